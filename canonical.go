@@ -0,0 +1,342 @@
+package gozone
+
+// https://www.ietf.org/rfc/rfc4034.txt section 6
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type rdataField int
+
+const (
+	rdataField_Name    rdataField = iota // a domain name, never compressed
+	rdataField_Uint8                     // a decimal number
+	rdataField_Uint16                    // a decimal number
+	rdataField_String                    // a <character-string>
+	rdataField_Strings                   // <character-string>s, to the end of the data
+	rdataField_Text                      // one string, to the end of the data without a length
+	rdataField_Hex                       // hexadecimal, to the end of the data
+	rdataField_Base64                    // base64, to the end of the data
+)
+
+// rdataSchemas describes the wire format of the data of types which have no
+// typed RData, so that they can still be written in wire and canonical form
+var rdataSchemas = map[RecordType][]rdataField{
+	RecordType_MD:         {rdataField_Name},
+	RecordType_MF:         {rdataField_Name},
+	RecordType_MB:         {rdataField_Name},
+	RecordType_MG:         {rdataField_Name},
+	RecordType_MR:         {rdataField_Name},
+	RecordType_DNAME:      {rdataField_Name},
+	RecordType_RP:         {rdataField_Name, rdataField_Name},
+	RecordType_AFSDB:      {rdataField_Uint16, rdataField_Name},
+	RecordType_RT:         {rdataField_Uint16, rdataField_Name},
+	RecordType_KX:         {rdataField_Uint16, rdataField_Name},
+	RecordType_PX:         {rdataField_Uint16, rdataField_Name, rdataField_Name},
+	RecordType_SRV:        {rdataField_Uint16, rdataField_Uint16, rdataField_Uint16, rdataField_Name},
+	RecordType_NAPTR:      {rdataField_Uint16, rdataField_Uint16, rdataField_String, rdataField_String, rdataField_String, rdataField_Name},
+	RecordType_X25:        {rdataField_String},
+	RecordType_ISDN:       {rdataField_Strings},
+	RecordType_SPF:        {rdataField_Strings},
+	RecordType_SSHFP:      {rdataField_Uint8, rdataField_Uint8, rdataField_Hex},
+	RecordType_TLSA:       {rdataField_Uint8, rdataField_Uint8, rdataField_Uint8, rdataField_Hex},
+	RecordType_SMIMEA:     {rdataField_Uint8, rdataField_Uint8, rdataField_Uint8, rdataField_Hex},
+	RecordType_CDS:        {rdataField_Uint16, rdataField_Uint8, rdataField_Uint8, rdataField_Hex},
+	RecordType_CDNSKEY:    {rdataField_Uint16, rdataField_Uint8, rdataField_Uint8, rdataField_Base64},
+	RecordType_DHCID:      {rdataField_Base64},
+	RecordType_OPENPGPKEY: {rdataField_Base64},
+	RecordType_URI:        {rdataField_Uint16, rdataField_Uint16, rdataField_Text},
+	RecordType_CAA:        {rdataField_Uint8, rdataField_String, rdataField_Text},
+}
+
+// packFields writes record data from its presentation fields, following the
+// schema of its type
+func (b *wireBuilder) packFields(rtype RecordType, schema []rdataField, fields []string) error {
+	names := b.names
+	b.names = nil
+	defer func() { b.names = names }()
+
+	fields = stripParens(fields)
+	for i, kind := range schema {
+		if i >= len(fields) {
+			return fmt.Errorf("%s record has %d fields, expected %d", rtype, len(fields), len(schema))
+		}
+		field := fields[i]
+
+		var err error
+		switch kind {
+		case rdataField_Name:
+			if err = checkDomainField(rtype, field); err == nil {
+				err = b.packName(field)
+			}
+		case rdataField_Uint8:
+			var value uint8
+			if value, err = parseUint8Field(rtype, "number", field); err == nil {
+				b.buf = append(b.buf, value)
+			}
+		case rdataField_Uint16:
+			var value uint16
+			if value, err = parseUint16Field(rtype, "number", field); err == nil {
+				b.buf = append(b.buf, byte(value>>8), byte(value))
+			}
+		case rdataField_String, rdataField_Strings:
+			rest := fields[i : i+1]
+			if kind == rdataField_Strings {
+				rest = fields[i:]
+			}
+
+			for _, field := range rest {
+				s, err := parseCharacterString(field)
+				if err != nil {
+					return fmt.Errorf("Invalid character-string in %s record: %s", rtype, err)
+				}
+				b.packCharacterString(s)
+			}
+		case rdataField_Text:
+			if len(fields) != i+1 {
+				return fmt.Errorf("%s record has %d fields, expected %d", rtype, len(fields), len(schema))
+			}
+
+			text := field
+			if len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"' {
+				text = text[1 : len(text)-1]
+			}
+
+			if text, err = decodeEscapes(text); err == nil {
+				b.buf = append(b.buf, text...)
+			}
+		case rdataField_Hex:
+			var data []byte
+			if data, err = hex.DecodeString(strings.Join(fields[i:], "")); err != nil {
+				err = fmt.Errorf("Invalid hexadecimal in %s record: %s", rtype, err)
+			}
+			b.buf = append(b.buf, data...)
+		case rdataField_Base64:
+			var data []byte
+			if data, err = base64.StdEncoding.DecodeString(strings.Join(fields[i:], "")); err != nil {
+				err = fmt.Errorf("Invalid base64 in %s record: %s", rtype, err)
+			}
+			b.buf = append(b.buf, data...)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if last := schema[len(schema)-1]; last != rdataField_Strings && last != rdataField_Hex && last != rdataField_Base64 && len(fields) != len(schema) {
+		return fmt.Errorf("%s record has %d fields, expected %d", rtype, len(fields), len(schema))
+	}
+
+	return nil
+}
+
+// unpackFields reads record data ending at end into presentation fields,
+// following the schema of its type. Data with an empty trailing field, which
+// presentation format cannot hold, is read in the generic form of RFC 3597.
+func (r *wireReader) unpackFields(rtype RecordType, schema []rdataField, end int) ([]string, error) {
+	// names within the data must stay within it, but may point anywhere before
+	bounded := &wireReader{msg: r.msg[:end], off: r.off}
+	defer func() { r.off = bounded.off }()
+
+	generic := func() ([]string, error) {
+		return presentationFields((&GenericRData{RType: rtype, Data: r.msg[r.off:end]}).String()), nil
+	}
+
+	var fields []string
+	for _, kind := range schema {
+		switch kind {
+		case rdataField_Name:
+			name, err := bounded.name()
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, name)
+		case rdataField_Uint8:
+			value, err := bounded.uint8()
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, strconv.Itoa(int(value)))
+		case rdataField_Uint16:
+			value, err := bounded.uint16()
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, strconv.Itoa(int(value)))
+		case rdataField_String:
+			s, err := bounded.characterString()
+			if err != nil {
+				return nil, err
+			}
+
+			// such as a CAA tag, which RFC 8659 writes without quotes
+			if isAlphanumeric(s) {
+				fields = append(fields, s)
+			} else {
+				fields = append(fields, quoteCharacterString(s))
+			}
+		case rdataField_Strings:
+			if bounded.off == end {
+				return generic()
+			}
+
+			for bounded.off < end {
+				s, err := bounded.characterString()
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, quoteCharacterString(s))
+			}
+		case rdataField_Text:
+			text, _ := bounded.bytes(end - bounded.off)
+			fields = append(fields, quoteCharacterString(string(text)))
+		case rdataField_Hex, rdataField_Base64:
+			data, _ := bounded.bytes(end - bounded.off)
+			switch {
+			case len(data) == 0:
+				return generic()
+			case kind == rdataField_Hex:
+				fields = append(fields, fmt.Sprintf("%X", data))
+			default:
+				fields = append(fields, base64.StdEncoding.EncodeToString(data))
+			}
+		}
+	}
+
+	return fields, nil
+}
+
+func isAlphanumeric(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !isDigit(c) && (c|0x20 < 'a' || c|0x20 > 'z') {
+			return false
+		}
+	}
+
+	return len(s) != 0
+}
+
+// packRecordData writes the data of a record, from its typed RData or else
+// the schema of its type
+func (b *wireBuilder) packRecordData(r Record) error {
+	rdata, err := r.RData()
+	if schema := rdataSchemas[r.Type]; schema != nil && errors.Is(err, ErrUnsupportedRData) {
+		err = b.packFields(r.Type, schema, r.Data)
+	} else if err == nil {
+		err = b.packRData(rdata)
+	}
+
+	if err != nil && !errors.Is(err, ErrWireFormat) {
+		return fmt.Errorf("Record for '%s' %w: %w", r.DomainName, ErrWireFormat, err)
+	}
+
+	return err
+}
+
+// CanonicalRData writes the data of a record in the canonical form of RFC
+// 4034 section 6.2: wire format, with no compression, and with the domain
+// names of the types which section 6.2 lists in lowercase, except NSEC (RFC
+// 6840 section 5.1).
+func (r Record) CanonicalRData() ([]byte, error) {
+	b := &wireBuilder{lowercase: true}
+	if err := b.packRecordData(r); err != nil {
+		return nil, err
+	}
+
+	return b.buf, nil
+}
+
+// CompareNames compares domain names in the canonical order of RFC 4034
+// section 6.1, returning -1, 0 or 1 as a sorts before, with or after b.
+// Names which are not absolute and valid sort after every valid name.
+func CompareNames(a, b string) int {
+	aLabels, aErr := nameLabels(a)
+	bLabels, bErr := nameLabels(b)
+	switch {
+	case aErr != nil && bErr != nil:
+		return strings.Compare(lowerASCII([]byte(a)), lowerASCII([]byte(b)))
+	case aErr != nil:
+		return 1
+	case bErr != nil:
+		return -1
+	}
+
+	switch c := compareLabels(aLabels, bLabels); {
+	case c < 0:
+		return -1
+	case c > 0:
+		return 1
+	}
+
+	return 0
+}
+
+// compareLabels orders names canonically, as RFC 4034 section 6.1: by their
+// lowercased labels, compared as bytes from the rightmost label
+func compareLabels(a, b []string) int {
+	for i, j := len(a)-1, len(b)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if c := strings.Compare(lowerASCII([]byte(a[i])), lowerASCII([]byte(b[j]))); c != 0 {
+			return c
+		}
+	}
+
+	return len(a) - len(b)
+}
+
+// SortCanonical sorts records by owner name in canonical order, then by
+// class and type, then by their data in canonical form (RFC 4034 section
+// 6.3), so that the same records always sort the same way. Records whose
+// data cannot be written in canonical form sort after the rest of their
+// RRset, by their presentation format.
+func SortCanonical(records []Record) {
+	sorter := canonicalSorter{records: records, rdatas: make([][]byte, len(records))}
+	for i, record := range records {
+		sorter.rdatas[i], _ = record.CanonicalRData()
+	}
+
+	sort.Stable(sorter)
+}
+
+type canonicalSorter struct {
+	records []Record
+	rdatas  [][]byte // nil where the data cannot be written in canonical form
+}
+
+func (s canonicalSorter) Len() int { return len(s.records) }
+
+func (s canonicalSorter) Swap(i, j int) {
+	s.records[i], s.records[j] = s.records[j], s.records[i]
+	s.rdatas[i], s.rdatas[j] = s.rdatas[j], s.rdatas[i]
+}
+
+func (s canonicalSorter) Less(i, j int) bool {
+	a, b := s.records[i], s.records[j]
+	if c := CompareNames(a.DomainName, b.DomainName); c != 0 {
+		return c < 0
+	}
+
+	if a.Class != b.Class {
+		return a.Class < b.Class
+	}
+
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+
+	switch {
+	case s.rdatas[i] != nil && s.rdatas[j] != nil:
+		return bytes.Compare(s.rdatas[i], s.rdatas[j]) < 0
+	case s.rdatas[i] != nil || s.rdatas[j] != nil:
+		return s.rdatas[i] != nil
+	}
+
+	return strings.Join(stripParens(a.Data), " ") < strings.Join(stripParens(b.Data), " ")
+}
@@ -0,0 +1,148 @@
+package gozone
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCompareNames(t *testing.T) {
+	// RFC 4034 section 6.1
+	expected := []string{
+		`example.`,
+		`a.example.`,
+		`yljkjljk.a.example.`,
+		`Z.a.example.`,
+		`zABC.a.EXAMPLE.`,
+		`z.example.`,
+		`\001.z.example.`,
+		`*.z.example.`,
+		`\200.z.example.`,
+		`not absolute`,
+	}
+
+	names := []string{expected[9], expected[4], expected[8], expected[0], expected[6], expected[2], expected[7], expected[1], expected[5], expected[3]}
+	sort.Slice(names, func(i, j int) bool { return CompareNames(names[i], names[j]) < 0 })
+
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Names sorted as %q, expected %q", names, expected)
+	}
+
+	if c := CompareNames("Example.COM.", "example.com."); c != 0 {
+		t.Fatalf("Names differing only in case compared as %d, expected 0", c)
+	}
+
+	if c := CompareNames(`a\.b.example.`, `b.example.`); c != -1 {
+		t.Fatalf("A name with an escaped dot compared as %d, expected -1", c)
+	}
+}
+
+func TestCanonicalRData(t *testing.T) {
+	records := []struct {
+		rtype    RecordType
+		data     []string
+		expected []byte
+	}{
+		{RecordType_MX, []string{"10", "Mail.Example."}, []byte("\x00\x0a\x04mail\x07example\x00")},
+		{RecordType_SRV, []string{"1", "2", "3", "SIP.Example."}, []byte("\x00\x01\x00\x02\x00\x03\x03sip\x07example\x00")},
+		{RecordType_NSEC, []string{"Next.Example.", "A"}, []byte("\x04Next\x07Example\x00\x00\x01\x40")},
+		{RecordType_CAA, []string{"0", "issue", `"ca.example.net"`}, []byte("\x00\x05issueca.example.net")},
+		{RecordType_TLSA, []string{"3", "1", "1", "0102", "0304"}, []byte{3, 1, 1, 1, 2, 3, 4}},
+		{RecordType_NAPTR, []string{"100", "10", `"S"`, `"SIP+D2U"`, `""`, "_sip._udp.Example."}, []byte("\x00\x64\x00\x0a\x01S\x07SIP+D2U\x00\x04_sip\x04_udp\x07example\x00")},
+		{RecordType_URI, []string{"10", "1", `"https://example.com/"`}, []byte("\x00\x0a\x00\x01https://example.com/")},
+		{RecordType_SRV, []string{`\#`, "3", "010203"}, []byte{1, 2, 3}},
+		{RecordType_RRSIG, []string{"A", "13", "2", "3600", "20240101000000", "20231201000000", "12345", "Example.", "AQID"},
+			[]byte("\x00\x01\x0d\x02\x00\x00\x0e\x10\x65\x92\x00\x80\x65\x69\x22\x00\x30\x39\x07example\x00\x01\x02\x03")},
+	}
+
+	for _, r := range records {
+		record := Record{DomainName: "example.", TimeToLive: 60, Class: RecordClass_IN, Type: r.rtype, Data: r.data}
+		rdata, err := record.CanonicalRData()
+		if err != nil {
+			t.Fatalf("Failed to write %s %v in canonical form: %s", r.rtype, r.data, err)
+		}
+
+		if !reflect.DeepEqual(rdata, r.expected) {
+			t.Fatalf("%s %v was %q in canonical form, expected %q", r.rtype, r.data, rdata, r.expected)
+		}
+	}
+}
+
+func TestCanonicalRDataErrors(t *testing.T) {
+	records := []struct {
+		rtype RecordType
+		data  []string
+	}{
+		{RecordType_SRV, []string{"1", "2", "3"}},
+		{RecordType_SRV, []string{"1", "2", "3", "sip.example.", "extra"}},
+		{RecordType_SRV, []string{"1", "2", "70000", "sip.example."}},
+		{RecordType_SRV, []string{"1", "2", "3", "relative"}},
+		{RecordType_SSHFP, []string{"1", "1", "XYZ"}},
+		{RecordType_OPENPGPKEY, []string{"not base64!"}},
+		{RecordType_LOC, []string{"52", "22", "23.000", "N", "4", "53", "32.000", "E", "-2.00m"}},
+	}
+
+	for _, r := range records {
+		record := Record{DomainName: "example.", TimeToLive: 60, Class: RecordClass_IN, Type: r.rtype, Data: r.data}
+		if rdata, err := record.CanonicalRData(); !errors.Is(err, ErrWireFormat) {
+			t.Fatalf("Writing %s %v in canonical form should have failed, got %x, %v", r.rtype, r.data, rdata, err)
+		}
+	}
+}
+
+func TestSortCanonical(t *testing.T) {
+	records := readAllRecords(t, `$ORIGIN example.
+$TTL 300
+www       IN A     192.0.2.10
+b         IN TXT   "b"
+@         IN MX    10 mail.example.
+WWW       IN A     192.0.2.9
+@         IN NS    ns.example.
+a.b       IN A     192.0.2.1
+www       IN AAAA  2001:db8::1
+b         IN A     192.0.2.2
+@         CH TXT   "chaos"
+_sip._tcp IN SRV   0 0 5060 sip.example.
+`)
+
+	SortCanonical(records)
+
+	var got []string
+	for _, r := range records {
+		got = append(got, r.DomainName+" "+r.Class.String()+" "+r.Type.String()+" "+strings.Join(r.Data, " "))
+	}
+
+	expected := []string{
+		"example. IN NS ns.example.",
+		"example. IN MX 10 mail.example.",
+		"example. CH TXT \"chaos\"",
+		"_sip._tcp.example. IN SRV 0 0 5060 sip.example.",
+		"b.example. IN A 192.0.2.2",
+		"b.example. IN TXT \"b\"",
+		"a.b.example. IN A 192.0.2.1",
+		"WWW.example. IN A 192.0.2.9",
+		"www.example. IN A 192.0.2.10",
+		"www.example. IN AAAA 2001:db8::1",
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Records sorted as\n%s\nexpected\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestRDataSchemasMatchDomainFields(t *testing.T) {
+	for rtype, schema := range rdataSchemas {
+		var names []int
+		for i, kind := range schema {
+			if kind == rdataField_Name {
+				names = append(names, i)
+			}
+		}
+
+		if !reflect.DeepEqual(names, rdataDomainFields[rtype]) {
+			t.Fatalf("%s schema has domain names at %v, but rdataDomainFields lists %v", rtype, names, rdataDomainFields[rtype])
+		}
+	}
+}
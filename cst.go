@@ -0,0 +1,384 @@
+package gozone
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The concrete syntax tree (CST) keeps every byte of a zone file, including
+// blank lines, comments, spacing and the original spelling of names, so that
+// a zone file can be edited by a program and written back out unchanged
+// everywhere except for the edits.
+
+type CSTKind int
+
+const (
+	CSTKind_Word       CSTKind = iota // a field, such as a domain, TTL, type or data
+	CSTKind_String                    // a quoted string field
+	CSTKind_Space                     // blanks between fields
+	CSTKind_Comment                   // a comment, from ';' to the end of the line
+	CSTKind_Newline                   // a line ending, "\n" or "\r\n"
+	CSTKind_OpenParen                 // '(', continuing an entry across lines
+	CSTKind_CloseParen                // ')'
+)
+
+type CSTToken struct {
+	Kind CSTKind
+	Text string
+}
+
+type CSTEntryKind int
+
+const (
+	CSTEntryKind_Blank   CSTEntryKind = iota // only blanks and comments
+	CSTEntryKind_Control                     // a control entry, such as $ORIGIN
+	CSTEntryKind_Record                      // a resource record
+)
+
+// CSTEntry is one logical line of a zone file: every token up to and
+// including the newline which ends it outside of parentheses.
+type CSTEntry struct {
+	Tokens []CSTToken
+}
+
+// CST is a lossless concrete syntax tree of a zone file.
+type CST struct {
+	Entries []*CSTEntry
+}
+
+// CSTRecord is a Record parsed from a CST, along with the entry it came from.
+type CSTRecord struct {
+	Record
+	Entry *CSTEntry
+}
+
+// ParseCST reads a zone file into a CST. Writing the CST back out reproduces
+// the input exactly.
+func ParseCST(src io.Reader) (*CST, error) {
+	input, err := io.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+
+	cst := &CST{}
+	entry := &CSTEntry{}
+	text := string(input)
+	depth := 0
+	line := 1
+
+	for len(text) != 0 {
+		token, err := lexCSTToken(text, depth != 0)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		text = text[len(token.Text):]
+		line += strings.Count(token.Text, "\n")
+
+		switch token.Kind {
+		case CSTKind_OpenParen:
+			depth++
+		case CSTKind_CloseParen:
+			depth--
+		}
+
+		entry.Tokens = append(entry.Tokens, token)
+		if token.Kind == CSTKind_Newline && depth == 0 {
+			cst.Entries = append(cst.Entries, entry)
+			entry = &CSTEntry{}
+		}
+	}
+
+	if depth != 0 {
+		return nil, fmt.Errorf("line %d: unexpected end of input inside parentheses", line)
+	}
+
+	if len(entry.Tokens) != 0 {
+		cst.Entries = append(cst.Entries, entry)
+	}
+
+	return cst, nil
+}
+
+// lexCSTToken splits the first token off text, following the same rules as
+// Scanner.scanToken: blanks are the ASCII whitespace of isSpace, a '(' only
+// opens parentheses outside of them and a ')' only closes them inside,
+// and are otherwise part of a word. Unlike the Scanner, a line end within
+// parentheses is kept as a token, and "\r\n" is a single line end.
+func lexCSTToken(text string, inParens bool) (CSTToken, error) {
+	switch {
+	case text[0] == '\n':
+		return CSTToken{CSTKind_Newline, text[:1]}, nil
+	case text[0] == '(' && !inParens:
+		return CSTToken{CSTKind_OpenParen, text[:1]}, nil
+	case text[0] == ')' && inParens:
+		return CSTToken{CSTKind_CloseParen, text[:1]}, nil
+	case text[0] == ';':
+		end := strings.IndexByte(text, '\n')
+		if end == -1 {
+			end = len(text)
+		}
+		if end > 1 && text[end-1] == '\r' {
+			end--
+		}
+		return CSTToken{CSTKind_Comment, text[:end]}, nil
+	case text[0] == '"':
+		for i := 1; i < len(text); i++ {
+			if text[i] == '\\' {
+				i++
+				continue
+			}
+
+			if text[i] == '"' {
+				return CSTToken{CSTKind_String, text[:i+1]}, nil
+			}
+		}
+		return CSTToken{}, fmt.Errorf("unterminated quoted string")
+	}
+
+	if strings.HasPrefix(text, "\r\n") {
+		return CSTToken{CSTKind_Newline, text[:2]}, nil
+	}
+
+	end := 0
+	for end < len(text) && isSpace(text[end]) && text[end] != '\n' && !strings.HasPrefix(text[end:], "\r\n") {
+		end++
+	}
+
+	if end != 0 {
+		return CSTToken{CSTKind_Space, text[:end]}, nil
+	}
+
+	for end < len(text) {
+		c := text[end]
+		if isSpace(c) || c == ';' || c == '"' || (c == '(' && !inParens) || (c == ')' && inParens) {
+			break
+		}
+
+		if c == '\\' && end+1 < len(text) {
+			end++
+		}
+		end++
+	}
+
+	return CSTToken{CSTKind_Word, text[:end]}, nil
+}
+
+func (e *CSTEntry) String() string {
+	var out strings.Builder
+	for _, token := range e.Tokens {
+		_, _ = out.WriteString(token.Text)
+	}
+
+	return out.String()
+}
+
+func (e *CSTEntry) Kind() CSTEntryKind {
+	for _, token := range e.Tokens {
+		switch token.Kind {
+		case CSTKind_Word:
+			if token.Text[0] == '$' {
+				return CSTEntryKind_Control
+			}
+			return CSTEntryKind_Record
+		case CSTKind_String, CSTKind_OpenParen, CSTKind_CloseParen:
+			return CSTEntryKind_Record
+		}
+	}
+
+	return CSTEntryKind_Blank
+}
+
+// Fields returns the text of each word and quoted string of the entry, as
+// written.
+func (e *CSTEntry) Fields() []string {
+	var fields []string
+	for _, token := range e.Tokens {
+		if token.Kind == CSTKind_Word || token.Kind == CSTKind_String {
+			fields = append(fields, token.Text)
+		}
+	}
+
+	return fields
+}
+
+// SetField replaces the text of the n'th field returned by Fields, leaving
+// the rest of the entry untouched.
+func (e *CSTEntry) SetField(n int, text string) error {
+	// the field must be read the same way whether or not it is within
+	// parentheses
+	var replacement CSTToken
+	for _, inParens := range []bool{false, true} {
+		var err error
+		replacement, err = lexCSTToken(text, inParens)
+		if err != nil || replacement.Text != text ||
+			(replacement.Kind != CSTKind_Word && replacement.Kind != CSTKind_String) {
+			return fmt.Errorf("'%s' is not a single field", text)
+		}
+	}
+
+	for i, token := range e.Tokens {
+		if token.Kind != CSTKind_Word && token.Kind != CSTKind_String {
+			continue
+		}
+
+		if n == 0 {
+			e.Tokens[i] = replacement
+			return nil
+		}
+		n--
+	}
+
+	return fmt.Errorf("entry has no field %d", n)
+}
+
+func (e *CSTEntry) endsLine() bool {
+	return len(e.Tokens) != 0 && e.Tokens[len(e.Tokens)-1].Kind == CSTKind_Newline
+}
+
+// NewCSTEntry creates an entry for record, written as by Record.String.
+func NewCSTEntry(record Record) (*CSTEntry, error) {
+	if strings.HasPrefix(record.DomainName, "$") {
+		// a leading $ would start a control entry
+		record.DomainName = "\\" + record.DomainName
+	}
+
+	cst, err := ParseCST(strings.NewReader(record.String() + "\n"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cst.Entries) != 1 {
+		return nil, fmt.Errorf("Record does not form a single entry")
+	}
+
+	return cst.Entries[0], nil
+}
+
+func (c *CST) String() string {
+	var out strings.Builder
+	for _, entry := range c.Entries {
+		_, _ = out.WriteString(entry.String())
+	}
+
+	return out.String()
+}
+
+func (c *CST) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, c.String())
+	return int64(n), err
+}
+
+// Records parses the records of the CST, pairing each with the entry it was
+// read from. Records produced by a $GENERATE control entry are paired with
+// that entry.
+func (c *CST) Records() ([]CSTRecord, error) {
+	entries := make(map[int]*CSTEntry, len(c.Entries))
+	line := 1
+	for _, entry := range c.Entries {
+		text := entry.String()
+		entries[line] = entry
+		line += strings.Count(text, "\n")
+	}
+
+	var records []CSTRecord
+	var record Record
+	s := NewScanner(strings.NewReader(c.String()))
+	for {
+		err := s.Next(&record)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		entry, ok := entries[record.Position.Line]
+		if !ok {
+			return nil, fmt.Errorf("%s: Record does not start an entry", record.Position)
+		}
+		records = append(records, CSTRecord{Record: record, Entry: entry})
+	}
+
+	return records, nil
+}
+
+func (c *CST) indexOf(entry *CSTEntry) int {
+	for i, e := range c.Entries {
+		if e == entry {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Replace swaps entry for a new entry holding record, returning the new entry.
+func (c *CST) Replace(entry *CSTEntry, record Record) (*CSTEntry, error) {
+	i := c.indexOf(entry)
+	if i == -1 {
+		return nil, fmt.Errorf("entry is not part of this CST")
+	}
+
+	replacement, err := NewCSTEntry(record)
+	if err != nil {
+		return nil, err
+	}
+
+	if !entry.endsLine() {
+		replacement.Tokens = replacement.Tokens[:len(replacement.Tokens)-1]
+	}
+
+	c.Entries[i] = replacement
+	return replacement, nil
+}
+
+// InsertAfter adds a new entry holding record after entry, or at the start
+// of the file when entry is nil, returning the new entry. Note that a
+// following record which starts with a blank will inherit its domain from
+// the inserted record.
+func (c *CST) InsertAfter(entry *CSTEntry, record Record) (*CSTEntry, error) {
+	i := 0
+	if entry != nil {
+		if i = c.indexOf(entry); i == -1 {
+			return nil, fmt.Errorf("entry is not part of this CST")
+		}
+		i++
+	}
+
+	inserted, err := NewCSTEntry(record)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil && !entry.endsLine() {
+		entry.Tokens = append(entry.Tokens, CSTToken{CSTKind_Newline, "\n"})
+		inserted.Tokens = inserted.Tokens[:len(inserted.Tokens)-1]
+	}
+
+	c.Entries = append(c.Entries, nil)
+	copy(c.Entries[i+1:], c.Entries[i:])
+	c.Entries[i] = inserted
+	return inserted, nil
+}
+
+// Append adds a new entry holding record at the end of the file.
+func (c *CST) Append(record Record) (*CSTEntry, error) {
+	if len(c.Entries) == 0 {
+		return c.InsertAfter(nil, record)
+	}
+
+	return c.InsertAfter(c.Entries[len(c.Entries)-1], record)
+}
+
+// Remove deletes entry from the CST.
+func (c *CST) Remove(entry *CSTEntry) error {
+	i := c.indexOf(entry)
+	if i == -1 {
+		return fmt.Errorf("entry is not part of this CST")
+	}
+
+	c.Entries = append(c.Entries[:i], c.Entries[i+1:]...)
+	return nil
+}
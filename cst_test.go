@@ -0,0 +1,219 @@
+package gozone
+
+import (
+	"strings"
+	"testing"
+)
+
+const cstZone = `; example zone
+$ORIGIN adomain.com.
+$TTL 1h
+
+@	IN	SOA	ns  hostmaster (
+		1271271271 ; serial
+		10800      ; refresh
+		3600 604800 300 )
+
+www   300 IN A     192.168.0.1   ; web
+	    300 IN A 192.168.0.2
+mail  IN  MX  10 smtp.ahostdomain.com.
+txt IN TXT "a \"quoted\" ; string" "b"
+escaped\ name IN A 192.168.0.3` + "\r\n" + `crlf IN A 192.168.0.4 ;c` + "\r\n" + `last IN A 192.168.0.5`
+
+func TestCSTRoundTrip(t *testing.T) {
+	cst, err := ParseCST(strings.NewReader(cstZone))
+	if err != nil {
+		t.Fatalf("Failed to parse CST: %s", err)
+	}
+
+	if cst.String() != cstZone {
+		t.Fatalf("CST output [%s] not equal to input [%s]", cst.String(), cstZone)
+	}
+
+	var out strings.Builder
+	if _, err = cst.WriteTo(&out); err != nil || out.String() != cstZone {
+		t.Fatalf("CST WriteTo did not reproduce the input")
+	}
+}
+
+func TestCSTEntryKinds(t *testing.T) {
+	cst, err := ParseCST(strings.NewReader(cstZone))
+	if err != nil {
+		t.Fatalf("Failed to parse CST: %s", err)
+	}
+
+	expected := []CSTEntryKind{
+		CSTEntryKind_Blank,
+		CSTEntryKind_Control,
+		CSTEntryKind_Control,
+		CSTEntryKind_Blank,
+		CSTEntryKind_Record,
+		CSTEntryKind_Blank,
+		CSTEntryKind_Record,
+		CSTEntryKind_Record,
+		CSTEntryKind_Record,
+		CSTEntryKind_Record,
+		CSTEntryKind_Record,
+		CSTEntryKind_Record,
+		CSTEntryKind_Record,
+	}
+
+	if len(cst.Entries) != len(expected) {
+		t.Fatalf("CST has %d entries, expected %d", len(cst.Entries), len(expected))
+	}
+
+	for i, kind := range expected {
+		if cst.Entries[i].Kind() != kind {
+			t.Fatalf("CST entry %d [%s] has kind %d, expected %d", i, cst.Entries[i], cst.Entries[i].Kind(), kind)
+		}
+	}
+
+	fields := cst.Entries[4].Fields()
+	if strings.Join(fields, " ") != "@ IN SOA ns hostmaster 1271271271 10800 3600 604800 300" {
+		t.Fatalf("CST SOA entry has unexpected fields %v", fields)
+	}
+}
+
+func TestCSTRecords(t *testing.T) {
+	cst, err := ParseCST(strings.NewReader(cstZone))
+	if err != nil {
+		t.Fatalf("Failed to parse CST: %s", err)
+	}
+
+	records, err := cst.Records()
+	if err != nil {
+		t.Fatalf("Failed to read records from CST: %s", err)
+	}
+
+	if len(records) != 8 {
+		t.Fatalf("CST has %d records, expected 8", len(records))
+	}
+
+	if records[2].DomainName != "www.adomain.com." || records[2].Entry != cst.Entries[7] {
+		t.Fatalf("CST record for inherited domain was not paired with its entry")
+	}
+}
+
+func TestCSTEditField(t *testing.T) {
+	cst, err := ParseCST(strings.NewReader(cstZone))
+	if err != nil {
+		t.Fatalf("Failed to parse CST: %s", err)
+	}
+
+	records, err := cst.Records()
+	if err != nil {
+		t.Fatalf("Failed to read records from CST: %s", err)
+	}
+
+	if err = records[1].Entry.SetField(4, "192.168.0.10"); err != nil {
+		t.Fatalf("Failed to set CST field: %s", err)
+	}
+
+	expected := strings.Replace(cstZone, "192.168.0.1   ; web", "192.168.0.10   ; web", 1)
+	if cst.String() != expected {
+		t.Fatalf("CST output after editing a field [%s] not equal to expected [%s]", cst.String(), expected)
+	}
+
+	if err = records[1].Entry.SetField(4, "two fields"); err == nil {
+		t.Fatalf("Setting a CST field to multiple fields did not return an error")
+	}
+
+	if err = records[1].Entry.SetField(10, "x"); err == nil {
+		t.Fatalf("Setting a missing CST field did not return an error")
+	}
+}
+
+func TestCSTReplaceAndInsert(t *testing.T) {
+	cst, err := ParseCST(strings.NewReader(cstZone))
+	if err != nil {
+		t.Fatalf("Failed to parse CST: %s", err)
+	}
+
+	records, err := cst.Records()
+	if err != nil {
+		t.Fatalf("Failed to read records from CST: %s", err)
+	}
+
+	mx := records[3]
+	mx.Data = []string{"20", "smtp2.ahostdomain.com."}
+	if _, err = cst.Replace(mx.Entry, mx.Record); err != nil {
+		t.Fatalf("Failed to replace CST record: %s", err)
+	}
+
+	if _, err = cst.InsertAfter(records[4].Entry, Record{"ftp.adomain.com.", 300, RecordClass_IN, RecordType_A, []string{"192.168.0.9"}, "", Position{}}); err != nil {
+		t.Fatalf("Failed to insert CST record: %s", err)
+	}
+
+	if _, err = cst.Append(Record{"end.adomain.com.", -1, RecordClass_IN, RecordType_A, []string{"192.168.0.99"}, "", Position{}}); err != nil {
+		t.Fatalf("Failed to append CST record: %s", err)
+	}
+
+	expected := strings.Replace(cstZone, "mail  IN  MX  10 smtp.ahostdomain.com.\n", "mail.adomain.com. 3600 IN MX 20 smtp2.ahostdomain.com.\n", 1)
+	expected = strings.Replace(expected, "\"b\"\n", "\"b\"\nftp.adomain.com. 300 IN A 192.168.0.9\n", 1)
+	expected += "\nend.adomain.com. IN A 192.168.0.99"
+	if cst.String() != expected {
+		t.Fatalf("CST output after edits [%s] not equal to expected [%s]", cst.String(), expected)
+	}
+
+	if err = cst.Remove(records[0].Entry); err != nil {
+		t.Fatalf("Failed to remove CST record: %s", err)
+	}
+
+	if strings.Contains(cst.String(), "SOA") {
+		t.Fatalf("Removed CST entry is still present")
+	}
+}
+
+func TestCSTUnbalancedFails(t *testing.T) {
+	for _, spec := range []string{
+		"adomain.com. 300 IN SOA ( 1 2 3",
+		"adomain.com. 300 IN TXT \"abc",
+	} {
+		if _, err := ParseCST(strings.NewReader(spec)); err == nil {
+			t.Fatalf("Parsing of unbalanced CST [%s] did not return an error", spec)
+		}
+	}
+}
+
+func TestCSTScannerRules(t *testing.T) {
+	for _, zone := range []string{
+		"a.\u00a0b. 300 IN A 192.168.0.1\n",
+		"a. 300 IN TXT ( b(c ) \n",
+		"a. 300 IN TXT b) \"c;d\" ;e\n",
+		"a. 300 IN TXT b\\;c\\\"d\n",
+	} {
+		cst, err := ParseCST(strings.NewReader(zone))
+		if err != nil {
+			t.Fatalf("Failed to parse CST [%s]: %s", zone, err)
+		}
+
+		if cst.String() != zone {
+			t.Fatalf("CST output [%s] not equal to input [%s]", cst.String(), zone)
+		}
+
+		records, err := cst.Records()
+		if err != nil || len(records) != 1 {
+			t.Fatalf("Failed to read the record from CST [%s]: %v", zone, err)
+		}
+
+		var expected Record
+		if err = NewScanner(strings.NewReader(zone)).Next(&expected); err != nil {
+			t.Fatalf("Failed to scan [%s]: %s", zone, err)
+		}
+
+		if records[0].String() != expected.String() {
+			t.Fatalf("CST record [%s] not equal to scanned record [%s]", records[0], expected)
+		}
+	}
+}
+
+func TestCSTDollarOwner(t *testing.T) {
+	entry, err := NewCSTEntry(Record{"$x.adomain.com.", 300, RecordClass_IN, RecordType_A, []string{"192.168.0.1"}, "", Position{}})
+	if err != nil {
+		t.Fatalf("Failed to create CST entry: %s", err)
+	}
+
+	if entry.Kind() != CSTEntryKind_Record {
+		t.Fatalf("CST entry [%s] for an owner starting with $ is not a record", entry)
+	}
+}
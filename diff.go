@@ -0,0 +1,292 @@
+package gozone
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// RRsetChange is an RRset which is in both sides of a diff.
+type RRsetChange struct {
+	Old *RRset
+	New *RRset
+}
+
+// ZoneDiff is the difference in meaning between two sets of records: it
+// ignores formatting, record order, and the case of domain names.
+type ZoneDiff struct {
+	Added      []*RRset
+	Removed    []*RRset
+	Changed    []RRsetChange // RRsets whose data changed, and maybe their TTL too
+	TTLChanged []RRsetChange // RRsets whose data is unchanged, but whose TTL changed
+
+	oldSOA *Record
+	newSOA *Record
+
+	rdataKeys map[*RRset][]string // the rdataKey of each record of each RRset
+}
+
+// Diff compares the records of two versions of a zone.
+func Diff(before, after []Record) *ZoneDiff {
+	d := &ZoneDiff{oldSOA: firstSOA(before), newSOA: firstSOA(after), rdataKeys: make(map[*RRset][]string)}
+	oldSets, _ := d.groupRRsets(before)
+	newSets, keys := d.groupRRsets(after)
+
+	for key := range oldSets {
+		if newSets[key] == nil {
+			keys = append(keys, key)
+		}
+	}
+
+	rrset := func(key string) *RRset {
+		if newSets[key] != nil {
+			return newSets[key]
+		}
+		return oldSets[key]
+	}
+
+	// RRsets are listed in canonical order
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := rrset(keys[i]), rrset(keys[j])
+		if c := CompareNames(a.DomainName, b.DomainName); c != 0 {
+			return c < 0
+		}
+		if a.Class != b.Class {
+			return a.Class < b.Class
+		}
+		return a.Type < b.Type
+	})
+
+	for _, key := range keys {
+		oldSet, newSet := oldSets[key], newSets[key]
+		switch {
+		case oldSet == nil:
+			d.Added = append(d.Added, newSet)
+		case newSet == nil:
+			d.Removed = append(d.Removed, oldSet)
+		case !d.sameRData(oldSet, newSet):
+			d.Changed = append(d.Changed, RRsetChange{oldSet, newSet})
+		case !sameTTL(oldSet, newSet):
+			d.TTLChanged = append(d.TTLChanged, RRsetChange{oldSet, newSet})
+		}
+	}
+
+	return d
+}
+
+// Empty reports whether both versions have the same meaning.
+func (d *ZoneDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.TTLChanged) == 0
+}
+
+func (d *ZoneDiff) String() string {
+	var out strings.Builder
+	section := func(title string, count int) {
+		if count != 0 {
+			_, _ = fmt.Fprintf(&out, "%s:\n", title)
+		}
+	}
+
+	section("Added", len(d.Added))
+	for _, rrset := range d.Added {
+		for _, record := range rrset.Records {
+			_, _ = fmt.Fprintf(&out, "  + %s\n", diffRecordString(record))
+		}
+	}
+
+	section("Removed", len(d.Removed))
+	for _, rrset := range d.Removed {
+		for _, record := range rrset.Records {
+			_, _ = fmt.Fprintf(&out, "  - %s\n", diffRecordString(record))
+		}
+	}
+
+	section("Changed", len(d.Changed))
+	for _, change := range d.Changed {
+		_, _ = fmt.Fprintf(&out, "  %s %s %s\n", change.New.DomainName, change.New.Class, change.New.Type)
+
+		// records in both versions are left out, as for IXFR, unless a new
+		// TTL changes every record
+		removed, added := d.missingRecords(change.Old, change.New), d.missingRecords(change.New, change.Old)
+		if !sameTTL(change.Old, change.New) {
+			removed, added = change.Old.Records, change.New.Records
+		}
+
+		for _, record := range removed {
+			_, _ = fmt.Fprintf(&out, "    - %d %s\n", record.TimeToLive, strings.Join(record.Data, " "))
+		}
+		for _, record := range added {
+			_, _ = fmt.Fprintf(&out, "    + %d %s\n", record.TimeToLive, strings.Join(record.Data, " "))
+		}
+	}
+
+	section("TTL changed", len(d.TTLChanged))
+	for _, change := range d.TTLChanged {
+		_, _ = fmt.Fprintf(&out, "  %s %s %s: %d -> %d\n", change.New.DomainName, change.New.Class, change.New.Type,
+			change.Old.Records[0].TimeToLive, change.New.Records[0].TimeToLive)
+	}
+
+	return out.String()
+}
+
+// IXFR returns the diff as an RFC 1995 incremental transfer sequence: the
+// old SOA record, the records to delete, the new SOA record, then the
+// records to add. The new SOA serial must be greater than the old one, under
+// RFC 1982 serial number arithmetic.
+func (d *ZoneDiff) IXFR() ([]Record, error) {
+	if d.oldSOA == nil || d.newSOA == nil {
+		return nil, fmt.Errorf("IXFR needs an SOA record in both versions of the zone")
+	}
+
+	oldSOA, err := rdataAs[*SOA](*d.oldSOA)
+	if err != nil {
+		return nil, err
+	}
+
+	newSOA, err := rdataAs[*SOA](*d.newSOA)
+	if err != nil {
+		return nil, err
+	}
+
+	if c, ok := CompareSerial(newSOA.Serial, oldSOA.Serial); !ok || c <= 0 {
+		return nil, fmt.Errorf("IXFR needs the new SOA serial %d to be greater than the old serial %d", newSOA.Serial, oldSOA.Serial)
+	}
+
+	deleted := []Record{*d.oldSOA}
+	var added []Record
+	for _, rrset := range d.Removed {
+		deleted = append(deleted, rrset.Records...)
+	}
+
+	for _, rrset := range d.Added {
+		added = append(added, rrset.Records...)
+	}
+
+	for _, change := range append(append([]RRsetChange{}, d.Changed...), d.TTLChanged...) {
+		if change.New.Type == RecordType_SOA {
+			continue
+		}
+
+		// a record is only replaced as a whole, so a new TTL replaces every record
+		if !sameTTL(change.Old, change.New) {
+			deleted = append(deleted, change.Old.Records...)
+			added = append(added, change.New.Records...)
+			continue
+		}
+
+		deleted = append(deleted, d.missingRecords(change.Old, change.New)...)
+		added = append(added, d.missingRecords(change.New, change.Old)...)
+	}
+
+	return append(append(deleted, *d.newSOA), added...), nil
+}
+
+func firstSOA(records []Record) *Record {
+	for i := range records {
+		if records[i].Type == RecordType_SOA {
+			return &records[i]
+		}
+	}
+
+	return nil
+}
+
+// groupRRsets groups records into RRsets, keyed by owner, class and type,
+// returning the keys in the order each was first seen
+func (d *ZoneDiff) groupRRsets(records []Record) (map[string]*RRset, []string) {
+	sets := make(map[string]*RRset)
+	seen := make(map[string]bool)
+	var keys []string
+	for _, record := range records {
+		key := fmt.Sprintf("%s %d %d", ownerKey(record.DomainName), record.Class, record.Type)
+		rrset := sets[key]
+		if rrset == nil {
+			rrset = &RRset{DomainName: record.DomainName, Class: record.Class, Type: record.Type}
+			sets[key] = rrset
+			keys = append(keys, key)
+		}
+
+		// RRsets are sets, so duplicate records are dropped
+		rdata := rdataKey(record)
+		if !seen[key+"\n"+rdata] {
+			seen[key+"\n"+rdata] = true
+			rrset.Records = append(rrset.Records, record)
+			d.rdataKeys[rrset] = append(d.rdataKeys[rrset], rdata)
+		}
+	}
+
+	return sets, keys
+}
+
+func ownerKey(name string) string {
+	labels, err := nameLabels(name)
+	if err != nil {
+		return lowerASCII([]byte(name))
+	}
+
+	return labelsKey(labels)
+}
+
+// rdataKey is equal for records whose data has the same meaning
+func rdataKey(record Record) string {
+	fields := slices.Clone(stripParens(record.Data))
+	if rdata, err := record.RData(); err == nil {
+		fields = presentationFields(rdata.String())
+	}
+
+	for _, i := range rdataDomainFields[record.Type] {
+		if i < len(fields) {
+			fields[i] = ownerKey(fields[i])
+		}
+	}
+
+	return strings.Join(fields, " ")
+}
+
+func containsRData(rrset *RRset, record Record) bool {
+	key := rdataKey(record)
+	for _, existing := range rrset.Records {
+		if rdataKey(existing) == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// missingRecords returns the records of a which are not in b
+func (d *ZoneDiff) missingRecords(a, b *RRset) []Record {
+	present := make(map[string]bool, len(b.Records))
+	for _, key := range d.rdataKeys[b] {
+		present[key] = true
+	}
+
+	var missing []Record
+	for i, record := range a.Records {
+		if !present[d.rdataKeys[a][i]] {
+			missing = append(missing, record)
+		}
+	}
+
+	return missing
+}
+
+func (d *ZoneDiff) sameRData(a, b *RRset) bool {
+	return len(a.Records) == len(b.Records) && len(d.missingRecords(a, b)) == 0
+}
+
+func sameTTL(a, b *RRset) bool {
+	for _, record := range append(append([]Record{}, a.Records...), b.Records...) {
+		if record.TimeToLive != a.Records[0].TimeToLive {
+			return false
+		}
+	}
+
+	return true
+}
+
+func diffRecordString(record Record) string {
+	record.Comment = ""
+	return record.String()
+}
@@ -0,0 +1,119 @@
+package gozone
+
+import (
+	"strings"
+	"testing"
+)
+
+const diffOldZone = `$ORIGIN adomain.com.
+@ 3600 IN SOA ns.adomain.com. hostmaster.adomain.com. 1 10800 3600 604800 300
+@ 3600 IN NS ns.adomain.com.
+www 300 IN A 192.168.0.1
+www 300 IN A 192.168.0.2
+mail 3600 IN MX 10 smtp.adomain.com.
+old 300 IN TXT "gone"
+`
+
+const diffNewZone = `$ORIGIN adomain.com.
+; reordered and reformatted
+www	300	IN	A	192.168.0.2
+WWW.adomain.com.	300	IN	A	192.168.0.3
+@	3600	IN	NS	NS.ADOMAIN.COM.
+@	3600	IN	SOA	ns.adomain.com. hostmaster.adomain.com. ( 2 3h 1h 1w 300 )
+mail	2h	IN	MX	10 smtp.adomain.com.
+new	300	IN	TXT	"here"
+`
+
+func TestDiff(t *testing.T) {
+	d := Diff(readAllRecords(t, diffOldZone), readAllRecords(t, diffNewZone))
+
+	expected := `Added:
+  + new.adomain.com. 300 IN TXT "here"
+Removed:
+  - old.adomain.com. 300 IN TXT "gone"
+Changed:
+  adomain.com. IN SOA
+    - 3600 ns.adomain.com. hostmaster.adomain.com. 1 10800 3600 604800 300
+    + 3600 ns.adomain.com. hostmaster.adomain.com. ( 2 3h 1h 1w 300 )
+  www.adomain.com. IN A
+    - 300 192.168.0.1
+    + 300 192.168.0.3
+TTL changed:
+  mail.adomain.com. IN MX: 3600 -> 7200
+`
+	if d.String() != expected {
+		t.Fatalf("Diff was [%s], expected [%s]", d, expected)
+	}
+
+	if d.Empty() {
+		t.Fatalf("Diff of different zones is empty")
+	}
+}
+
+func TestDiffStringPartialChange(t *testing.T) {
+	old := "adomain.com. 300 IN NS ns1.adomain.com.\nadomain.com. 300 IN NS ns2.adomain.com.\nadomain.com. 300 IN NS ns3.adomain.com.\n"
+	changed := map[string]string{
+		"adomain.com. 300 IN NS ns1.adomain.com.\nadomain.com. 300 IN NS ns3.adomain.com.\nadomain.com. 300 IN NS ns4.adomain.com.\n": `Changed:
+  adomain.com. IN NS
+    - 300 ns2.adomain.com.
+    + 300 ns4.adomain.com.
+`,
+		"adomain.com. 600 IN NS ns1.adomain.com.\nadomain.com. 600 IN NS ns2.adomain.com.\nadomain.com. 600 IN NS ns4.adomain.com.\n": `Changed:
+  adomain.com. IN NS
+    - 300 ns1.adomain.com.
+    - 300 ns2.adomain.com.
+    - 300 ns3.adomain.com.
+    + 600 ns1.adomain.com.
+    + 600 ns2.adomain.com.
+    + 600 ns4.adomain.com.
+`,
+	}
+
+	for zone, expected := range changed {
+		if d := Diff(readAllRecords(t, old), readAllRecords(t, zone)); d.String() != expected {
+			t.Fatalf("Diff was [%s], expected [%s]", d, expected)
+		}
+	}
+}
+
+func TestDiffIgnoresFormatting(t *testing.T) {
+	reformatted := strings.NewReplacer(" ", "\t", "www 300", "WWW.ADOMAIN.COM. 5m").Replace(diffOldZone)
+	d := Diff(readAllRecords(t, diffOldZone), readAllRecords(t, reformatted))
+	if !d.Empty() {
+		t.Fatalf("Diff of reformatted zone was not empty: [%s]", d)
+	}
+}
+
+func TestDiffIXFR(t *testing.T) {
+	d := Diff(readAllRecords(t, diffOldZone), readAllRecords(t, diffNewZone))
+	sequence, err := d.IXFR()
+	if err != nil {
+		t.Fatalf("Failed to create IXFR sequence: %s", err)
+	}
+
+	expected := []string{
+		"adomain.com. SOA ns.adomain.com. hostmaster.adomain.com. 1 10800 3600 604800 300",
+		`old.adomain.com. TXT "gone"`,
+		"www.adomain.com. A 192.168.0.1",
+		"mail.adomain.com. MX 10 smtp.adomain.com.",
+		"adomain.com. SOA ns.adomain.com. hostmaster.adomain.com. ( 2 3h 1h 1w 300 )",
+		`new.adomain.com. TXT "here"`,
+		"WWW.adomain.com. A 192.168.0.3",
+		"mail.adomain.com. MX 10 smtp.adomain.com.",
+	}
+
+	if recordNames(sequence) != strings.Join(expected, ", ") {
+		t.Fatalf("IXFR sequence was [%s], expected [%s]", recordNames(sequence), strings.Join(expected, ", "))
+	}
+
+	if _, err = Diff(nil, nil).IXFR(); err == nil {
+		t.Fatalf("IXFR without SOA records did not return an error")
+	}
+
+	for _, serial := range []string{"2", "1", "2147483650"} {
+		next := strings.Replace(diffNewZone, "( 2 3h", "( "+serial+" 3h", 1)
+		if _, err = Diff(readAllRecords(t, diffNewZone), readAllRecords(t, next)).IXFR(); err == nil {
+			t.Fatalf("IXFR from serial 2 to %s did not return an error", serial)
+		}
+	}
+}
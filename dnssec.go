@@ -0,0 +1,776 @@
+package gozone
+
+// https://www.ietf.org/rfc/rfc4033.txt
+// https://www.ietf.org/rfc/rfc4034.txt
+// https://www.ietf.org/rfc/rfc4035.txt
+// https://www.ietf.org/rfc/rfc5155.txt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DNSSECAlgorithm_RSASHA256       = 8
+	DNSSECAlgorithm_ECDSAP256SHA256 = 13
+	DNSSECAlgorithm_ECDSAP384SHA384 = 14
+	DNSSECAlgorithm_ED25519         = 15
+)
+
+const (
+	DNSKEYFlag_SEP     = 0x0001 // secure entry point, set for key-signing keys
+	DNSKEYFlag_ZoneKey = 0x0100
+)
+
+const (
+	DSDigest_SHA1   = 1
+	DSDigest_SHA256 = 2
+	DSDigest_SHA384 = 4
+)
+
+const (
+	NSEC3Flag_OptOut = 0x01
+	nsec3HashSHA1    = 1
+)
+
+// SigningKey is a DNSKEY with its private key.
+type SigningKey struct {
+	Owner  string // the apex of the zone the key signs
+	DNSKEY DNSKEY
+	Signer crypto.Signer
+}
+
+// SignOptions control the signatures and denial of existence records made
+// by SignZone.
+type SignOptions struct {
+	Inception  time.Time
+	Expiration time.Time
+	Jitter     time.Duration // each signature expires up to this much earlier, at random, to spread re-signing
+	NSEC3      *NSEC3Options // build an NSEC3 chain, rather than NSEC
+	Rand       io.Reader     // randomness for jitter and signatures; crypto/rand when nil
+}
+
+type NSEC3Options struct {
+	Iterations uint16
+	Salt       []byte
+	OptOut     bool // leave delegations without DS records out of the chain
+}
+
+// NewSigningKey creates a SigningKey for the zone owner, choosing the
+// algorithm from the type of the private key: RSA (with SHA-256), ECDSA with
+// curve P-256 or P-384, or Ed25519. flags should include DNSKEYFlag_ZoneKey,
+// and DNSKEYFlag_SEP for a key-signing key.
+func NewSigningKey(owner string, flags uint16, signer crypto.Signer) (*SigningKey, error) {
+	key := &SigningKey{Owner: owner, Signer: signer, DNSKEY: DNSKEY{Flags: flags, Protocol: 3}}
+
+	switch public := signer.Public().(type) {
+	case *rsa.PublicKey:
+		key.DNSKEY.Algorithm = DNSSECAlgorithm_RSASHA256
+		exponent := big.NewInt(int64(public.E)).Bytes()
+		if len(exponent) < 256 {
+			key.DNSKEY.PublicKey = append([]byte{byte(len(exponent))}, exponent...)
+		} else {
+			key.DNSKEY.PublicKey = append([]byte{0, byte(len(exponent) >> 8), byte(len(exponent))}, exponent...)
+		}
+		key.DNSKEY.PublicKey = append(key.DNSKEY.PublicKey, public.N.Bytes()...)
+	case *ecdsa.PublicKey:
+		size := 0
+		switch public.Curve {
+		case elliptic.P256():
+			key.DNSKEY.Algorithm, size = DNSSECAlgorithm_ECDSAP256SHA256, 32
+		case elliptic.P384():
+			key.DNSKEY.Algorithm, size = DNSSECAlgorithm_ECDSAP384SHA384, 48
+		default:
+			return nil, fmt.Errorf("Unsupported ECDSA curve %s", public.Curve.Params().Name)
+		}
+		key.DNSKEY.PublicKey = make([]byte, 2*size)
+		public.X.FillBytes(key.DNSKEY.PublicKey[:size])
+		public.Y.FillBytes(key.DNSKEY.PublicKey[size:])
+	case ed25519.PublicKey:
+		key.DNSKEY.Algorithm = DNSSECAlgorithm_ED25519
+		key.DNSKEY.PublicKey = append([]byte(nil), public...)
+	default:
+		return nil, fmt.Errorf("Unsupported private key type %T", signer)
+	}
+
+	return key, nil
+}
+
+// KeyTag computes the key tag of RFC 4034 appendix B.
+func (rd *DNSKEY) KeyTag() uint16 {
+	b := &wireBuilder{}
+	_ = b.packDNSSEC(rd)
+
+	var sum uint32
+	for i, c := range b.buf {
+		if i%2 == 0 {
+			sum += uint32(c) << 8
+		} else {
+			sum += uint32(c)
+		}
+	}
+	sum += sum >> 16
+
+	return uint16(sum)
+}
+
+// NewDS creates the DS record data for the DNSKEY of owner, as RFC 4034
+// section 5.1.4.
+func NewDS(owner string, key *DNSKEY, digestType uint8) (*DS, error) {
+	labels, err := nameLabels(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &wireBuilder{buf: canonicalNameWire(labels)}
+	if err = b.packDNSSEC(key); err != nil {
+		return nil, err
+	}
+
+	ds := &DS{KeyTag: key.KeyTag(), Algorithm: key.Algorithm, DigestType: digestType}
+	switch digestType {
+	case DSDigest_SHA1:
+		sum := sha1.Sum(b.buf)
+		ds.Digest = sum[:]
+	case DSDigest_SHA256:
+		ds.Digest = hashData(crypto.SHA256, b.buf)
+	case DSDigest_SHA384:
+		ds.Digest = hashData(crypto.SHA384, b.buf)
+	default:
+		return nil, fmt.Errorf("Unsupported DS digest type %d", digestType)
+	}
+
+	return ds, nil
+}
+
+// publicKey decodes the public key of a DNSKEY
+func (rd *DNSKEY) publicKey() (crypto.PublicKey, error) {
+	key := rd.PublicKey
+	switch rd.Algorithm {
+	case DNSSECAlgorithm_RSASHA256:
+		if len(key) < 3 {
+			return nil, fmt.Errorf("RSA public key is too short")
+		}
+
+		length, key := int(key[0]), key[1:]
+		if length == 0 {
+			length, key = int(key[0])<<8|int(key[1]), key[2:]
+		}
+
+		if length == 0 || length > 8 || length >= len(key) {
+			return nil, fmt.Errorf("RSA public key has a bad exponent")
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(key[length:]),
+			E: int(new(big.Int).SetBytes(key[:length]).Int64()),
+		}, nil
+	case DNSSECAlgorithm_ECDSAP256SHA256, DNSSECAlgorithm_ECDSAP384SHA384:
+		curve := elliptic.P256()
+		if rd.Algorithm == DNSSECAlgorithm_ECDSAP384SHA384 {
+			curve = elliptic.P384()
+		}
+
+		size := (curve.Params().BitSize + 7) / 8
+		if len(key) != 2*size {
+			return nil, fmt.Errorf("ECDSA public key is %d bytes, expected %d", len(key), 2*size)
+		}
+
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(key[:size]),
+			Y:     new(big.Int).SetBytes(key[size:]),
+		}, nil
+	case DNSSECAlgorithm_ED25519:
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Ed25519 public key is %d bytes, expected %d", len(key), ed25519.PublicKeySize)
+		}
+
+		return ed25519.PublicKey(key), nil
+	}
+
+	return nil, fmt.Errorf("Unsupported DNSSEC algorithm %d", rd.Algorithm)
+}
+
+// verify checks a signature over data, made by the private half of key
+func verify(key *DNSKEY, data []byte, signature []byte) error {
+	public, err := key.publicKey()
+	if err != nil {
+		return err
+	}
+
+	valid := false
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(public, crypto.SHA256, hashData(crypto.SHA256, data), signature) == nil
+	case *ecdsa.PublicKey:
+		hash, size := crypto.SHA256, 32
+		if public.Curve == elliptic.P384() {
+			hash, size = crypto.SHA384, 48
+		}
+
+		if len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			valid = ecdsa.Verify(public, hashData(hash, data), r, s)
+		}
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(public, data, signature)
+	}
+
+	if !valid {
+		return fmt.Errorf("Signature does not verify with key %d", key.KeyTag())
+	}
+
+	return nil
+}
+
+// SignZone signs every authoritative RRset of z, adding the DNSKEY records of
+// keys and an NSEC or NSEC3 chain. Where keys include both key-signing keys
+// (with DNSKEYFlag_SEP) and zone-signing keys, the key-signing keys sign
+// only the DNSKEY RRset. The records are only added once every signature
+// has been made, so z is left unchanged when signing fails.
+func SignZone(z *Zone, keys []*SigningKey, options SignOptions) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("No keys to sign zone %s with", z.origin)
+	}
+
+	if options.Inception.IsZero() || options.Expiration.IsZero() {
+		return fmt.Errorf("Signatures need both an inception and an expiration time")
+	}
+
+	if !options.Inception.Before(options.Expiration) {
+		return fmt.Errorf("Signature inception %s is not before expiration %s", options.Inception, options.Expiration)
+	}
+
+	if options.Rand == nil {
+		options.Rand = rand.Reader
+	}
+
+	soa := z.apexSOA()
+	if soa == nil {
+		return fmt.Errorf("Zone %s has no SOA record", z.origin)
+	}
+
+	for _, rrset := range z.rrsets {
+		switch rrset.Type {
+		case RecordType_RRSIG, RecordType_NSEC, RecordType_NSEC3, RecordType_NSEC3PARAM:
+			return fmt.Errorf("Zone %s is already signed: it has %s records", z.origin, rrset.Type)
+		}
+
+		// the TTLs of records are copied into RRSIG, NSEC and NSEC3 records,
+		// which cannot hold the "unset" indicator
+		for _, record := range rrset.Records {
+			if record.TimeToLive < 0 {
+				return fmt.Errorf("%s %s record at %s has no TTL, so cannot be signed: %w", record.DomainName, record.Type, record.Position, ErrInvalidTTL)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		labels, err := nameLabels(key.Owner)
+		if err != nil || labelsKey(labels) != labelsKey(z.labels) {
+			return fmt.Errorf("Key %d belongs to %s, not to zone %s", key.DNSKEY.KeyTag(), key.Owner, z.origin)
+		}
+	}
+
+	// RFC 9077: denial of existence records take the smaller of the SOA TTL and minimum
+	soaData, err := rdataAs[*SOA](soa.Records[0])
+	if err != nil {
+		return err
+	}
+	negativeTTL := soa.Records[0].TimeToLive
+	if minimum := int64(soaData.Minimum); minimum < negativeTTL {
+		negativeTTL = minimum
+	}
+
+	// the chain and signatures are built in a copy of the zone, as they
+	// depend on the records added before them
+	signed := z.clone()
+	apex := signed.nodes[labelsKey(signed.labels)]
+	var dnskeys []Record
+	for _, key := range keys {
+		record := Record{
+			DomainName: z.origin,
+			TimeToLive: soa.Records[0].TimeToLive,
+			Class:      soa.Class,
+			Type:       RecordType_DNSKEY,
+			Data:       presentationFields(key.DNSKEY.String()),
+		}
+
+		if existing := apex.rrset(soa.Class, RecordType_DNSKEY); existing == nil || !containsRData(existing, record) {
+			if err = signed.Add(record); err != nil {
+				return err
+			}
+			dnskeys = append(dnskeys, record)
+		}
+	}
+
+	if options.NSEC3 != nil {
+		err = signed.addNSEC3Chain(soa.Class, negativeTTL, *options.NSEC3)
+	} else {
+		err = signed.addNSECChain(soa.Class, negativeTTL)
+	}
+	if err != nil {
+		return err
+	}
+
+	var ksks, zsks []*SigningKey
+	for _, key := range keys {
+		if key.DNSKEY.Flags&DNSKEYFlag_SEP != 0 {
+			ksks = append(ksks, key)
+		} else {
+			zsks = append(zsks, key)
+		}
+	}
+
+	if len(ksks) == 0 || len(zsks) == 0 {
+		ksks, zsks = keys, keys
+	}
+
+	for _, rrset := range append([]*RRset{}, signed.rrsets...) {
+		if !signed.isSigned(rrset) {
+			continue
+		}
+
+		signers := zsks
+		if rrset.Type == RecordType_DNSKEY {
+			signers = ksks
+		}
+
+		for _, key := range signers {
+			rrsig, err := signRRset(rrset, key, signed.labels, options)
+			if err != nil {
+				return err
+			}
+
+			if err = signed.Add(rrsig); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the zone held no RRSIG, NSEC, NSEC3 or NSEC3PARAM records, so every
+	// one in the copy is new
+	added := dnskeys
+	for _, rrset := range signed.rrsets {
+		switch rrset.Type {
+		case RecordType_RRSIG, RecordType_NSEC, RecordType_NSEC3, RecordType_NSEC3PARAM:
+			added = append(added, rrset.Records...)
+		}
+	}
+
+	for _, record := range added {
+		if err = z.Add(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type nameStatus int
+
+const (
+	nameStatus_Authoritative nameStatus = iota
+	nameStatus_Delegation               // a zone cut, where only DS and NSEC records are authoritative
+	nameStatus_Occluded                 // below a zone cut, such as glue
+	nameStatus_OutOfZone
+)
+
+// status reports how the zone holds the data of a name
+func (z *Zone) status(labels []string) nameStatus {
+	if !isSubdomain(labels, z.labels) {
+		return nameStatus_OutOfZone
+	}
+
+	for i := len(labels) - len(z.labels) - 1; i >= 0; i-- {
+		if !z.hasNS(labels[i:]) {
+			continue
+		}
+
+		if i == 0 {
+			return nameStatus_Delegation
+		}
+		return nameStatus_Occluded
+	}
+
+	return nameStatus_Authoritative
+}
+
+func (z *Zone) hasNS(labels []string) bool {
+	return z.nodes[labelsKey(labels)].hasType(RecordType_NS)
+}
+
+// hasType reports whether the node has an RRset of the type, in any class
+func (n *zoneNode) hasType(rtype RecordType) bool {
+	if n == nil {
+		return false
+	}
+
+	for _, rrset := range n.rrsets {
+		if rrset.Type == rtype {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSigned reports whether an RRset is authoritative data which is signed
+func (z *Zone) isSigned(rrset *RRset) bool {
+	if rrset.Type == RecordType_RRSIG {
+		return false
+	}
+
+	labels, err := nameLabels(rrset.DomainName)
+	if err != nil {
+		return false
+	}
+
+	switch z.status(labels) {
+	case nameStatus_Authoritative:
+		return true
+	case nameStatus_Delegation:
+		return rrset.Type == RecordType_DS || rrset.Type == RecordType_NSEC
+	}
+
+	return false
+}
+
+// chainTypes returns the types of the authoritative RRsets of a node, for
+// the type bitmap of an NSEC or NSEC3 record
+func (z *Zone) chainTypes(node *zoneNode) []RecordType {
+	status := z.status(node.labels)
+	var types []RecordType
+	for _, rrset := range node.rrsets {
+		switch {
+		case rrset.Type == RecordType_NSEC3:
+		case status == nameStatus_Authoritative:
+			types = append(types, rrset.Type)
+		case rrset.Type == RecordType_NS || rrset.Type == RecordType_DS:
+			types = append(types, rrset.Type)
+		}
+	}
+
+	return types
+}
+
+// chainNodes returns the names which take part in a denial of existence
+// chain, in canonical order. The owners of NSEC3 records are not.
+func (z *Zone) chainNodes(includeEmpty bool) []*zoneNode {
+	var nodes []*zoneNode
+	for _, node := range z.nodes {
+		if node.hasType(RecordType_NSEC3) {
+			continue
+		}
+
+		switch z.status(node.labels) {
+		case nameStatus_Authoritative, nameStatus_Delegation:
+			if len(node.rrsets) != 0 || includeEmpty {
+				nodes = append(nodes, node)
+			}
+		}
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return compareLabels(nodes[i].labels, nodes[j].labels) < 0 })
+	return nodes
+}
+
+func (z *Zone) addNSECChain(class RecordClass, ttl int64) error {
+	nodes := z.chainNodes(false)
+	for i, node := range nodes {
+		next := nodes[(i+1)%len(nodes)]
+		types := append(z.chainTypes(node), RecordType_NSEC, RecordType_RRSIG)
+		sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+		nsec := &NSEC{NextDomain: joinLabels(next.labels), Types: types}
+		if err := z.Add(Record{
+			DomainName: joinLabels(node.labels),
+			TimeToLive: ttl,
+			Class:      class,
+			Type:       RecordType_NSEC,
+			Data:       presentationFields(nsec.String()),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (z *Zone) addNSEC3Chain(class RecordClass, ttl int64, options NSEC3Options) error {
+	param := &NSEC3PARAM{HashAlgorithm: nsec3HashSHA1, Iterations: options.Iterations, Salt: options.Salt}
+	if err := z.Add(Record{
+		DomainName: z.origin,
+		TimeToLive: 0,
+		Class:      class,
+		Type:       RecordType_NSEC3PARAM,
+		Data:       presentationFields(param.String()),
+	}); err != nil {
+		return err
+	}
+
+	type hashedNode struct {
+		hash  []byte
+		types []RecordType
+	}
+
+	var hashed []hashedNode
+	for _, node := range z.chainNodes(true) {
+		types := z.chainTypes(node)
+		isDelegation := z.status(node.labels) == nameStatus_Delegation
+		hasDS := node.rrset(class, RecordType_DS) != nil
+		if options.OptOut && isDelegation && !hasDS {
+			continue
+		}
+
+		if len(types) != 0 && (!isDelegation || hasDS) {
+			types = append(types, RecordType_RRSIG)
+		}
+		sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+		hashed = append(hashed, hashedNode{nsec3Hash(node.labels, options.Salt, options.Iterations), types})
+	}
+
+	sort.Slice(hashed, func(i, j int) bool { return bytes.Compare(hashed[i].hash, hashed[j].hash) < 0 })
+
+	var flags uint8
+	if options.OptOut {
+		flags = NSEC3Flag_OptOut
+	}
+
+	for i, node := range hashed {
+		next := hashed[(i+1)%len(hashed)]
+		if i > 0 && bytes.Equal(node.hash, hashed[i-1].hash) {
+			return fmt.Errorf("NSEC3 hash collision in zone %s, choose another salt", z.origin)
+		}
+
+		nsec3 := &NSEC3{
+			HashAlgorithm:   nsec3HashSHA1,
+			Flags:           flags,
+			Iterations:      options.Iterations,
+			Salt:            options.Salt,
+			NextHashedOwner: next.hash,
+			Types:           node.types,
+		}
+
+		if err := z.Add(Record{
+			DomainName: strings.ToLower(nsec3Encoding.EncodeToString(node.hash)) + "." + z.origin,
+			TimeToLive: ttl,
+			Class:      class,
+			Type:       RecordType_NSEC3,
+			Data:       presentationFields(nsec3.String()),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nsec3Hash hashes a name as RFC 5155 section 5
+func nsec3Hash(labels []string, salt []byte, iterations uint16) []byte {
+	name := canonicalNameWire(labels)
+	h := sha1.New()
+	_, _ = h.Write(name)
+	_, _ = h.Write(salt)
+	hash := h.Sum(nil)
+
+	for i := 0; i < int(iterations); i++ {
+		h.Reset()
+		_, _ = h.Write(hash)
+		_, _ = h.Write(salt)
+		hash = h.Sum(hash[:0])
+	}
+
+	return hash
+}
+
+// signRRset creates an RRSIG record over rrset
+func signRRset(rrset *RRset, key *SigningKey, zone []string, options SignOptions) (Record, error) {
+	labels, err := nameLabels(rrset.DomainName)
+	if err != nil {
+		return Record{}, err
+	}
+
+	count := len(labels)
+	if count != 0 && labels[0] == "*" {
+		count--
+	}
+
+	expiration := options.Expiration
+	if options.Jitter > 0 {
+		var random [8]byte
+		if _, err = io.ReadFull(options.Rand, random[:]); err != nil {
+			return Record{}, err
+		}
+		expiration = expiration.Add(-time.Duration(binary.BigEndian.Uint64(random[:]) % uint64(options.Jitter)))
+	}
+
+	rrsig := &RRSIG{
+		TypeCovered: rrset.Type,
+		Algorithm:   key.DNSKEY.Algorithm,
+		Labels:      uint8(count),
+		OriginalTTL: uint32(rrset.Records[0].TimeToLive),
+		Expiration:  uint32(expiration.Unix()),
+		Inception:   uint32(options.Inception.Unix()),
+		KeyTag:      key.DNSKEY.KeyTag(),
+		SignerName:  joinLabels(zone),
+	}
+
+	data, err := signedData(rrsig, rrset)
+	if err != nil {
+		return Record{}, err
+	}
+
+	if rrsig.Signature, err = sign(key, data, options.Rand); err != nil {
+		return Record{}, err
+	}
+
+	return Record{
+		DomainName: rrset.DomainName,
+		TimeToLive: rrset.Records[0].TimeToLive,
+		Class:      rrset.Class,
+		Type:       RecordType_RRSIG,
+		Data:       presentationFields(rrsig.String()),
+	}, nil
+}
+
+// signedData builds the data an RRSIG signs, as RFC 4034 section 3.1.8.1:
+// the RRSIG fields before the signature, then the records of the RRset in
+// canonical form and order
+func signedData(rrsig *RRSIG, rrset *RRset) ([]byte, error) {
+	header := *rrsig
+	header.SignerName = lowerASCII([]byte(rrsig.SignerName))
+
+	b := &wireBuilder{}
+	if err := b.packRRSIGHeader(&header); err != nil {
+		return nil, err
+	}
+
+	labels, err := nameLabels(rrset.DomainName)
+	if err != nil {
+		return nil, err
+	}
+
+	// a wildcard's signature covers its owner without the expanded labels
+	if len(labels) > int(rrsig.Labels) {
+		labels = append([]string{"*"}, labels[len(labels)-int(rrsig.Labels):]...)
+	}
+	owner := canonicalNameWire(labels)
+
+	rdatas, err := canonicalRDatas(rrset.Records)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rdata := range rdatas {
+		b.buf = append(b.buf, owner...)
+		b.buf = binary.BigEndian.AppendUint16(b.buf, uint16(rrset.Type))
+		b.buf = binary.BigEndian.AppendUint16(b.buf, uint16(rrset.Class))
+		b.buf = binary.BigEndian.AppendUint32(b.buf, rrsig.OriginalTTL)
+		b.buf = binary.BigEndian.AppendUint16(b.buf, uint16(len(rdata)))
+		b.buf = append(b.buf, rdata...)
+	}
+
+	return b.buf, nil
+}
+
+func sign(key *SigningKey, data []byte, random io.Reader) ([]byte, error) {
+	var hash crypto.Hash
+	var size int
+	switch key.DNSKEY.Algorithm {
+	case DNSSECAlgorithm_RSASHA256:
+		hash = crypto.SHA256
+	case DNSSECAlgorithm_ECDSAP256SHA256:
+		hash, size = crypto.SHA256, 32
+	case DNSSECAlgorithm_ECDSAP384SHA384:
+		hash, size = crypto.SHA384, 48
+	case DNSSECAlgorithm_ED25519:
+		return key.Signer.Sign(random, data, crypto.Hash(0))
+	default:
+		return nil, fmt.Errorf("Unsupported DNSSEC algorithm %d", key.DNSKEY.Algorithm)
+	}
+
+	digest := hashData(hash, data)
+	signature, err := key.Signer.Sign(random, digest, hash)
+	if err != nil || size == 0 {
+		return signature, err
+	}
+
+	// ECDSA signatures are r and s, each padded to the curve size (RFC 6605)
+	var rs struct{ R, S *big.Int }
+	if _, err = asn1.Unmarshal(signature, &rs); err != nil {
+		return nil, err
+	}
+
+	signature = make([]byte, 2*size)
+	rs.R.FillBytes(signature[:size])
+	rs.S.FillBytes(signature[size:])
+	return signature, nil
+}
+
+func hashData(hash crypto.Hash, data []byte) []byte {
+	switch hash {
+	case crypto.SHA256:
+		sum := sha256.Sum256(data)
+		return sum[:]
+	case crypto.SHA384:
+		sum := sha512.Sum384(data)
+		return sum[:]
+	}
+
+	sum := sha512.Sum512(data)
+	return sum[:]
+}
+
+// canonicalRDatas returns the data of records in the canonical form of RFC
+// 4034 section 6.2, sorted as section 6.3 and without duplicates
+func canonicalRDatas(records []Record) ([][]byte, error) {
+	var rdatas [][]byte
+	for _, record := range records {
+		rdata, err := record.CanonicalRData()
+		if err != nil {
+			return nil, err
+		}
+		rdatas = append(rdatas, rdata)
+	}
+
+	sort.Slice(rdatas, func(i, j int) bool { return bytes.Compare(rdatas[i], rdatas[j]) < 0 })
+
+	unique := rdatas[:0]
+	for i, rdata := range rdatas {
+		if i == 0 || !bytes.Equal(rdata, rdatas[i-1]) {
+			unique = append(unique, rdata)
+		}
+	}
+
+	return unique, nil
+}
+
+func canonicalNameWire(labels []string) []byte {
+	var wire []byte
+	for _, label := range labels {
+		wire = append(wire, byte(len(label)))
+		wire = append(wire, lowerASCII([]byte(label))...)
+	}
+
+	return append(wire, 0)
+}
@@ -0,0 +1,479 @@
+package gozone
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+const signingZone = `$ORIGIN example.com.
+$TTL 3600
+@         IN SOA  ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300
+@         IN NS   ns1.example.com.
+ns1       IN A    192.0.2.1
+www       IN A    192.0.2.2
+www       IN A    192.0.2.3
+a.b.c     IN TXT  "deep"
+*.wild    IN MX   10 mail.example.com.
+sub       IN NS   ns.sub.example.com.
+ns.sub    IN A    192.0.2.4
+secure    IN NS   ns1.example.com.
+secure    IN DS   1 13 2 ABCD
+`
+
+var signOptions = SignOptions{
+	Inception:  time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC),
+	Expiration: time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC),
+}
+
+func loadSigningZone(t *testing.T) *Zone {
+	t.Helper()
+
+	z, err := LoadZone(NewScanner(strings.NewReader(signingZone)))
+	if err != nil {
+		t.Fatalf("Failed to load zone: %s", err)
+	}
+
+	return z
+}
+
+func newSigningKey(t *testing.T, flags uint16, signer crypto.Signer, err error) *SigningKey {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+
+	key, err := NewSigningKey("example.com.", flags, signer)
+	if err != nil {
+		t.Fatalf("Failed to create signing key: %s", err)
+	}
+
+	return key
+}
+
+// verifyRRSIG checks the signature of an RRSIG record over rrset
+func verifyRRSIG(t *testing.T, rrset *RRset, record Record, public crypto.PublicKey) {
+	t.Helper()
+
+	rdata, err := record.RData()
+	if err != nil {
+		t.Fatalf("Failed to parse RRSIG for %s %s: %s", rrset.DomainName, rrset.Type, err)
+	}
+	rrsig := rdata.(*RRSIG)
+
+	data, err := signedData(rrsig, rrset)
+	if err != nil {
+		t.Fatalf("Failed to build signed data for %s %s: %s", rrset.DomainName, rrset.Type, err)
+	}
+
+	valid := false
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(public, crypto.SHA256, hashData(crypto.SHA256, data), rrsig.Signature) == nil
+	case *ecdsa.PublicKey:
+		hash := crypto.SHA256
+		if public.Curve == elliptic.P384() {
+			hash = crypto.SHA384
+		}
+		size := len(rrsig.Signature) / 2
+		r := new(big.Int).SetBytes(rrsig.Signature[:size])
+		s := new(big.Int).SetBytes(rrsig.Signature[size:])
+		valid = ecdsa.Verify(public, hashData(hash, data), r, s)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(public, data, rrsig.Signature)
+	}
+
+	if !valid {
+		t.Fatalf("RRSIG for %s %s does not verify", rrset.DomainName, rrset.Type)
+	}
+}
+
+// signatures returns the RRSIG records covering each type at a name
+func signatures(z *Zone, name string) map[RecordType][]Record {
+	covered := make(map[RecordType][]Record)
+	rrsigs := z.RRset(name, RecordClass_IN, RecordType_RRSIG)
+	if rrsigs == nil {
+		return covered
+	}
+
+	for _, record := range rrsigs.Records {
+		rdata, _ := record.RData()
+		rrsig := rdata.(*RRSIG)
+		covered[rrsig.TypeCovered] = append(covered[rrsig.TypeCovered], record)
+	}
+
+	return covered
+}
+
+func TestKeyTag(t *testing.T) {
+	// RFC 8080 section 6.1
+	key, err := Record{Type: RecordType_DNSKEY, Data: []string{"257", "3", "15", "l02Woi0iS8Aa25FQkUd9RMzZHJpBoRQwAQEX1SxZJA4="}}.RData()
+	if err != nil {
+		t.Fatalf("Failed to parse DNSKEY: %s", err)
+	}
+
+	if tag := key.(*DNSKEY).KeyTag(); tag != 3613 {
+		t.Fatalf("Key tag was %d, expected 3613", tag)
+	}
+}
+
+func TestSignZoneAlgorithms(t *testing.T) {
+	rsaKey, rsaErr := rsa.GenerateKey(rand.Reader, 2048)
+	p256Key, p256Err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384Key, p384Err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	_, ed25519Key, ed25519Err := ed25519.GenerateKey(rand.Reader)
+
+	keys := []*SigningKey{
+		newSigningKey(t, DNSKEYFlag_ZoneKey, rsaKey, rsaErr),
+		newSigningKey(t, DNSKEYFlag_ZoneKey, p256Key, p256Err),
+		newSigningKey(t, DNSKEYFlag_ZoneKey, p384Key, p384Err),
+		newSigningKey(t, DNSKEYFlag_ZoneKey, ed25519Key, ed25519Err),
+	}
+
+	expectedAlgorithms := []uint8{DNSSECAlgorithm_RSASHA256, DNSSECAlgorithm_ECDSAP256SHA256, DNSSECAlgorithm_ECDSAP384SHA384, DNSSECAlgorithm_ED25519}
+	for i, key := range keys {
+		if key.DNSKEY.Algorithm != expectedAlgorithms[i] {
+			t.Fatalf("Key %d has algorithm %d, expected %d", i, key.DNSKEY.Algorithm, expectedAlgorithms[i])
+		}
+
+		z := loadSigningZone(t)
+		if err := SignZone(z, []*SigningKey{key}, signOptions); err != nil {
+			t.Fatalf("Failed to sign zone with algorithm %d: %s", key.DNSKEY.Algorithm, err)
+		}
+
+		for _, rrset := range z.RRsets() {
+			if rrset.Type == RecordType_RRSIG {
+				continue
+			}
+
+			for _, rrsig := range signatures(z, rrset.DomainName)[rrset.Type] {
+				verifyRRSIG(t, rrset, rrsig, key.Signer.Public())
+			}
+		}
+	}
+}
+
+func TestSignZoneNSEC(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	key := newSigningKey(t, DNSKEYFlag_ZoneKey, private, err)
+
+	z := loadSigningZone(t)
+	if err = SignZone(z, []*SigningKey{key}, signOptions); err != nil {
+		t.Fatalf("Failed to sign zone: %s", err)
+	}
+
+	chain := []struct {
+		owner string
+		nsec  string
+	}{
+		{"example.com.", "a.b.c.example.com. NS SOA RRSIG NSEC DNSKEY"},
+		{"a.b.c.example.com.", "ns1.example.com. TXT RRSIG NSEC"},
+		{"ns1.example.com.", "secure.example.com. A RRSIG NSEC"},
+		{"secure.example.com.", "sub.example.com. NS DS RRSIG NSEC"},
+		{"sub.example.com.", "*.wild.example.com. NS RRSIG NSEC"},
+		{"*.wild.example.com.", "www.example.com. MX RRSIG NSEC"},
+		{"www.example.com.", "example.com. A RRSIG NSEC"},
+	}
+
+	for _, link := range chain {
+		nsec := z.RRset(link.owner, RecordClass_IN, RecordType_NSEC)
+		if nsec == nil {
+			t.Fatalf("%s has no NSEC record", link.owner)
+		}
+
+		if data := strings.Join(nsec.Records[0].Data, " "); data != link.nsec || nsec.Records[0].TimeToLive != 300 {
+			t.Fatalf("%s has NSEC %d %q, expected 300 %q", link.owner, nsec.Records[0].TimeToLive, data, link.nsec)
+		}
+	}
+
+	if rrset := z.RRset("ns.sub.example.com.", RecordClass_IN, RecordType_NSEC); rrset != nil {
+		t.Fatalf("Glue below a zone cut has an NSEC record")
+	}
+
+	unsigned := []struct {
+		owner string
+		rtype RecordType
+	}{
+		{"sub.example.com.", RecordType_NS},
+		{"secure.example.com.", RecordType_NS},
+		{"ns.sub.example.com.", RecordType_A},
+	}
+
+	for _, u := range unsigned {
+		if len(signatures(z, u.owner)[u.rtype]) != 0 {
+			t.Fatalf("%s %s should not be signed", u.owner, u.rtype)
+		}
+	}
+
+	signed := []struct {
+		owner  string
+		rtype  RecordType
+		labels uint8
+	}{
+		{"example.com.", RecordType_SOA, 2},
+		{"example.com.", RecordType_DNSKEY, 2},
+		{"www.example.com.", RecordType_A, 3},
+		{"secure.example.com.", RecordType_DS, 3},
+		{"sub.example.com.", RecordType_NSEC, 3},
+		{"*.wild.example.com.", RecordType_MX, 3},
+	}
+
+	for _, s := range signed {
+		rrsigs := signatures(z, s.owner)[s.rtype]
+		if len(rrsigs) != 1 {
+			t.Fatalf("%s %s has %d signatures, expected 1", s.owner, s.rtype, len(rrsigs))
+		}
+
+		rdata, _ := rrsigs[0].RData()
+		if labels := rdata.(*RRSIG).Labels; labels != s.labels {
+			t.Fatalf("%s %s RRSIG has %d labels, expected %d", s.owner, s.rtype, labels, s.labels)
+		}
+
+		verifyRRSIG(t, z.RRset(s.owner, RecordClass_IN, s.rtype), rrsigs[0], key.Signer.Public())
+	}
+}
+
+func TestSignZoneNSEC3(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	key := newSigningKey(t, DNSKEYFlag_ZoneKey, private, err)
+
+	options := signOptions
+	options.NSEC3 = &NSEC3Options{Iterations: 1, Salt: []byte{0xaa, 0xbb}, OptOut: true}
+
+	z := loadSigningZone(t)
+	if err = SignZone(z, []*SigningKey{key}, options); err != nil {
+		t.Fatalf("Failed to sign zone: %s", err)
+	}
+
+	param := z.RRset("example.com.", RecordClass_IN, RecordType_NSEC3PARAM)
+	if param == nil || strings.Join(param.Records[0].Data, " ") != "1 0 1 AABB" || param.Records[0].TimeToLive != 0 {
+		t.Fatalf("Zone has NSEC3PARAM %v, expected 0 \"1 0 1 AABB\"", param)
+	}
+
+	// the insecure delegation sub is opted out, but the empty non-terminals b.c and c are in the chain
+	expected := map[string]string{
+		"example.com.":        "NS SOA RRSIG DNSKEY NSEC3PARAM",
+		"a.b.c.example.com.":  "TXT RRSIG",
+		"b.c.example.com.":    "",
+		"c.example.com.":      "",
+		"ns1.example.com.":    "A RRSIG",
+		"secure.example.com.": "NS DS RRSIG",
+		"*.wild.example.com.": "MX RRSIG",
+		"wild.example.com.":   "",
+		"www.example.com.":    "A RRSIG",
+	}
+
+	nsec3s := 0
+	for _, rrset := range z.RRsets() {
+		if rrset.Type != RecordType_NSEC3 {
+			continue
+		}
+		nsec3s++
+
+		if len(signatures(z, rrset.DomainName)[RecordType_NSEC3]) != 1 {
+			t.Fatalf("NSEC3 record at %s is not signed", rrset.DomainName)
+		}
+	}
+
+	if nsec3s != len(expected) {
+		t.Fatalf("Zone has %d NSEC3 records, expected %d", nsec3s, len(expected))
+	}
+
+	for name, types := range expected {
+		labels, _ := nameLabels(name)
+		hashed := strings.ToLower(nsec3Encoding.EncodeToString(nsec3Hash(labels, options.NSEC3.Salt, 1))) + ".example.com."
+		nsec3 := z.RRset(hashed, RecordClass_IN, RecordType_NSEC3)
+		if nsec3 == nil {
+			t.Fatalf("%s has no NSEC3 record at %s", name, hashed)
+		}
+
+		if got := strings.Join(nsec3.Records[0].Data[5:], " "); got != types || nsec3.Records[0].Data[1] != "1" {
+			t.Fatalf("%s has NSEC3 %v, expected opt-out and types %q", name, nsec3.Records[0].Data, types)
+		}
+	}
+}
+
+func TestNSEC3Hash(t *testing.T) {
+	// RFC 5155 appendix A
+	salt, _ := hex.DecodeString("aabbccdd")
+	hashes := map[string]string{
+		"example.":      "0p9mhaveqvm6t7vbl5lop2u3t2rp3tom",
+		"a.example.":    "35mthgpgcu1qg68fab165klnsnk3dpvl",
+		"ai.example.":   "gjeqe526plbf1g8mklp59enfd789njgi",
+		"*.w.example.":  "r53bq7cc2uvmubfu5ocmm6pers9tk9en",
+		"xx.example.":   "t644ebqk9bibcna874givr6joj62mlhv",
+		"ns1.example.":  "2t7b4g4vsa5smi47k61mv5bv1a22bojr",
+		"w.example.":    "k8udemvp1j2f7eg6jebps17vp3n8i58h",
+		"x.w.example.":  "b4um86eghhds6nea196smvmlo4ors995",
+		"y.w.example.":  "ji6neoaepv8b5o6k4ev33abha8ht9fgc",
+		"x.y.w.example": "2vptu5timamqttgl4luu9kg21e0aor3s",
+	}
+
+	for name, expected := range hashes {
+		labels, err := nameLabels(strings.TrimSuffix(name, ".") + ".")
+		if err != nil {
+			t.Fatalf("Failed to split %s: %s", name, err)
+		}
+
+		if hash := strings.ToLower(nsec3Encoding.EncodeToString(nsec3Hash(labels, salt, 12))); hash != expected {
+			t.Fatalf("NSEC3 hash of %s was %s, expected %s", name, hash, expected)
+		}
+	}
+}
+
+func TestSignZoneKeyRoles(t *testing.T) {
+	_, kskPrivate, kskErr := ed25519.GenerateKey(rand.Reader)
+	_, zskPrivate, zskErr := ed25519.GenerateKey(rand.Reader)
+	ksk := newSigningKey(t, DNSKEYFlag_ZoneKey|DNSKEYFlag_SEP, kskPrivate, kskErr)
+	zsk := newSigningKey(t, DNSKEYFlag_ZoneKey, zskPrivate, zskErr)
+
+	options := signOptions
+	options.Jitter = 24 * time.Hour
+
+	z := loadSigningZone(t)
+	if err := SignZone(z, []*SigningKey{ksk, zsk}, options); err != nil {
+		t.Fatalf("Failed to sign zone: %s", err)
+	}
+
+	if dnskeys := z.RRset("example.com.", RecordClass_IN, RecordType_DNSKEY); dnskeys == nil || len(dnskeys.Records) != 2 {
+		t.Fatalf("Zone should have two DNSKEY records, has %v", dnskeys)
+	}
+
+	covered := signatures(z, "example.com.")
+	for rtype, rrsigs := range covered {
+		expected := zsk
+		if rtype == RecordType_DNSKEY {
+			expected = ksk
+		}
+
+		if len(rrsigs) != 1 {
+			t.Fatalf("%s has %d signatures, expected 1", rtype, len(rrsigs))
+		}
+
+		rdata, _ := rrsigs[0].RData()
+		rrsig := rdata.(*RRSIG)
+		if rrsig.KeyTag != expected.DNSKEY.KeyTag() {
+			t.Fatalf("%s is signed by key %d, expected %d", rtype, rrsig.KeyTag, expected.DNSKEY.KeyTag())
+		}
+
+		expiration := time.Unix(int64(rrsig.Expiration), 0)
+		if expiration.After(options.Expiration) || !expiration.After(options.Expiration.Add(-options.Jitter)) {
+			t.Fatalf("%s signature expires at %s, outside the jitter window", rtype, expiration)
+		}
+
+		verifyRRSIG(t, z.RRset("example.com.", RecordClass_IN, rtype), rrsigs[0], expected.Signer.Public())
+	}
+}
+
+func TestSignZoneErrors(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	key := newSigningKey(t, DNSKEYFlag_ZoneKey, private, err)
+
+	z := loadSigningZone(t)
+	if err = SignZone(z, nil, signOptions); err == nil {
+		t.Fatalf("Signing without keys should have failed")
+	}
+
+	other, _ := NewSigningKey("example.net.", DNSKEYFlag_ZoneKey, private)
+	if err = SignZone(z, []*SigningKey{other}, signOptions); err == nil {
+		t.Fatalf("Signing with another zone's key should have failed")
+	}
+
+	if err = SignZone(z, []*SigningKey{key}, signOptions); err != nil {
+		t.Fatalf("Failed to sign zone: %s", err)
+	}
+
+	if err = SignZone(z, []*SigningKey{key}, signOptions); err == nil {
+		t.Fatalf("Signing a signed zone should have failed")
+	}
+
+	// only the SOA has no TTL
+	zone := strings.Replace(signingZone, "$TTL 3600\n", "", 1)
+	zone = strings.Replace(zone, "@         IN NS", "$TTL 3600\n@         IN NS", 1)
+	z, err = LoadZone(NewScanner(strings.NewReader(zone)))
+	if err != nil {
+		t.Fatalf("Failed to load zone: %s", err)
+	}
+
+	if err = SignZone(z, []*SigningKey{key}, signOptions); !errors.Is(err, ErrInvalidTTL) {
+		t.Fatalf("Signing a zone whose SOA has no TTL did not return ErrInvalidTTL: %v", err)
+	}
+
+	z = loadSigningZone(t)
+	if err = z.Add(Record{DomainName: "txt.example.com.", TimeToLive: -1, Class: RecordClass_IN, Type: RecordType_TXT, Data: []string{"x"}}); err != nil {
+		t.Fatalf("Failed to add record: %s", err)
+	}
+
+	if err = SignZone(z, []*SigningKey{key}, signOptions); !errors.Is(err, ErrInvalidTTL) {
+		t.Fatalf("Signing a zone with a record without a TTL did not return ErrInvalidTTL: %v", err)
+	}
+
+	z = loadSigningZone(t)
+	for _, options := range []SignOptions{
+		{},
+		{Inception: signOptions.Inception},
+		{Inception: signOptions.Expiration, Expiration: signOptions.Inception},
+		{Inception: signOptions.Inception, Expiration: signOptions.Inception},
+	} {
+		if err = SignZone(z, []*SigningKey{key}, options); err == nil {
+			t.Fatalf("Signing with inception %s and expiration %s should have failed", options.Inception, options.Expiration)
+		}
+	}
+}
+
+func TestSignZoneFailureLeavesZoneUnchanged(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	key := newSigningKey(t, DNSKEYFlag_ZoneKey, private, err)
+
+	z := loadSigningZone(t)
+	before := len(z.RRsets())
+
+	// jitter reads from Rand for every signature
+	options := signOptions
+	options.Jitter = time.Hour
+	options.Rand = iotest.ErrReader(errors.New("no randomness"))
+	for _, nsec3 := range []*NSEC3Options{nil, {Iterations: 1}} {
+		options.NSEC3 = nsec3
+		if err = SignZone(z, []*SigningKey{key}, options); err == nil {
+			t.Fatalf("Signing without randomness should have failed")
+		}
+
+		if len(z.RRsets()) != before {
+			t.Fatalf("Failed signing left the zone with %d RRsets, expected %d", len(z.RRsets()), before)
+		}
+	}
+
+	if err = SignZone(z, []*SigningKey{key}, signOptions); err != nil {
+		t.Fatalf("Failed to sign zone after a failed attempt: %s", err)
+	}
+}
+
+func TestSignZoneGenericSOA(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	key := newSigningKey(t, DNSKEYFlag_ZoneKey, private, err)
+
+	z := loadSigningZone(t)
+	soa := &z.apexSOA().Records[0]
+	data, err := soa.CanonicalRData()
+	if err != nil {
+		t.Fatalf("Failed to write SOA record data: %s", err)
+	}
+	soa.Data = []string{`\#`, strconv.Itoa(len(data)), fmt.Sprintf("%X", data)}
+
+	if err = SignZone(z, []*SigningKey{key}, signOptions); err != nil {
+		t.Fatalf("Failed to sign a zone with its SOA record in the generic form: %s", err)
+	}
+}
@@ -0,0 +1,100 @@
+package gozone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Position is a location within a zone file. Lines and columns are counted
+// from 1, with columns counted in runes.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Categories of ParseError, for use with errors.Is
+var (
+	ErrUnexpectedEOF       = errors.New("unexpected end of input")
+	ErrIncompleteRecord    = errors.New("incomplete record")
+	ErrUnknownType         = errors.New("unknown record type")
+	ErrNoOrigin            = errors.New("relative domain without $ORIGIN")
+	ErrNoPreviousDomain    = errors.New("no previous domain to inherit")
+	ErrInvalidName         = errors.New("invalid domain name")
+	ErrInvalidTTL          = errors.New("invalid time-to-live")
+	ErrInvalidData         = errors.New("invalid record data")
+	ErrUnknownControlEntry = errors.New("unknown control entry")
+	ErrInvalidControlEntry = errors.New("invalid control entry")
+	ErrInclude             = errors.New("failed to include file")
+	ErrIncludeCycle        = errors.New("$INCLUDE cycle")
+	ErrIncludeDepth        = errors.New("$INCLUDE nested too deeply")
+)
+
+// ParseError describes a problem found by Scanner.Next, and where it was
+// found. Kind is one of the Err* categories above; Err, when set, is the
+// underlying error which caused the problem.
+type ParseError struct {
+	Position
+	Token   string
+	Kind    error
+	Message string
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	if e.Token == "" || e.Token == "\n" || strings.Contains(e.Message, e.Token) {
+		return fmt.Sprintf("%s: %s", e.Position, e.Message)
+	}
+
+	return fmt.Sprintf("%s: %s (at '%s')", e.Position, e.Message, e.Token)
+}
+
+func (e *ParseError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+
+	return []error{e.Kind, e.Err}
+}
+
+// errorf returns a ParseError positioned at the most recent token
+func (s *Scanner) errorf(kind error, token string, format string, args ...interface{}) error {
+	return &ParseError{
+		Position: s.tokenPosition,
+		Token:    token,
+		Kind:     kind,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// wrapError returns a ParseError positioned at the most recent token, caused
+// by err
+func (s *Scanner) wrapError(kind error, token string, err error) error {
+	return &ParseError{
+		Position: s.tokenPosition,
+		Token:    token,
+		Kind:     kind,
+		Message:  err.Error(),
+		Err:      err,
+	}
+}
+
+// wrapRecordError returns a ParseError positioned at the start of record,
+// caused by err
+func wrapRecordError(kind error, record Record, err error) error {
+	return &ParseError{
+		Position: record.Position,
+		Kind:     kind,
+		Message:  err.Error(),
+		Err:      err,
+	}
+}
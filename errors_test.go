@@ -0,0 +1,137 @@
+package gozone
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRecordPosition(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("; a comment\n\nadomain.com. 300 IN A 192.168.1.1\n  300 IN SOA ns.ahostdomain.com. hostmaster.ahostdomain.com. (\n 1 2 3 4 5 )\nftp.adomain.com. 300 IN A 192.168.1.2\n"))
+	s.SetFileName("adomain.zone")
+
+	expected := []Position{
+		Position{File: "adomain.zone", Line: 3, Column: 1},
+		Position{File: "adomain.zone", Line: 4, Column: 3},
+		Position{File: "adomain.zone", Line: 6, Column: 1},
+	}
+
+	for _, position := range expected {
+		if err := s.Next(&r); err != nil {
+			t.Fatalf("Unexpected error when parsing: %s", err)
+		}
+
+		if r.Position != position {
+			t.Fatalf("Record '%s' has Position %s, expected %s", r.DomainName, r.Position, position)
+		}
+	}
+}
+
+func TestIncludedRecordPosition(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$INCLUDE hosts.zone\nftp.adomain.com. 300 IN A 192.168.1.2\n"))
+	s.SetFileName("adomain.zone")
+	s.SetIncludeResolver(IncludeFS(fstest.MapFS{
+		"hosts.zone": &fstest.MapFile{Data: []byte("\nwww.adomain.com. 300 IN A 192.168.1.1\n")},
+	}))
+
+	expected := []Position{
+		Position{File: "hosts.zone", Line: 2, Column: 1},
+		Position{File: "adomain.zone", Line: 2, Column: 1},
+	}
+
+	for _, position := range expected {
+		if err := s.Next(&r); err != nil {
+			t.Fatalf("Unexpected error when parsing: %s", err)
+		}
+
+		if r.Position != position {
+			t.Fatalf("Record '%s' has Position %s, expected %s", r.DomainName, r.Position, position)
+		}
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("adomain.com. 300 IN A 192.168.1.1\nwww.adomain.com. 300 IN FAKE 192.168.1.1\n"))
+	s.SetFileName("adomain.zone")
+
+	if err := s.Next(&r); err != nil {
+		t.Fatalf("Unexpected error when parsing: %s", err)
+	}
+
+	err := s.Next(&r)
+	var parseError *ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatalf("Parsing of bad-type record did not return a ParseError: %v", err)
+	}
+
+	if parseError.Position != (Position{File: "adomain.zone", Line: 2, Column: 25}) {
+		t.Fatalf("ParseError has unexpected Position %s", parseError.Position)
+	}
+
+	if parseError.Token != "FAKE" {
+		t.Fatalf("ParseError has unexpected Token '%s'", parseError.Token)
+	}
+
+	if !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("ParseError for bad-type record is not ErrUnknownType")
+	}
+
+	if !strings.HasPrefix(err.Error(), "adomain.zone:2:25: ") {
+		t.Fatalf("ParseError message does not start with the position: %s", err)
+	}
+}
+
+func TestParseErrorKinds(t *testing.T) {
+	check := map[string]error{
+		"adomain.com. 300 IN A \n":                   ErrIncompleteRecord,
+		"adomain.com. 300 IN A":                      ErrIncompleteRecord,
+		"adomain.com. 300 IN\n":                      ErrIncompleteRecord,
+		"adomain.com. 300 IN FAKE 192.168.1.1":       ErrUnknownType,
+		"www 300 IN A 192.168.1.1":                   ErrNoOrigin,
+		" 300 IN A 192.168.1.1":                      ErrNoPreviousDomain,
+		"adomain.com. 300 IN SOA ( 1271271271":       ErrUnexpectedEOF,
+		"$TTL 1x\n":                                  ErrInvalidTTL,
+		"$ORIGIN\n":                                  ErrInvalidControlEntry,
+		"$UNKNOWN\n":                                 ErrUnknownControlEntry,
+		"$INCLUDE hosts.zone\n":                      ErrInclude,
+		"adomain.com. 300 IN TYPE731 \\# 2 0A0000\n": ErrInvalidData,
+	}
+
+	for spec, kind := range check {
+		var r Record
+		s := NewScanner(strings.NewReader(spec))
+		err := s.Next(&r)
+		if !errors.Is(err, kind) {
+			t.Fatalf("Parsing of [%s] returned [%v], expected an error matching [%s]", spec, err, kind)
+		}
+	}
+}
+
+func TestParseErrorUnwrapsCause(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$INCLUDE missing.zone\n"))
+	s.SetIncludeResolver(IncludeFS(fstest.MapFS{}))
+	err := s.Next(&r)
+
+	if !errors.Is(err, ErrInclude) || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("ParseError for missing $INCLUDE file does not match both its Kind and its cause: %v", err)
+	}
+}
+
+func TestEndOfFileIsNotParseError(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("adomain.com. 300 IN A 192.168.1.1\n"))
+	if err := s.Next(&r); err != nil {
+		t.Fatalf("Unexpected error when parsing: %s", err)
+	}
+
+	if err := s.Next(&r); err != io.EOF {
+		t.Fatalf("End of input did not return a bare io.EOF: %v", err)
+	}
+}
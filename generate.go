@@ -0,0 +1,398 @@
+package gozone
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// a piece of a $GENERATE template: either literal text, or a substitution of
+// the iterator (when base is non-zero)
+type generatePart struct {
+	literal string
+	offset  int64
+	width   int
+	base    byte
+}
+
+type generateTemplate []generatePart
+
+// generator holds the state of a $GENERATE control entry, so that its
+// records can be returned one at a time by Scanner.Next
+type generator struct {
+	current    int64
+	stop       int64
+	step       int64
+	owner      generateTemplate
+	origin     string
+	timeToLive int64
+	class      RecordClass
+	rtype      RecordType
+	data       []generateTemplate
+	position   Position
+}
+
+func (s *Scanner) scanControlEntryGenerate() error {
+	var tokens []string
+	var token string
+	var err error
+
+	position := s.tokenPosition
+
+	for {
+		if token, err = s.nextToken(); err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			return err
+		}
+
+		if token == "\n" {
+			break
+		}
+
+		if token[0] == ';' {
+			continue
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	if len(tokens) < 4 {
+		return s.errorf(ErrInvalidControlEntry, token, "Incomplete $GENERATE control entry")
+	}
+
+	g := generator{
+		origin:     s.origin,
+		timeToLive: s.timeToLive,
+		position:   position,
+	}
+
+	if g.current, g.stop, g.step, err = parseGenerateRange(tokens[0]); err != nil {
+		return s.wrapError(ErrInvalidControlEntry, tokens[0], err)
+	}
+
+	if g.owner, err = parseGenerateTemplate(tokens[1]); err != nil {
+		return s.wrapError(ErrInvalidControlEntry, tokens[1], err)
+	}
+
+	var hasTTL bool
+	var hasClass bool
+	rest := tokens[2:]
+	for len(rest) != 0 {
+		token = rest[0]
+		rest = rest[1:]
+
+		if !hasTTL {
+			var ttl uint32
+			if ttl, err = ParseTTL(token); err == nil {
+				g.timeToLive = int64(ttl)
+				hasTTL = true
+				continue
+			}
+		}
+
+		if !hasClass {
+			if g.class, err = parseClass(token); err == nil {
+				hasClass = true
+				continue
+			}
+		}
+
+		if g.rtype, err = parseType(token); err != nil {
+			return s.wrapError(ErrUnknownType, token, err)
+		}
+		break
+	}
+
+	if g.rtype == RecordType_UNKNOWN || len(rest) == 0 {
+		return s.errorf(ErrIncompleteRecord, token, "missing data part in $GENERATE control entry")
+	}
+
+	for _, token = range rest {
+		var template generateTemplate
+		if template, err = parseGenerateTemplate(token); err != nil {
+			return s.wrapError(ErrInvalidControlEntry, token, err)
+		}
+		g.data = append(g.data, template)
+	}
+
+	if g.origin == "" {
+		owner := g.owner[len(g.owner)-1]
+		if owner.base != 0 || owner.literal[len(owner.literal)-1] != '.' {
+			return s.errorf(ErrNoOrigin, tokens[1], "$GENERATE of relative domain specified when no $ORIGIN defined")
+		}
+	}
+
+	s.generate = &g
+	return nil
+}
+
+// parseGenerateRange parses a range of the form "start-stop[/step]"
+func parseGenerateRange(token string) (int64, int64, int64, error) {
+	var step uint64 = 1
+	var err error
+
+	bounds := token
+	if i := strings.IndexByte(token, '/'); i != -1 {
+		bounds = token[:i]
+		if step, err = strconv.ParseUint(token[i+1:], 10, 32); err != nil || step == 0 {
+			return 0, 0, 0, fmt.Errorf("Invalid step in $GENERATE range '%s'", token)
+		}
+	}
+
+	i := strings.IndexByte(bounds, '-')
+	if i == -1 {
+		return 0, 0, 0, fmt.Errorf("Invalid $GENERATE range '%s'", token)
+	}
+
+	start, err := strconv.ParseUint(bounds[:i], 10, 32)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("Invalid start of $GENERATE range '%s'", token)
+	}
+
+	stop, err := strconv.ParseUint(bounds[i+1:], 10, 32)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("Invalid end of $GENERATE range '%s'", token)
+	}
+
+	if start > stop {
+		return 0, 0, 0, fmt.Errorf("$GENERATE range '%s' ends before it starts", token)
+	}
+
+	return int64(start), int64(stop), int64(step), nil
+}
+
+// parseGenerateTemplate splits a $GENERATE owner or data token into literal
+// text and substitutions of the iterator. "$" is replaced by the iterator,
+// "${offset[,width[,base]]}" by a modified iterator, and "$$" or "\$" by a
+// literal "$".
+func parseGenerateTemplate(token string) (generateTemplate, error) {
+	var template generateTemplate
+	var literal strings.Builder
+
+	flush := func() {
+		if literal.Len() != 0 {
+			template = append(template, generatePart{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(token); i++ {
+		c := token[i]
+		if c == '\\' && i+1 < len(token) {
+			i++
+			if token[i] != '$' {
+				_ = literal.WriteByte('\\')
+			}
+			_ = literal.WriteByte(token[i])
+			continue
+		}
+
+		if c != '$' {
+			_ = literal.WriteByte(c)
+			continue
+		}
+
+		if i+1 < len(token) && token[i+1] == '$' {
+			i++
+			_ = literal.WriteByte('$')
+			continue
+		}
+
+		flush()
+		if i+1 >= len(token) || token[i+1] != '{' {
+			template = append(template, generatePart{base: 'd'})
+			continue
+		}
+
+		end := strings.IndexByte(token[i:], '}')
+		if end == -1 {
+			return nil, fmt.Errorf("Unterminated modifier in $GENERATE template '%s'", token)
+		}
+
+		part, err := parseGenerateModifier(token[i+2 : i+end])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid modifier in $GENERATE template '%s': %s", token, err)
+		}
+
+		template = append(template, part)
+		i += end
+	}
+	flush()
+
+	return template, nil
+}
+
+func parseGenerateModifier(modifier string) (generatePart, error) {
+	part := generatePart{base: 'd'}
+	fields := strings.Split(modifier, ",")
+	if len(fields) > 3 {
+		return part, fmt.Errorf("too many fields")
+	}
+
+	var err error
+	if part.offset, err = strconv.ParseInt(fields[0], 10, 32); err != nil {
+		return part, fmt.Errorf("bad offset '%s'", fields[0])
+	}
+
+	if len(fields) > 1 {
+		var width uint64
+		if width, err = strconv.ParseUint(fields[1], 10, 8); err != nil {
+			return part, fmt.Errorf("bad width '%s'", fields[1])
+		}
+		part.width = int(width)
+	}
+
+	if len(fields) > 2 {
+		if len(fields[2]) != 1 || !strings.Contains("doxXnN", fields[2]) {
+			return part, fmt.Errorf("bad base '%s'", fields[2])
+		}
+		part.base = fields[2][0]
+	}
+
+	return part, nil
+}
+
+func (t generateTemplate) expand(value int64) (string, error) {
+	var out strings.Builder
+	for _, part := range t {
+		if part.base == 0 {
+			_, _ = out.WriteString(part.literal)
+			continue
+		}
+
+		v := value + part.offset
+		if v < 0 || v > math.MaxUint32 {
+			return "", fmt.Errorf("$GENERATE value %d with offset %d is out of range", value, part.offset)
+		}
+
+		var digits string
+		switch part.base {
+		case 'd':
+			digits = strconv.FormatInt(v, 10)
+		case 'o':
+			digits = strconv.FormatInt(v, 8)
+		case 'x':
+			digits = strconv.FormatInt(v, 16)
+		case 'X':
+			digits = strings.ToUpper(strconv.FormatInt(v, 16))
+		case 'n', 'N':
+			_, _ = out.WriteString(nibbles(v, part.width, part.base == 'N'))
+			continue
+		}
+
+		for n := len(digits); n < part.width; n++ {
+			_ = out.WriteByte('0')
+		}
+		_, _ = out.WriteString(digits)
+	}
+
+	return out.String(), nil
+}
+
+// nibbles formats value as dot-separated hexadecimal digits, least
+// significant first, as used for ip6.arpa names. Like BIND, width counts the
+// dots as well as the digits.
+func nibbles(value int64, width int, upper bool) string {
+	hex := "0123456789abcdef"
+	if upper {
+		hex = "0123456789ABCDEF"
+	}
+
+	var out strings.Builder
+	for {
+		_ = out.WriteByte(hex[value&0x0f])
+		value >>= 4
+		if width > 0 {
+			width--
+		}
+
+		if width > 0 || value != 0 {
+			_ = out.WriteByte('.')
+			if width > 0 {
+				width--
+			}
+		}
+
+		if value == 0 && width == 0 {
+			break
+		}
+	}
+
+	return out.String()
+}
+
+// nextGenerated fills outrecord with the next record of an active $GENERATE
+// control entry, returning false once there are none left.
+func (s *Scanner) nextGenerated(outrecord *Record) (bool, error) {
+	g := s.generate
+	if g == nil {
+		return false, nil
+	}
+
+	if g.current > g.stop {
+		s.generate = nil
+		return false, nil
+	}
+
+	value := g.current
+	g.current += g.step
+
+	record := Record{Position: g.position}
+	domain, err := g.owner.expand(value)
+	if err != nil {
+		s.generate = nil
+		return false, wrapRecordError(ErrInvalidData, record, err)
+	}
+
+	if domain == "@" {
+		domain = g.origin
+	} else if domain[len(domain)-1] != '.' {
+		domain = qualifyName(domain, g.origin)
+	}
+
+	if _, err = walkLabels(domain, nil); err != nil {
+		s.generate = nil
+		return false, wrapRecordError(ErrInvalidName, record, nameError(domain, err))
+	}
+
+	record.DomainName = domain
+	record.TimeToLive = g.timeToLive
+	record.Class = g.class
+	if record.Class == RecordClass_UNKNOWN {
+		record.Class = s.lastClass
+	}
+	record.Type = g.rtype
+	record.Data = make([]string, 0, len(g.data))
+	for _, template := range g.data {
+		var data string
+		if data, err = template.expand(value); err != nil {
+			s.generate = nil
+			return false, wrapRecordError(ErrInvalidData, record, err)
+		}
+		record.Data = append(record.Data, data)
+	}
+
+	if !s.keepRelativeNames {
+		if err = qualifyData(&record, g.origin); err != nil {
+			s.generate = nil
+			return false, err
+		}
+	}
+
+	if _, err = record.RData(); err != nil && !errors.Is(err, ErrUnsupportedRData) {
+		s.generate = nil
+		return false, wrapRecordError(ErrInvalidData, record, err)
+	}
+
+	// like BIND, a generated record is the previous record for the next
+	s.lastOwner = record.DomainName
+	s.lastClass = record.Class
+	*outrecord = record
+	return true, nil
+}
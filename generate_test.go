@@ -0,0 +1,208 @@
+package gozone
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerateControlEntryExpandsRange(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$ORIGIN 0.168.192.in-addr.arpa.\n$GENERATE 1-3 $ PTR host-$.adomain.com.\nwww.adomain.com. 300 IN A 192.168.0.1\n"))
+
+	for i, expected := range []string{"1", "2", "3"} {
+		if err := s.Next(&r); err != nil {
+			t.Fatalf("Unexpected error when parsing $GENERATE control entry: %s", err)
+		}
+
+		if r.DomainName != expected+".0.168.192.in-addr.arpa." {
+			t.Fatalf("$GENERATE record %d has unexpected domain '%s'", i, r.DomainName)
+		}
+
+		if r.Type != RecordType_PTR {
+			t.Fatalf("$GENERATE record %d has unexpected type '%s'", i, r.Type)
+		}
+
+		if !reflect.DeepEqual(r.Data, []string{"host-" + expected + ".adomain.com."}) {
+			t.Fatalf("$GENERATE record %d has unexpected data %#v", i, r.Data)
+		}
+	}
+
+	if err := s.Next(&r); err != nil {
+		t.Fatalf("Unexpected error when parsing record after $GENERATE control entry: %s", err)
+	}
+
+	if r.DomainName != "www.adomain.com." {
+		t.Fatalf("Record after $GENERATE control entry was not returned")
+	}
+
+	if err := s.Next(&r); err != io.EOF {
+		t.Fatalf("Parsing did not end with EOF after $GENERATE control entry: %v", err)
+	}
+}
+
+func TestGenerateControlEntryStepTTLAndClass(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$ORIGIN adomain.com.\n$GENERATE 10-20/5 host$ 600 IN A 10.0.0.$ ; pool\n"))
+
+	for _, expected := range []string{"10", "15", "20"} {
+		if err := s.Next(&r); err != nil {
+			t.Fatalf("Unexpected error when parsing $GENERATE control entry: %s", err)
+		}
+
+		record := Record{
+			DomainName: "host" + expected + ".adomain.com.",
+			TimeToLive: 600,
+			Class:      RecordClass_IN,
+			Type:       RecordType_A,
+			Data:       []string{"10.0.0." + expected},
+			Position:   Position{Line: 2, Column: 1},
+		}
+
+		if !reflect.DeepEqual(r, record) {
+			t.Fatalf("Generated Output [%#v] not equal to expected [%#v]", r, record)
+		}
+	}
+
+	if err := s.Next(&r); err != io.EOF {
+		t.Fatalf("Parsing did not end with EOF after $GENERATE control entry: %v", err)
+	}
+}
+
+func TestGenerateControlEntryInheritsClass(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$ORIGIN adomain.com.\na CH TXT x\n$GENERATE 1-2 h$ TXT y\n"))
+
+	for i := 0; i < 3; i++ {
+		if err := s.Next(&r); err != nil {
+			t.Fatalf("Unexpected error when parsing $GENERATE control entry: %s", err)
+		}
+
+		if r.Class != RecordClass_CH {
+			t.Fatalf("Record %d did not have the previous record's Class", i)
+		}
+	}
+}
+
+func TestGenerateControlEntrySetsPreviousDomainName(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$ORIGIN adomain.com.\n$GENERATE 1-2 h$ IN A 192.168.0.$\n\tTXT x\n"))
+
+	for _, expected := range []string{"h1", "h2", "h2"} {
+		if err := s.Next(&r); err != nil {
+			t.Fatalf("Unexpected error when parsing record after $GENERATE control entry: %s", err)
+		}
+
+		if r.DomainName != expected+".adomain.com." || r.Class != RecordClass_IN {
+			t.Fatalf("Expected IN Record for '%s.adomain.com.', got %s '%s'", expected, r.Class, r.DomainName)
+		}
+	}
+}
+
+func TestGenerateControlEntryQualifiesData(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$ORIGIN 0.168.192.in-addr.arpa.\n$GENERATE 1-1 $ 300 IN PTR host-$\n"))
+	if err := s.Next(&r); err != nil {
+		t.Fatalf("Unexpected error when parsing $GENERATE control entry: %s", err)
+	}
+
+	if !reflect.DeepEqual(r.Data, []string{"host-1.0.168.192.in-addr.arpa."}) {
+		t.Fatalf("$GENERATE record has unexpected data %#v", r.Data)
+	}
+}
+
+func TestGenerateControlEntryUsesDefaultTimeToLive(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$TTL 900\n$GENERATE 1-1 host$.adomain.com. CNAME adomain.com.\n"))
+
+	if err := s.Next(&r); err != nil {
+		t.Fatalf("Unexpected error when parsing $GENERATE control entry: %s", err)
+	}
+
+	if r.TimeToLive != 900 {
+		t.Fatalf("$GENERATE record without a TTL did not use the $TTL default")
+	}
+}
+
+func TestGenerateTemplateModifiers(t *testing.T) {
+	check := map[string]string{
+		"host-$":             "host-10",
+		"$$host":             "$host",
+		`\$host`:             "$host",
+		"${0}":               "10",
+		"${5}":               "15",
+		"${-10}":             "0",
+		"${0,4}":             "0010",
+		"${0,4,d}":           "0010",
+		"${0,3,o}":           "012",
+		"${0,2,x}":           "0a",
+		"${0,2,X}":           "0A",
+		"${250,0,x}":         "104",
+		"${0,0,n}":           "a",
+		"${245,0,N}":         "F.F",
+		"${0,7,n}":           "a.0.0.0",
+		"${0,1,n}":           "a",
+		"${0,2,n}":           "a.",
+		"${0,3,n}":           "a.0",
+		"${245,3,N}":         "F.F",
+		"${245,1,N}":         "F.F",
+		"${0,7,n}.ip6.arpa.": "a.0.0.0.ip6.arpa.",
+		"a${1}b${2,3}c$":     "a11b012c10",
+	}
+
+	for spec, expected := range check {
+		template, err := parseGenerateTemplate(spec)
+		if err != nil {
+			t.Fatalf("Failed to parse $GENERATE template '%s': %s", spec, err)
+		}
+
+		expanded, err := template.expand(10)
+		if err != nil {
+			t.Fatalf("Failed to expand $GENERATE template '%s': %s", spec, err)
+		}
+
+		if expanded != expected {
+			t.Fatalf("Expansion of $GENERATE template '%s' gave '%s', expected '%s'", spec, expanded, expected)
+		}
+	}
+}
+
+func TestGenerateControlEntryLargeRangeIsLazy(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$GENERATE 0-4294967295 host$.adomain.com. A 192.168.0.1\n"))
+
+	for i := 0; i < 1000; i++ {
+		if err := s.Next(&r); err != nil {
+			t.Fatalf("Unexpected error when parsing $GENERATE control entry: %s", err)
+		}
+	}
+
+	if r.DomainName != "host999.adomain.com." {
+		t.Fatalf("$GENERATE over a large range returned unexpected domain '%s'", r.DomainName)
+	}
+}
+
+func TestMalformedGenerateControlEntries(t *testing.T) {
+	specs := []string{
+		"$GENERATE 1-3 host$.adomain.com. A\n",
+		"$GENERATE 1-3 host$.adomain.com.\n",
+		"$GENERATE 3-1 host$.adomain.com. A 192.168.0.$\n",
+		"$GENERATE 1-3/0 host$.adomain.com. A 192.168.0.$\n",
+		"$GENERATE a-3 host$.adomain.com. A 192.168.0.$\n",
+		"$GENERATE 1-3 host${0,2,q}.adomain.com. A 192.168.0.$\n",
+		"$GENERATE 1-3 host${0.adomain.com. A 192.168.0.$\n",
+		"$GENERATE 1-3 host$.adomain.com. FAKE 192.168.0.$\n",
+		"$GENERATE 1-3 host$ A 192.168.0.$\n",
+		"$GENERATE 1-3 host$.adomain.com. A 192.168.0.30$\n",
+		"$GENERATE 1-3 host..$.adomain.com. A 192.168.0.$\n",
+	}
+
+	for _, spec := range specs {
+		var r Record
+		s := NewScanner(strings.NewReader(spec))
+		if err := s.Next(&r); err == nil {
+			t.Fatalf("Parsing of malformed $GENERATE control entry [%s] did not return an error", spec)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package gozone
+
+// https://www.ietf.org/rfc/rfc3597.txt
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseGenericValue parses the RFC 3597 "TYPEnnn" / "CLASSnnn" spelling of a
+// type or class
+func parseGenericValue(token string, prefix string) (uint16, bool) {
+	if !strings.HasPrefix(token, prefix) {
+		return 0, false
+	}
+
+	digits := token[len(prefix):]
+	if len(digits) == 0 || digits[0] == '+' || digits[0] == '-' {
+		return 0, false
+	}
+
+	value, err := strconv.ParseUint(digits, 10, 16)
+	if err != nil || value == 0 {
+		return 0, false
+	}
+
+	return uint16(value), true
+}
+
+func isGenericData(data []string) bool {
+	return len(data) != 0 && data[0] == `\#`
+}
+
+// decodeGenericData decodes RFC 3597 generic record data, of the form
+// "\# <length> <hex> ...", checking the hex against the given length
+func decodeGenericData(data []string) ([]byte, error) {
+	fields := stripParens(data)
+	if len(fields) < 2 || fields[0] != `\#` {
+		return nil, fmt.Errorf("Record data is not in the generic \\# form")
+	}
+
+	length, err := strconv.ParseUint(fields[1], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid length '%s' in generic record data", fields[1])
+	}
+
+	decoded, err := hex.DecodeString(strings.Join(fields[2:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid hex in generic record data: %s", err)
+	}
+
+	if uint64(len(decoded)) != length {
+		return nil, fmt.Errorf("Generic record data has %d bytes, but its length is given as %d", len(decoded), length)
+	}
+
+	return decoded, nil
+}
+
+// GenericData decodes record data written in the RFC 3597 generic form,
+// "\# <length> <hex>".
+func (r Record) GenericData() ([]byte, error) {
+	return decodeGenericData(r.Data)
+}
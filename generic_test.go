@@ -0,0 +1,109 @@
+package gozone
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGenericTypeAndClass(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader(`adomain.com. 300 CLASS42 TYPE65280 \# 4 0A000001`))
+	err := s.Next(&r)
+	if err != nil {
+		t.Fatalf("Failed to parse record with generic type and class: %s", err)
+	}
+
+	if r.Class != RecordClass(42) || r.Type != RecordType(65280) {
+		t.Fatalf("Parsing of generic type and class gave unexpected record [%#v]", r)
+	}
+
+	expected := `adomain.com. 300 CLASS42 TYPE65280 \# 4 0A000001`
+	if r.String() != expected {
+		t.Fatalf("Generated Output [%s] not equal to expected output [%s]", r.String(), expected)
+	}
+}
+
+func TestGenericTypeOfKnownType(t *testing.T) {
+	parsed, err := parseType("TYPE1")
+	if err != nil {
+		t.Fatalf("Failed to parse generic type for A: %s", err)
+	}
+
+	if parsed != RecordType_A || parsed.String() != "A" {
+		t.Fatalf("Parsing of 'TYPE1' did not return the A type")
+	}
+}
+
+func TestInvalidGenericTypesFail(t *testing.T) {
+	for _, spec := range []string{"TYPE", "TYPE0", "TYPE65536", "TYPE-1", "TYPE+1", "TYPEA"} {
+		if _, err := parseType(spec); err == nil {
+			t.Fatalf("Parsing of invalid generic type '%s' did not return an error", spec)
+		}
+	}
+
+	for _, spec := range []string{"CLASS", "CLASS0", "CLASS65536"} {
+		if _, err := parseClass(spec); err == nil {
+			t.Fatalf("Parsing of invalid generic class '%s' did not return an error", spec)
+		}
+	}
+}
+
+func TestUnknownTypeString(t *testing.T) {
+	if RecordType(65534).String() != "TYPE65534" {
+		t.Fatalf("Unknown RecordType did not format in the generic form")
+	}
+
+	if RecordClass(1234).String() != "CLASS1234" {
+		t.Fatalf("Unknown RecordClass did not format in the generic form")
+	}
+}
+
+func TestGenericData(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("adomain.com. 300 IN TYPE731 \\# 6 ( abcd\n ef 012345 )\n"))
+	err := s.Next(&r)
+	if err != nil {
+		t.Fatalf("Failed to parse record with generic data: %s", err)
+	}
+
+	data, err := r.GenericData()
+	if err != nil {
+		t.Fatalf("Failed to decode generic data: %s", err)
+	}
+
+	if !bytes.Equal(data, []byte{0xab, 0xcd, 0xef, 0x01, 0x23, 0x45}) {
+		t.Fatalf("Decoding of generic data gave unexpected bytes %x", data)
+	}
+}
+
+func TestEmptyGenericData(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader(`adomain.com. 300 IN TYPE731 \# 0`))
+	err := s.Next(&r)
+	if err != nil {
+		t.Fatalf("Failed to parse record with empty generic data: %s", err)
+	}
+
+	data, err := r.GenericData()
+	if err != nil || len(data) != 0 {
+		t.Fatalf("Decoding of empty generic data did not return empty data")
+	}
+}
+
+func TestGenericDataLengthMismatchFails(t *testing.T) {
+	for _, spec := range []string{
+		`adomain.com. 300 IN TYPE731 \# 4 0A0000`,
+		`adomain.com. 300 IN TYPE731 \# 2 0A0000`,
+		`adomain.com. 300 IN TYPE731 \# 2 0A0`,
+		`adomain.com. 300 IN TYPE731 \# 2 zzzz`,
+		`adomain.com. 300 IN TYPE731 \# x 0A00`,
+		`adomain.com. 300 IN TYPE731 \#`,
+	} {
+		var r Record
+		s := NewScanner(strings.NewReader(spec))
+		if err := s.Next(&r); err == nil {
+			t.Fatalf("Parsing of malformed generic data [%s] did not return an error", spec)
+		}
+	}
+}
@@ -3,15 +3,12 @@ package gozone
 // https://www.ietf.org/rfc/rfc1035.txt
 
 import (
-	"bufio"
-	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"math"
-	"strconv"
+	"slices"
 	"strings"
-	"unicode"
 )
 
 type RecordClass int
@@ -37,9 +34,12 @@ func (rc RecordClass) String() string {
 		return "HS"
 	case RecordClass_any:
 		return "*"
+	case RecordClass_UNKNOWN:
+		return "[UNKNOWN]"
 	}
 
-	return "[UNKNOWN]"
+	// RFC 3597 generic class
+	return fmt.Sprintf("CLASS%d", int(rc))
 }
 
 type RecordType int
@@ -318,9 +318,12 @@ func (rt RecordType) String() string {
 		return "TA"
 	case RecordType_DLV:
 		return "DLV"
+	case RecordType_UNKNOWN:
+		return "[UNKNOWN]"
 	}
 
-	return "[UNKNOWN]"
+	// RFC 3597 generic type
+	return fmt.Sprintf("TYPE%d", int(rt))
 }
 
 type Record struct {
@@ -330,6 +333,7 @@ type Record struct {
 	Type       RecordType
 	Data       []string
 	Comment    string
+	Position   Position // where the record starts in the zone file
 }
 
 func (r Record) String() string {
@@ -358,6 +362,82 @@ func (r Record) String() string {
 	return strings.Join(spec, " ")
 }
 
+// stripParens returns record data without the parentheses which group it
+// across lines. Data without parentheses is returned as it is, so the
+// result must not be modified.
+func stripParens(data []string) []string {
+	if !slices.Contains(data, "(") && !slices.Contains(data, ")") {
+		return data
+	}
+
+	fields := make([]string, 0, len(data))
+	for _, field := range data {
+		if field != "(" && field != ")" {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// qualifyName makes a relative domain name absolute, by adding origin, with
+// "@" standing for origin itself
+func qualifyName(name, origin string) string {
+	switch {
+	case name == "@":
+		return origin
+	case origin == ".":
+		return name + "."
+	}
+
+	return name + "." + origin
+}
+
+// qualifyData makes the relative domain names within the record data
+// absolute, as rdataDomainFields lists them. Without an origin, they are
+// left as written.
+func qualifyData(record *Record, origin string) error {
+	domainFields := rdataDomainFields[record.Type]
+	if len(domainFields) == 0 || isGenericData(stripParens(record.Data)) {
+		return nil
+	}
+
+	field := -1
+	for i, data := range record.Data {
+		if data == "(" || data == ")" {
+			continue
+		}
+
+		field++
+		if !slices.Contains(domainFields, field) || data[0] == '"' {
+			continue
+		}
+
+		if data != "@" {
+			absolute, err := walkLabels(data, nil)
+			if err != nil {
+				return wrapRecordError(ErrInvalidName, *record, nameError(data, err))
+			}
+
+			if absolute {
+				continue
+			}
+		}
+
+		if origin == "" {
+			continue
+		}
+
+		qualified := qualifyName(data, origin)
+		if _, err := walkLabels(qualified, nil); err != nil {
+			return wrapRecordError(ErrInvalidName, *record, nameError(qualified, err))
+		}
+		record.Data[i] = qualified
+	}
+
+	return nil
+}
+
 type scannerState int
 
 const (
@@ -375,23 +455,67 @@ const (
 )
 
 type Scanner struct {
-	src        *bufio.Reader
+	in         scanBuffer
 	state      scannerState
 	origin     string
 	timeToLive int64
-	nextRune   rune
-	nextSize   int
+
+	fileName        string
+	closer          io.Closer
+	resolver        IncludeResolver
+	includes        []scannerInclude
+	maxIncludeDepth int
+	generate        *generator
+
+	lineIndented bool
+	lastOwner    string
+	lastClass    RecordClass
+	ownerPending bool  // whether the previous owner is not known yet
+	ownerError   error // the error for the first record inheriting a pending owner
+
+	keepRelativeNames bool
+
+	line          int // position of the next byte to be scanned
+	column        int
+	tokenPosition Position // position of the start of the current token
+	lineEnded     bool     // whether the most recent token ended a line
+
+	text      []byte // the owner and Data of the record being scanned
+	fields    []int  // where each Data field starts within text
+	dataBlock []string
+	kinds     map[string]tokenKind
+
+	recover bool
+	errors  []*ParseError
 }
 
 func NewScanner(src io.Reader) *Scanner {
 	return &Scanner{
-		src:        bufio.NewReader(src),
-		timeToLive: -1,
-		nextRune:   0,
-		nextSize:   0,
+		in:              newScanBuffer(src),
+		state:           scannerState_Space,
+		timeToLive:      -1,
+		maxIncludeDepth: DefaultMaxIncludeDepth,
+		line:            1,
+		column:          1,
 	}
 }
 
+// SetFileName records the name of the file being scanned, for use in the
+// Position of records and errors, and so that an $INCLUDE of the same file
+// can be recognised as a cycle.
+func (s *Scanner) SetFileName(name string) {
+	s.fileName = name
+}
+
+// SetKeepRelativeNames chooses whether relative domain names within record
+// data are kept as written. By default they are made absolute using the
+// current origin, as owner names are, following the fields which hold
+// domain names for each type. Before any origin is set, they are kept as
+// written either way.
+func (s *Scanner) SetKeepRelativeNames(keep bool) {
+	s.keepRelativeNames = keep
+}
+
 func (s *Scanner) SetOrigin(domain string) error {
 	if domain[len(domain)-1] != '.' {
 		return fmt.Errorf("Tried to set $ORIGIN to relative domain")
@@ -414,193 +538,6 @@ func (s *Scanner) SetTimeToLive(timeToLive int64) error {
 	return nil
 }
 
-func (s *Scanner) nextToken() (string, error) {
-	var token bytes.Buffer
-
-	var r rune
-	var size int
-	var err error
-	for {
-		if s.nextSize != 0 {
-			r = s.nextRune
-			size = s.nextSize
-			s.nextSize = 0
-		} else {
-			r, size, err = s.src.ReadRune()
-			if err != nil {
-				if err == io.EOF {
-					if s.state != scannerState_Default &&
-						s.state != scannerState_Space &&
-						s.state != scannerState_Comment {
-						return "", errors.New("Unexpected end of input")
-					}
-
-					if token.Len() != 0 {
-						return token.String(), nil
-					}
-				}
-
-				return "", err
-			}
-		}
-
-		s.nextRune = r
-		s.nextSize = size
-
-		switch s.state {
-		case scannerState_Default, scannerState_Paren:
-			if unicode.IsSpace(r) {
-				if token.Len() > 0 {
-					return token.String(), nil
-				}
-
-				if s.state == scannerState_Default {
-					if r == '\n' {
-						s.nextSize = 0
-						s.state = scannerState_Space
-						return "\n", nil
-					}
-				}
-
-				// ignore whitespace between tokens
-				s.nextSize = 0
-				continue
-			}
-
-			if s.state == scannerState_Default {
-				if r == '(' {
-					if token.Len() > 0 {
-						return token.String(), nil
-					}
-
-					s.nextSize = 0
-					s.state = scannerState_Paren
-					return "(", nil
-				}
-
-				if r == '\\' {
-					s.nextSize = 0
-					s.state = scannerState_DefaultEscape
-					_, _ = token.WriteRune(r)
-					continue
-				}
-			} else if s.state == scannerState_Paren {
-				if r == ')' {
-					if token.Len() > 0 {
-						return token.String(), nil
-					}
-
-					s.nextSize = 0
-					s.state = scannerState_Default
-					return ")", nil
-				}
-
-				if r == '\\' {
-					s.nextSize = 0
-					s.state = scannerState_ParenEscape
-					_, _ = token.WriteRune(r)
-					continue
-				}
-			}
-
-			if r == '"' {
-				if token.Len() > 0 {
-					return token.String(), nil
-				}
-
-				s.nextSize = 0
-				if s.state == scannerState_Default {
-					s.state = scannerState_String
-				} else {
-					s.state = scannerState_ParenString
-				}
-				_, _ = token.WriteRune(r)
-				continue
-			}
-
-			if r == ';' {
-				if token.Len() > 0 {
-					return token.String(), nil
-				}
-
-				s.nextSize = 0
-				if s.state == scannerState_Default {
-					s.state = scannerState_Comment
-				} else {
-					s.state = scannerState_ParenComment
-				}
-				_, _ = token.WriteRune(r)
-				continue
-			}
-
-			s.nextSize = 0
-			_, _ = token.WriteRune(r)
-
-		case scannerState_String, scannerState_ParenString:
-			if r == '"' {
-				s.nextSize = 0
-				if s.state == scannerState_String {
-					s.state = scannerState_Default
-				} else {
-					s.state = scannerState_Paren
-				}
-				_, _ = token.WriteRune(r)
-				return token.String(), nil
-			}
-
-			if r == '\\' {
-				s.nextSize = 0
-				if s.state == scannerState_String {
-					s.state = scannerState_StringEscape
-				} else {
-					s.state = scannerState_ParenStringEscape
-				}
-				_, _ = token.WriteRune(r)
-				continue
-			}
-
-			s.nextSize = 0
-			_, _ = token.WriteRune(r)
-
-		case scannerState_DefaultEscape, scannerState_StringEscape, scannerState_ParenEscape, scannerState_ParenStringEscape:
-			s.nextSize = 0
-			switch s.state {
-			case scannerState_DefaultEscape:
-				s.state = scannerState_Default
-			case scannerState_StringEscape:
-				s.state = scannerState_String
-			case scannerState_ParenEscape:
-				s.state = scannerState_Paren
-			case scannerState_ParenStringEscape:
-				s.state = scannerState_String
-			}
-			_, _ = token.WriteRune(r)
-
-		case scannerState_Comment, scannerState_ParenComment:
-			if r == '\n' {
-				if s.state == scannerState_Comment {
-					s.state = scannerState_Default
-				} else {
-					s.state = scannerState_Paren
-				}
-				continue
-			}
-
-			s.nextSize = 0
-			_, _ = token.WriteRune(r)
-
-		case scannerState_Space:
-			if unicode.IsSpace(r) {
-				s.nextSize = 0
-				continue
-			}
-
-			s.state = scannerState_Default
-			continue
-		}
-	}
-}
-
 func parseClass(token string) (RecordClass, error) {
 	switch token {
 	case "IN":
@@ -613,9 +550,13 @@ func parseClass(token string) (RecordClass, error) {
 		return RecordClass_HS, nil
 	case "*":
 		return RecordClass_any, nil
-	default:
-		return RecordClass_UNKNOWN, fmt.Errorf("Unknown Record Class '%s'", token)
 	}
+
+	if value, ok := parseGenericValue(token, "CLASS"); ok {
+		return RecordClass(value), nil
+	}
+
+	return RecordClass_UNKNOWN, fmt.Errorf("Unknown Record Class '%s'", token)
 }
 
 func parseType(token string) (RecordType, error) {
@@ -794,9 +735,13 @@ func parseType(token string) (RecordType, error) {
 		return RecordType_TA, nil
 	case "DLV":
 		return RecordType_DLV, nil
-	default:
-		return 0, fmt.Errorf("Unknown Record Type '%s'", token)
 	}
+
+	if value, ok := parseGenericValue(token, "TYPE"); ok {
+		return RecordType(value), nil
+	}
+
+	return 0, fmt.Errorf("Unknown Record Type '%s'", token)
 }
 
 func (s *Scanner) scanControlEntry(initial string) error {
@@ -805,8 +750,12 @@ func (s *Scanner) scanControlEntry(initial string) error {
 		return s.scanControlEntryOrigin()
 	case "$TTL":
 		return s.scanControlEntryTTL()
+	case "$INCLUDE":
+		return s.scanControlEntryInclude()
+	case "$GENERATE":
+		return s.scanControlEntryGenerate()
 	default:
-		return fmt.Errorf("Unknown Control Entry '%s'", initial)
+		return s.errorf(ErrUnknownControlEntry, initial, "Unknown Control Entry '%s'", initial)
 	}
 }
 
@@ -822,7 +771,7 @@ func (s *Scanner) scanControlEntryOrigin() error {
 					break
 				}
 
-				return fmt.Errorf("Incomplete $ORIGIN control entry at end of file")
+				return s.errorf(ErrInvalidControlEntry, "", "Incomplete $ORIGIN control entry at end of file")
 			}
 
 			return err
@@ -833,22 +782,22 @@ func (s *Scanner) scanControlEntryOrigin() error {
 				return nil
 			}
 
-			return fmt.Errorf("Incomplete $ORIGIN control entry ends in comment")
+			return s.errorf(ErrInvalidControlEntry, token, "Incomplete $ORIGIN control entry ends in comment")
 		}
 
 		if token == "\n" {
 			if !hasDomain {
-				return fmt.Errorf("missing DomainName in $ORIGIN control entry")
+				return s.errorf(ErrInvalidControlEntry, token, "missing DomainName in $ORIGIN control entry")
 			}
 			break
 		}
 
 		if hasDomain {
-			return fmt.Errorf("Multiple domains found in $ORIGIN control entry")
+			return s.errorf(ErrInvalidControlEntry, token, "Multiple domains found in $ORIGIN control entry")
 		}
 
 		if err = s.SetOrigin(token); err != nil {
-			return err
+			return s.wrapError(ErrInvalidControlEntry, token, err)
 		}
 
 		hasDomain = true
@@ -869,7 +818,7 @@ func (s *Scanner) scanControlEntryTTL() error {
 					break
 				}
 
-				return fmt.Errorf("Incomplete $TTL control entry at end of file")
+				return s.errorf(ErrInvalidControlEntry, "", "Incomplete $TTL control entry at end of file")
 			}
 
 			return err
@@ -880,28 +829,34 @@ func (s *Scanner) scanControlEntryTTL() error {
 				return nil
 			}
 
-			return fmt.Errorf("Incomplete $TTL control entry ends in comment")
+			return s.errorf(ErrInvalidControlEntry, token, "Incomplete $TTL control entry ends in comment")
 		}
 
 		if token == "\n" {
 			if !hasTTL {
-				return fmt.Errorf("missing TimeToLive in $TTL control entry")
+				return s.errorf(ErrInvalidControlEntry, token, "missing TimeToLive in $TTL control entry")
 			}
 			break
 		}
 
 		if hasTTL {
-			return fmt.Errorf("Multiple TimeToLive found in $TTL control entry")
+			return s.errorf(ErrInvalidControlEntry, token, "Multiple TimeToLive found in $TTL control entry")
 		}
 
-		var i64 uint64
-		i64, err = strconv.ParseUint(token, 10, 32)
+		var ttl uint32
+		ttl, err = ParseTTL(token)
 		if err != nil {
-			return fmt.Errorf("Failed to parse TimeToLive in $TTL control entry: %s", err)
+			return &ParseError{
+				Position: s.tokenPosition,
+				Token:    token,
+				Kind:     ErrInvalidTTL,
+				Message:  fmt.Sprintf("Failed to parse TimeToLive in $TTL control entry: %s", err),
+				Err:      err,
+			}
 		}
 
-		if err = s.SetTimeToLive(int64(i64)); err != nil {
-			return err
+		if err = s.SetTimeToLive(int64(ttl)); err != nil {
+			return s.wrapError(ErrInvalidTTL, token, err)
 		}
 		hasTTL = true
 	}
@@ -909,9 +864,36 @@ func (s *Scanner) scanControlEntryTTL() error {
 	return nil
 }
 
+// Next reads the next record into outrecord, returning io.EOF once the
+// input is exhausted. The strings of each record are held in a single
+// allocation, and its Data in a block shared with the records which follow,
+// so records stay valid however long they are kept.
 func (s *Scanner) Next(outrecord *Record) error {
+	err := s.next(outrecord)
+	for s.recover && err != nil {
+		var parseError *ParseError
+		if !errors.As(err, &parseError) {
+			break
+		}
+
+		s.errors = append(s.errors, parseError)
+		if errors.Is(err, ErrUnexpectedEOF) {
+			// nothing more can be read from the current file
+			s.state = scannerState_Space
+			s.lineEnded = true
+		}
+
+		if err = s.resync(); err == nil {
+			err = s.next(outrecord)
+		}
+	}
+
+	return err
+}
+
+func (s *Scanner) next(outrecord *Record) error {
 	var record Record
-	var token string
+	var token []byte
 	var err error
 
 	var hasClass bool
@@ -919,111 +901,207 @@ func (s *Scanner) Next(outrecord *Record) error {
 	var hasType bool
 	var hasData bool
 
+	if generated, err := s.nextGenerated(outrecord); generated || err != nil {
+		return err
+	}
+
+	var indented bool
 	record.TimeToLive = -1
 	for { // ignore leading spaces / comments / process control entries
-		if token, err = s.nextToken(); err != nil {
+		if token, err = s.nextTokenBytes(); err != nil {
 			return err
 		}
 
-		if token[0] == '$' {
+		switch {
+		case isToken(token, " "):
+			indented = true
+			continue
+		case isToken(token, "\n"):
+			indented = false
+			continue
+		case token[0] == ';':
+			continue
+		case token[0] == '$':
 			// control entry
-			if err = s.scanControlEntry(token); err != nil {
+			if err = s.scanControlEntry(string(token)); err != nil {
 				return err
 			}
-		}
 
-		if token != "\n" && token[0] != ';' && token[0] != '$' {
-			break
+			// the control entry used up the rest of its line
+			indented = false
+			if generated, err := s.nextGenerated(outrecord); generated || err != nil {
+				return err
+			}
+			continue
 		}
+
+		break
 	}
 
-	domain := token
-	if domain == "@" {
-		if s.origin == "" {
-			return fmt.Errorf("Record for current domain specified when no $ORIGIN defined")
+	// the owner and Data of the record are gathered into text, so that
+	// they share a single string
+	s.text = s.text[:0]
+	s.fields = s.fields[:0]
+	ownerEnd := -1 // the end of the owner within text, when it is there
+
+	record.Position = s.tokenPosition
+	if indented {
+		if s.lastOwner == "" && (!s.ownerPending || s.ownerError == nil) {
+			err = s.errorf(ErrNoPreviousDomain, string(token), "Record inherits the previous DomainName when no previous record defined")
+			if !s.ownerPending {
+				return err
+			}
+
+			// kept, in case no earlier record turns out to have an owner
+			s.ownerError = err
 		}
-		domain = s.origin
-	} else if domain[len(token)-1] != '.' {
+		record.DomainName = s.lastOwner
+	} else if isToken(token, "@") {
 		if s.origin == "" {
-			return fmt.Errorf("Record relative-to-current domain specified when no $ORIGIN defined")
+			return s.errorf(ErrNoOrigin, string(token), "Record for current domain specified when no $ORIGIN defined")
+		}
+		record.DomainName = s.origin
+	} else {
+		absolute, err := walkLabels(token, nil)
+		if err != nil {
+			return s.wrapError(ErrInvalidName, string(token), nameError(string(token), err))
 		}
 
-		domain = fmt.Sprintf("%s.%s", token, s.origin)
+		s.text = append(s.text, token...)
+		if !absolute {
+			if s.origin == "" {
+				return s.errorf(ErrNoOrigin, string(token), "Record relative-to-current domain specified when no $ORIGIN defined")
+			}
+
+			s.text = append(s.text, '.')
+			if s.origin != "." {
+				s.text = append(s.text, s.origin...)
+			}
+
+			if _, err = walkLabels(s.text, nil); err != nil {
+				return s.wrapError(ErrInvalidName, string(token), nameError(string(s.text), err))
+			}
+		}
+		ownerEnd = len(s.text)
 	}
-	record.DomainName = domain
 
+	owner := func() string {
+		if ownerEnd < 0 {
+			return record.DomainName
+		}
+		return string(s.text[:ownerEnd])
+	}
+
+	// an inherited DomainName means the current token is already part of
+	// the rest of the record
+	reuseToken := indented
 	for {
-		if token, err = s.nextToken(); err != nil {
+		if reuseToken {
+			reuseToken = false
+		} else if token, err = s.nextTokenBytes(); err != nil {
 			if err == io.EOF {
 				if hasData {
-					*outrecord = record
 					break
 				}
 
-				if hasClass || hasTTL || hasType {
-					return fmt.Errorf("Incomplete record at end of file")
-				}
+				return s.errorf(ErrIncompleteRecord, "", "Incomplete record at end of file")
 			}
 
 			return err
 		}
 
 		if !hasType {
+			if isToken(token, "\n") || token[0] == ';' {
+				return s.errorf(ErrIncompleteRecord, string(token), "missing Type for DomainName: %s", owner())
+			}
+
 			if !hasTTL {
-				var i64 uint64
-				i64, err = strconv.ParseUint(token, 10, 32)
-				if err != nil {
-					record.TimeToLive = s.timeToLive
-				} else {
-					record.TimeToLive = int64(i64)
-					hasTTL = true
-					continue
+				// only a token starting with a digit can be a TimeToLive
+				if isDigit(token[0]) {
+					if ttl, err := parseTTL(token); err == nil {
+						record.TimeToLive = int64(ttl)
+						hasTTL = true
+						continue
+					}
 				}
+				record.TimeToLive = s.timeToLive
 			}
 
+			kind := s.kindOf(token)
 			if !hasClass {
-				record.Class, err = parseClass(token)
-				if err != nil {
-					record.Class = RecordClass_UNKNOWN
-				} else {
+				if kind.isClass {
+					record.Class = kind.class
 					hasClass = true
 					continue
 				}
+				record.Class = RecordClass_UNKNOWN
 			}
 
-			record.Type, err = parseType(token)
-			if err != nil {
-				return err
-			} else {
-				hasType = true
-				continue
+			if !kind.isType {
+				_, err = parseType(string(token))
+				return s.wrapError(ErrUnknownType, string(token), err)
 			}
+
+			record.Type = kind.rtype
+			hasType = true
+			continue
 		}
 
 		if !hasData {
-			if token == "\n" || token[0] == ';' {
-				return fmt.Errorf("missing data part for DomainName: %s; Type: %s",
-					record.DomainName,
+			if isToken(token, "\n") || token[0] == ';' {
+				return s.errorf(ErrIncompleteRecord, string(token), "missing data part for DomainName: %s; Type: %s",
+					owner(),
 					record.Type,
 				)
 			}
 		}
 
 		if token[0] == ';' {
-			record.Comment = token
+			record.Comment = string(token)
 			continue
 		}
 
-		if token == "\n" {
+		if isToken(token, "\n") {
 			break
 		}
 
 		record.Comment = "" // ignore "internal" comments
-		record.Data = append(record.Data, token)
+		s.fields = append(s.fields, len(s.text))
+		s.text = append(s.text, token...)
 		hasData = true
 		continue
 	}
 
+	text := string(s.text)
+	if ownerEnd >= 0 {
+		record.DomainName = text[:ownerEnd]
+	}
+
+	record.Data = s.allocData(len(s.fields))
+	for i, start := range s.fields {
+		end := len(text)
+		if i+1 < len(s.fields) {
+			end = s.fields[i+1]
+		}
+		record.Data[i] = text[start:end]
+	}
+
+	if !hasClass {
+		record.Class = s.lastClass
+	}
+
+	if !s.keepRelativeNames {
+		if err = qualifyData(&record, s.origin); err != nil {
+			return err
+		}
+	}
+
+	if _, err = record.RData(); err != nil && !errors.Is(err, ErrUnsupportedRData) {
+		return wrapRecordError(ErrInvalidData, record, err)
+	}
+
+	s.lastOwner = record.DomainName
+	s.lastClass = record.Class
 	*outrecord = record
 	return nil
 }
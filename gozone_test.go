@@ -143,24 +143,24 @@ func TestRecordTypes(t *testing.T) {
 	records := map[string]Record{
 		"adomain.com. 300 IN SOA ns.ahostdomain.com. hostmaster.ahostdomain.com. ( 1271271271 10800 3600 604800 300 )": Record{
 			"adomain.com.", 300, RecordClass_IN, RecordType_SOA,
-			[]string{"ns.ahostdomain.com.", "hostmaster.ahostdomain.com.", "(", "1271271271", "10800", "3600", "604800", "300", ")"}, "",
+			[]string{"ns.ahostdomain.com.", "hostmaster.ahostdomain.com.", "(", "1271271271", "10800", "3600", "604800", "300", ")"}, "", Position{Line: 1, Column: 1},
 		},
 
 		"adomain.com. 300 IN SOA ns.ahostdomain.com. hostmaster.ahostdomain.com.(1271271271 10800 3600 604800 300)": Record{
 			"adomain.com.", 300, RecordClass_IN, RecordType_SOA,
-			[]string{"ns.ahostdomain.com.", "hostmaster.ahostdomain.com.", "(", "1271271271", "10800", "3600", "604800", "300", ")"}, "",
+			[]string{"ns.ahostdomain.com.", "hostmaster.ahostdomain.com.", "(", "1271271271", "10800", "3600", "604800", "300", ")"}, "", Position{Line: 1, Column: 1},
 		},
 
-		"adomain.com. 300 IN A 192.168.0.1;aComment": Record{"adomain.com.", 300, RecordClass_IN, RecordType_A, []string{"192.168.0.1"}, ";aComment"},
-		"adomain.com. IN A 192.168.0.1":              Record{"adomain.com.", -1, RecordClass_IN, RecordType_A, []string{"192.168.0.1"}, ""},
+		"adomain.com. 300 IN A 192.168.0.1;aComment": Record{"adomain.com.", 300, RecordClass_IN, RecordType_A, []string{"192.168.0.1"}, ";aComment", Position{Line: 1, Column: 1}},
+		"adomain.com. IN A 192.168.0.1":              Record{"adomain.com.", -1, RecordClass_IN, RecordType_A, []string{"192.168.0.1"}, "", Position{Line: 1, Column: 1}},
 
-		"adomain.com. 300 IN A 192.168.0.1\n\nadomain.com. 300 IN A 192.168.0.2\n": Record{"adomain.com.", 300, RecordClass_IN, RecordType_A, []string{"192.168.0.1"}, ""},
+		"adomain.com. 300 IN A 192.168.0.1\n\nadomain.com. 300 IN A 192.168.0.2\n": Record{"adomain.com.", 300, RecordClass_IN, RecordType_A, []string{"192.168.0.1"}, "", Position{Line: 1, Column: 1}},
 
-		"adomain.com. 300 IN NS ns.ahostdomain.com.":      Record{"adomain.com.", 300, RecordClass_IN, RecordType_NS, []string{"ns.ahostdomain.com."}, ""},
-		"adomain.com. 300 IN MX 10 smtp.ahostdomain.com.": Record{"adomain.com.", 300, RecordClass_IN, RecordType_MX, []string{"10", "smtp.ahostdomain.com."}, ""},
-		`adomain.com. 300 IN TXT "a \"b\" c"`:             Record{"adomain.com.", 300, RecordClass_IN, RecordType_TXT, []string{`"a \"b\" c"`}, ""},
-		`adomain.com. 300 IN TXT"a \"b\" c"`:              Record{"adomain.com.", 300, RecordClass_IN, RecordType_TXT, []string{`"a \"b\" c"`}, ""},
-		"www.adomain.com. 300 IN CNAME adomain.com.":      Record{"www.adomain.com.", 300, RecordClass_IN, RecordType_CNAME, []string{"adomain.com."}, ""},
+		"adomain.com. 300 IN NS ns.ahostdomain.com.":      Record{"adomain.com.", 300, RecordClass_IN, RecordType_NS, []string{"ns.ahostdomain.com."}, "", Position{Line: 1, Column: 1}},
+		"adomain.com. 300 IN MX 10 smtp.ahostdomain.com.": Record{"adomain.com.", 300, RecordClass_IN, RecordType_MX, []string{"10", "smtp.ahostdomain.com."}, "", Position{Line: 1, Column: 1}},
+		`adomain.com. 300 IN TXT "a \"b\" c"`:             Record{"adomain.com.", 300, RecordClass_IN, RecordType_TXT, []string{`"a \"b\" c"`}, "", Position{Line: 1, Column: 1}},
+		`adomain.com. 300 IN TXT"a \"b\" c"`:              Record{"adomain.com.", 300, RecordClass_IN, RecordType_TXT, []string{`"a \"b\" c"`}, "", Position{Line: 1, Column: 1}},
+		"www.adomain.com. 300 IN CNAME adomain.com.":      Record{"www.adomain.com.", 300, RecordClass_IN, RecordType_CNAME, []string{"adomain.com."}, "", Position{Line: 1, Column: 1}},
 	}
 
 	for spec, record := range records {
@@ -609,3 +609,78 @@ func TestSetTimeToLiveTooSmallFolds(t *testing.T) {
 		t.Fatalf("Setting TimeToLive to a number smaller than -1 (ie, to indicate unspecified) did not fold the value to -1")
 	}
 }
+
+func TestIndentedRecordInheritsDomainName(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("adomain.com. 300 IN A 192.168.1.1\n\t300 IN A 192.168.1.2\n  MX 10 smtp.ahostdomain.com.\n"))
+
+	expected := []Record{
+		Record{"adomain.com.", 300, RecordClass_IN, RecordType_A, []string{"192.168.1.1"}, "", Position{Line: 1, Column: 1}},
+		Record{"adomain.com.", 300, RecordClass_IN, RecordType_A, []string{"192.168.1.2"}, "", Position{Line: 2, Column: 2}},
+		Record{"adomain.com.", -1, RecordClass_IN, RecordType_MX, []string{"10", "smtp.ahostdomain.com."}, "", Position{Line: 3, Column: 3}},
+	}
+
+	for _, record := range expected {
+		if err := s.Next(&r); err != nil {
+			t.Fatalf("Failed to parse record with inherited DomainName: %s", err)
+		}
+
+		if !reflect.DeepEqual(r, record) {
+			t.Fatalf("Generated Output [%#v] not equal to expected [%#v]", r, record)
+		}
+	}
+}
+
+func TestIndentedBlankAndCommentLinesAreIgnored(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("adomain.com. 300 IN A 192.168.1.1\n   \n\t; a comment\nwww.adomain.com. 300 IN A 192.168.1.2\n"))
+
+	for _, domain := range []string{"adomain.com.", "www.adomain.com."} {
+		if err := s.Next(&r); err != nil {
+			t.Fatalf("Failed to parse record after indented blank line: %s", err)
+		}
+
+		if r.DomainName != domain {
+			t.Fatalf("Expected Record for '%s', got '%s'", domain, r.DomainName)
+		}
+	}
+}
+
+func TestIndentedControlEntryDoesNotIndentNextRecord(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("a.example. 60 IN TXT x\n  $ORIGIN sub.example.\nc 60 IN A 192.0.2.1\n"))
+
+	for _, domain := range []string{"a.example.", "c.sub.example."} {
+		if err := s.Next(&r); err != nil {
+			t.Fatalf("Failed to parse record after indented control entry: %s", err)
+		}
+
+		if r.DomainName != domain {
+			t.Fatalf("Expected Record for '%s', got '%s'", domain, r.DomainName)
+		}
+	}
+}
+
+func TestIndentedFirstRecordFails(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("  300 IN A 192.168.1.1\n"))
+	err := s.Next(&r)
+	if err == nil {
+		t.Fatalf("Parsing of indented record with no previous DomainName did not return an error")
+	}
+}
+
+func TestClasslessRecordInheritsClass(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("adomain.com. 300 CH TXT \"a\"\nwww.adomain.com. 300 TXT \"b\"\n"))
+
+	for i := 0; i < 2; i++ {
+		if err := s.Next(&r); err != nil {
+			t.Fatalf("Failed to parse record: %s", err)
+		}
+
+		if r.Class != RecordClass_CH {
+			t.Fatalf("Record %d did not have the previous record's Class", i)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package gozone
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// DefaultMaxIncludeDepth is the number of nested $INCLUDE control entries a
+// new Scanner will follow before giving up.
+const DefaultMaxIncludeDepth = 16
+
+// IncludeResolver opens the files named by $INCLUDE control entries.
+type IncludeResolver interface {
+	Open(name string) (io.ReadCloser, error)
+}
+
+// IncludeResolverFunc adapts an ordinary function to an IncludeResolver.
+type IncludeResolverFunc func(name string) (io.ReadCloser, error)
+
+func (f IncludeResolverFunc) Open(name string) (io.ReadCloser, error) {
+	return f(name)
+}
+
+type fsIncludeResolver struct {
+	fsys fs.FS
+}
+
+func (r fsIncludeResolver) Open(name string) (io.ReadCloser, error) {
+	return r.fsys.Open(name)
+}
+
+// IncludeFS returns an IncludeResolver which opens $INCLUDE'd files from fsys.
+func IncludeFS(fsys fs.FS) IncludeResolver {
+	return fsIncludeResolver{fsys: fsys}
+}
+
+// the state of a parent file, saved while an $INCLUDE'd file is scanned
+type scannerInclude struct {
+	in       scanBuffer
+	closer   io.Closer
+	fileName string
+	state    scannerState
+	origin   string
+
+	line   int
+	column int
+}
+
+// SetIncludeResolver sets the resolver used to open files named by $INCLUDE
+// control entries. Without a resolver, $INCLUDE is an error.
+func (s *Scanner) SetIncludeResolver(resolver IncludeResolver) {
+	s.resolver = resolver
+}
+
+// SetMaxIncludeDepth limits how deeply $INCLUDE control entries may nest.
+func (s *Scanner) SetMaxIncludeDepth(depth int) error {
+	if depth < 0 {
+		return fmt.Errorf("Tried to set maximum $INCLUDE depth to a negative number")
+	}
+
+	s.maxIncludeDepth = depth
+	return nil
+}
+
+// Close closes any $INCLUDE'd files which are still open. It does not close
+// the io.Reader the Scanner was created with.
+func (s *Scanner) Close() error {
+	var err error
+	for len(s.includes) != 0 {
+		if cerr := s.closer.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		s.restoreInclude()
+	}
+
+	return err
+}
+
+func (s *Scanner) scanControlEntryInclude() error {
+	var fileName string
+	var origin string
+	var token string
+	var err error
+
+	for {
+		if token, err = s.nextToken(); err != nil {
+			if err == io.EOF {
+				if fileName != "" {
+					break
+				}
+
+				return s.errorf(ErrInvalidControlEntry, "", "Incomplete $INCLUDE control entry at end of file")
+			}
+
+			return err
+		}
+
+		if token[0] == ';' {
+			if fileName != "" {
+				// the comment is followed by the end of the line
+				continue
+			}
+
+			return s.errorf(ErrInvalidControlEntry, token, "Incomplete $INCLUDE control entry ends in comment")
+		}
+
+		if token == "\n" {
+			if fileName == "" {
+				return s.errorf(ErrInvalidControlEntry, token, "missing file name in $INCLUDE control entry")
+			}
+			break
+		}
+
+		if fileName == "" {
+			fileName = unquote(token)
+			if fileName == "" {
+				return s.errorf(ErrInvalidControlEntry, token, "Empty file name in $INCLUDE control entry")
+			}
+			continue
+		}
+
+		if origin != "" {
+			return s.errorf(ErrInvalidControlEntry, token, "Too many arguments in $INCLUDE control entry")
+		}
+
+		if token == "@" {
+			if s.origin == "" {
+				return s.errorf(ErrNoOrigin, token, "$INCLUDE of current domain specified when no $ORIGIN defined")
+			}
+			origin = s.origin
+		} else if token[len(token)-1] != '.' {
+			if s.origin == "" {
+				return s.errorf(ErrNoOrigin, token, "$INCLUDE of relative domain specified when no $ORIGIN defined")
+			}
+			origin = qualifyName(token, s.origin)
+		} else {
+			origin = token
+		}
+
+		if _, err = walkLabels(origin, nil); err != nil {
+			return s.wrapError(ErrInvalidName, token, nameError(origin, err))
+		}
+	}
+
+	return s.pushInclude(fileName, origin)
+}
+
+func (s *Scanner) pushInclude(fileName string, origin string) error {
+	if s.resolver == nil {
+		return s.errorf(ErrInclude, fileName, "No IncludeResolver set for $INCLUDE of '%s'", fileName)
+	}
+
+	if len(s.includes) >= s.maxIncludeDepth {
+		return s.errorf(ErrIncludeDepth, fileName, "$INCLUDE of '%s' exceeds maximum depth of %d", fileName, s.maxIncludeDepth)
+	}
+
+	if fileName == s.fileName {
+		return s.errorf(ErrIncludeCycle, fileName, "$INCLUDE cycle: '%s' includes itself", fileName)
+	}
+
+	for _, parent := range s.includes {
+		if parent.fileName == fileName {
+			return s.errorf(ErrIncludeCycle, fileName, "$INCLUDE cycle: '%s' is already being included", fileName)
+		}
+	}
+
+	src, err := s.resolver.Open(fileName)
+	if err != nil {
+		return &ParseError{
+			Position: s.tokenPosition,
+			Token:    fileName,
+			Kind:     ErrInclude,
+			Message:  fmt.Sprintf("Failed to open $INCLUDE file '%s': %s", fileName, err),
+			Err:      err,
+		}
+	}
+
+	s.includes = append(s.includes, scannerInclude{
+		in:       s.in,
+		closer:   s.closer,
+		fileName: s.fileName,
+		state:    s.state,
+		origin:   s.origin,
+
+		line:   s.line,
+		column: s.column,
+	})
+
+	s.in = newScanBuffer(src)
+	s.closer = src
+	s.fileName = fileName
+	s.state = scannerState_Space
+	s.lineIndented = false
+	s.line = 1
+	s.column = 1
+	if origin != "" {
+		s.origin = origin
+	}
+
+	return nil
+}
+
+// popInclude returns to the parent of an $INCLUDE'd file, restoring the
+// parent's $ORIGIN (RFC 1035 section 5.1). It returns false when the current
+// file was not $INCLUDE'd.
+func (s *Scanner) popInclude() bool {
+	if len(s.includes) == 0 {
+		return false
+	}
+
+	_ = s.closer.Close()
+	s.restoreInclude()
+	return true
+}
+
+func (s *Scanner) restoreInclude() {
+	parent := s.includes[len(s.includes)-1]
+	s.includes = s.includes[:len(s.includes)-1]
+
+	s.in = parent.in
+	s.closer = parent.closer
+	s.fileName = parent.fileName
+	s.state = parent.state
+	s.origin = parent.origin
+	s.line = parent.line
+	s.column = parent.column
+}
+
+// unquote strips the quotes and escapes from a quoted token, returning other
+// tokens as-is
+func unquote(token string) string {
+	if len(token) < 2 || token[0] != '"' || token[len(token)-1] != '"' {
+		return token
+	}
+
+	var out strings.Builder
+	inner := token[1 : len(token)-1]
+	for i := 0; i < len(inner); i++ {
+		if inner[i] == '\\' && i+1 < len(inner) {
+			i++
+		}
+		_ = out.WriteByte(inner[i])
+	}
+
+	return out.String()
+}
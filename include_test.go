@@ -0,0 +1,200 @@
+package gozone
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestIncludeControlEntryIncludesFile(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$ORIGIN adomain.com.\n$INCLUDE hosts.zone\nmail 300 IN A 192.168.1.3\n"))
+	s.SetIncludeResolver(IncludeFS(fstest.MapFS{
+		"hosts.zone": &fstest.MapFile{Data: []byte("www 300 IN A 192.168.1.1\nftp 300 IN A 192.168.1.2")},
+	}))
+
+	expected := []string{"www.adomain.com.", "ftp.adomain.com.", "mail.adomain.com."}
+	for _, domain := range expected {
+		if err := s.Next(&r); err != nil {
+			t.Fatalf("Unexpected error when parsing a zone with an $INCLUDE control entry: %s", err)
+		}
+
+		if r.DomainName != domain {
+			t.Fatalf("Expected Record for '%s', got '%s'", domain, r.DomainName)
+		}
+	}
+
+	if err := s.Next(&r); err != io.EOF {
+		t.Fatalf("Parsing of a zone with an $INCLUDE control entry did not end with EOF: %v", err)
+	}
+}
+
+func TestIncludeControlEntryOriginIsRestored(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$ORIGIN adomain.com.\n$INCLUDE sub.zone sub ; a comment\nwww 300 IN A 192.168.1.2\n"))
+	s.SetIncludeResolver(IncludeFS(fstest.MapFS{
+		"sub.zone": &fstest.MapFile{Data: []byte("www 300 IN A 192.168.1.1\n$ORIGIN other.com.\n")},
+	}))
+
+	if err := s.Next(&r); err != nil {
+		t.Fatalf("Unexpected error when parsing an $INCLUDE'd file: %s", err)
+	}
+
+	if r.DomainName != "www.sub.adomain.com." {
+		t.Fatalf("$INCLUDE control entry origin was not applied to the included file, got '%s'", r.DomainName)
+	}
+
+	if err := s.Next(&r); err != nil {
+		t.Fatalf("Unexpected error when parsing after an $INCLUDE'd file: %s", err)
+	}
+
+	if r.DomainName != "www.adomain.com." {
+		t.Fatalf("$ORIGIN of the parent file was not restored after $INCLUDE, got '%s'", r.DomainName)
+	}
+}
+
+func TestIncludeControlEntryOriginUnderRoot(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$ORIGIN .\n$INCLUDE sub.zone sub\n"))
+	s.SetIncludeResolver(IncludeFS(fstest.MapFS{
+		"sub.zone": &fstest.MapFile{Data: []byte("@ 300 IN A 192.168.1.1\n")},
+	}))
+
+	if err := s.Next(&r); err != nil {
+		t.Fatalf("Unexpected error when parsing an $INCLUDE'd file: %s", err)
+	}
+
+	if r.DomainName != "sub." {
+		t.Fatalf("$INCLUDE control entry origin under the root was not 'sub.', got '%s'", r.DomainName)
+	}
+}
+
+func TestIncludeControlEntryInvalidOriginFails(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$ORIGIN adomain.com.\n$INCLUDE sub.zone sub..\n"))
+	s.SetIncludeResolver(IncludeFS(fstest.MapFS{
+		"sub.zone": &fstest.MapFile{Data: []byte("@ 300 IN A 192.168.1.1\n")},
+	}))
+
+	if err := s.Next(&r); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("Parsing of an $INCLUDE control entry with an invalid origin did not return ErrInvalidName: %v", err)
+	}
+}
+
+func TestIncludeControlEntryQuotedFileName(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$INCLUDE \"my hosts.zone\"\n"))
+	s.SetIncludeResolver(IncludeFS(fstest.MapFS{
+		"my hosts.zone": &fstest.MapFile{Data: []byte("www.adomain.com. 300 IN A 192.168.1.1\n")},
+	}))
+
+	if err := s.Next(&r); err != nil {
+		t.Fatalf("Unexpected error when $INCLUDE'ing a quoted file name: %s", err)
+	}
+
+	if r.DomainName != "www.adomain.com." {
+		t.Fatalf("$INCLUDE of a quoted file name did not return the included Record")
+	}
+}
+
+func TestIncludeControlEntryWithoutResolverFails(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$INCLUDE hosts.zone\n"))
+	err := s.Next(&r)
+	if err == nil {
+		t.Fatalf("Parsing of $INCLUDE control entry without a resolver did not return an error")
+	}
+}
+
+func TestIncludeControlEntryMissingFileFails(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$INCLUDE missing.zone\n"))
+	s.SetIncludeResolver(IncludeFS(fstest.MapFS{}))
+	err := s.Next(&r)
+	if err == nil {
+		t.Fatalf("Parsing of $INCLUDE control entry for a missing file did not return an error")
+	}
+}
+
+func TestIncompleteIncludeControlEntry(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$INCLUDE\n"))
+	s.SetIncludeResolver(IncludeFS(fstest.MapFS{}))
+	err := s.Next(&r)
+	if err == nil {
+		t.Fatalf("Parsing of incomplete $INCLUDE control entry did not return an error")
+	}
+}
+
+func TestIncludeControlEntryCycleFails(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$INCLUDE a.zone\n"))
+	s.SetIncludeResolver(IncludeFS(fstest.MapFS{
+		"a.zone": &fstest.MapFile{Data: []byte("$INCLUDE b.zone\n")},
+		"b.zone": &fstest.MapFile{Data: []byte("$INCLUDE a.zone\n")},
+	}))
+
+	err := s.Next(&r)
+	if err == nil {
+		t.Fatalf("Parsing of cyclic $INCLUDE control entries did not return an error")
+	}
+
+	if !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("Parsing of cyclic $INCLUDE control entries did not report a cycle: %s", err)
+	}
+}
+
+func TestIncludeControlEntryDepthFails(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$INCLUDE 1.zone\n"))
+	s.SetIncludeResolver(IncludeResolverFunc(func(name string) (io.ReadCloser, error) {
+		next := len(name) + 1
+		return io.NopCloser(strings.NewReader("$INCLUDE " + strings.Repeat("x", next) + "\n")), nil
+	}))
+
+	if err := s.SetMaxIncludeDepth(4); err != nil {
+		t.Fatalf("Unexpected error when setting maximum $INCLUDE depth: %s", err)
+	}
+
+	err := s.Next(&r)
+	if err == nil {
+		t.Fatalf("Parsing of deeply nested $INCLUDE control entries did not return an error")
+	}
+
+	if !strings.Contains(err.Error(), "depth") {
+		t.Fatalf("Parsing of deeply nested $INCLUDE control entries did not report the depth: %s", err)
+	}
+}
+
+func TestScannerCloseClosesIncludes(t *testing.T) {
+	var r Record
+	closed := 0
+	s := NewScanner(strings.NewReader("$INCLUDE a.zone\n"))
+	s.SetIncludeResolver(IncludeResolverFunc(func(name string) (io.ReadCloser, error) {
+		return closeCounter{strings.NewReader("www.adomain.com. 300 IN A 192.168.1.1\nwww.adomain.com. 300 IN A 192.168.1.2\n"), &closed}, nil
+	}))
+
+	if err := s.Next(&r); err != nil {
+		t.Fatalf("Unexpected error when parsing an $INCLUDE'd file: %s", err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Unexpected error when closing Scanner: %s", err)
+	}
+
+	if closed != 1 {
+		t.Fatalf("Closing the Scanner did not close the open $INCLUDE'd file")
+	}
+}
+
+type closeCounter struct {
+	io.Reader
+	closed *int
+}
+
+func (c closeCounter) Close() error {
+	*c.closed++
+	return nil
+}
@@ -0,0 +1,378 @@
+package gozone
+
+import (
+	"io"
+)
+
+// the size of the buffer a Scanner reads its input into. Tokens are sliced
+// straight out of it, so it only grows to hold a token longer than this.
+const scanBufferSize = 256 << 10
+
+// the number of Data fields allocated at once, to be shared out between the
+// records a Scanner returns
+const dataBlockSize = 1024
+
+// the number of distinct tokens a Scanner remembers reading as a class or
+// type
+const maxTokenKinds = 256
+
+var (
+	tokenNewline = []byte("\n")
+	tokenBlank   = []byte(" ")
+	tokenOpen    = []byte("(")
+	tokenClose   = []byte(")")
+)
+
+// scanBuffer holds input read from src, which tokens are sliced out of
+// without copying
+type scanBuffer struct {
+	src  io.Reader
+	buf  []byte
+	off  int   // the next byte to be scanned
+	end  int   // the end of the input read into buf
+	mark int   // the start of the token being scanned, or -1
+	err  error // the error which ended reading from src
+}
+
+func newScanBuffer(src io.Reader) scanBuffer {
+	return scanBuffer{src: src, mark: -1}
+}
+
+// fill reads more input into the buffer, keeping the token being scanned,
+// and reports whether any was read
+func (b *scanBuffer) fill() bool {
+	if b.err != nil {
+		return false
+	}
+
+	if b.buf == nil {
+		size := scanBufferSize
+		if sized, ok := b.src.(interface{ Len() int }); ok && sized.Len() < size {
+			// an input smaller than the buffer needs no more room than itself
+			size = max(sized.Len(), 512)
+		}
+		b.buf = make([]byte, size)
+	}
+
+	keep := b.off
+	if b.mark >= 0 {
+		keep = b.mark
+	}
+
+	if keep > 0 {
+		b.end = copy(b.buf, b.buf[keep:b.end])
+		b.off -= keep
+		if b.mark >= 0 {
+			b.mark -= keep
+		}
+	}
+
+	if b.end == len(b.buf) {
+		b.buf = append(b.buf, make([]byte, len(b.buf))...)
+		b.buf = b.buf[:cap(b.buf)]
+	}
+
+	for range 100 {
+		n, err := b.src.Read(b.buf[b.end:])
+		b.end += n
+		if err != nil {
+			b.err = err
+		}
+
+		if n > 0 {
+			return true
+		}
+
+		if err != nil {
+			return false
+		}
+	}
+
+	b.err = io.ErrNoProgress
+	return false
+}
+
+// tokenKind records how a token before the record data reads as a class or
+// type
+type tokenKind struct {
+	class   RecordClass
+	rtype   RecordType
+	isClass bool
+	isType  bool
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// isToken reports whether a token is the given text, without allocating
+func isToken(token []byte, text string) bool {
+	return string(token) == text
+}
+
+// nextTokenBytes returns the next token, which is only valid until the next
+// token is read
+func (s *Scanner) nextTokenBytes() ([]byte, error) {
+	token, err := s.scanToken()
+	s.lineEnded = err == nil && isToken(token, "\n")
+	return token, err
+}
+
+func (s *Scanner) nextToken() (string, error) {
+	token, err := s.nextTokenBytes()
+	return string(token), err
+}
+
+// advance moves past the byte c at the current position
+func (s *Scanner) advance(c byte) {
+	s.in.off++
+	if c == '\n' {
+		s.line++
+		s.column = 1
+	} else if c&0xC0 != 0x80 {
+		// columns count characters, rather than the bytes of UTF-8
+		s.column++
+	}
+}
+
+// extend adds the byte c at the current position to the token being scanned
+func (s *Scanner) extend(c byte) {
+	if s.in.mark < 0 {
+		s.in.mark = s.in.off
+	}
+	s.advance(c)
+}
+
+// take ends the token being scanned, and returns it
+func (s *Scanner) take() []byte {
+	token := s.in.buf[s.in.mark:s.in.off]
+	s.in.mark = -1
+	return token
+}
+
+// scanToken returns the next token, sliced out of the input buffer. Line
+// ends, parentheses and the blank starting an indented line are tokens of
+// their own; quoted strings and comments are single tokens, quotes and
+// semicolon included; escapes are left as written. Tokens are separated by
+// ASCII whitespace.
+func (s *Scanner) scanToken() ([]byte, error) {
+	in := &s.in
+	in.mark = -1
+
+	for {
+		if in.off == in.end && !in.fill() {
+			return s.scanEnd()
+		}
+
+		c := in.buf[in.off]
+		if in.mark < 0 {
+			s.tokenPosition = Position{File: s.fileName, Line: s.line, Column: s.column}
+		}
+
+		switch s.state {
+		case scannerState_Default, scannerState_Paren:
+			if isSpace(c) {
+				if in.mark >= 0 {
+					return s.take(), nil
+				}
+
+				s.advance(c)
+				if s.state == scannerState_Default && c == '\n' {
+					s.state = scannerState_Space
+					return tokenNewline, nil
+				}
+
+				// ignore whitespace between tokens
+				continue
+			}
+
+			switch {
+			case c == '(' && s.state == scannerState_Default:
+				if in.mark >= 0 {
+					return s.take(), nil
+				}
+
+				s.advance(c)
+				s.state = scannerState_Paren
+				return tokenOpen, nil
+
+			case c == ')' && s.state == scannerState_Paren:
+				if in.mark >= 0 {
+					return s.take(), nil
+				}
+
+				s.advance(c)
+				s.state = scannerState_Default
+				return tokenClose, nil
+
+			case c == '\\':
+				s.extend(c)
+				if s.state == scannerState_Default {
+					s.state = scannerState_DefaultEscape
+				} else {
+					s.state = scannerState_ParenEscape
+				}
+
+			case c == '"':
+				if in.mark >= 0 {
+					return s.take(), nil
+				}
+
+				s.extend(c)
+				if s.state == scannerState_Default {
+					s.state = scannerState_String
+				} else {
+					s.state = scannerState_ParenString
+				}
+
+			case c == ';':
+				if in.mark >= 0 {
+					return s.take(), nil
+				}
+
+				s.extend(c)
+				if s.state == scannerState_Default {
+					s.state = scannerState_Comment
+				} else {
+					s.state = scannerState_ParenComment
+				}
+
+			default:
+				s.extend(c)
+			}
+
+		case scannerState_String, scannerState_ParenString:
+			s.extend(c)
+			if c == '"' {
+				if s.state == scannerState_String {
+					s.state = scannerState_Default
+				} else {
+					s.state = scannerState_Paren
+				}
+				return s.take(), nil
+			}
+
+			if c == '\\' {
+				if s.state == scannerState_String {
+					s.state = scannerState_StringEscape
+				} else {
+					s.state = scannerState_ParenStringEscape
+				}
+			}
+
+		case scannerState_DefaultEscape, scannerState_StringEscape, scannerState_ParenEscape, scannerState_ParenStringEscape:
+			s.extend(c)
+			switch s.state {
+			case scannerState_DefaultEscape:
+				s.state = scannerState_Default
+			case scannerState_StringEscape:
+				s.state = scannerState_String
+			case scannerState_ParenEscape:
+				s.state = scannerState_Paren
+			case scannerState_ParenStringEscape:
+				s.state = scannerState_ParenString
+			}
+
+		case scannerState_Comment, scannerState_ParenComment:
+			if c == '\n' {
+				// the line end is read again, to end the comment
+				if s.state == scannerState_Comment {
+					s.state = scannerState_Default
+				} else {
+					s.state = scannerState_Paren
+				}
+				continue
+			}
+
+			s.extend(c)
+
+		case scannerState_Space:
+			if isSpace(c) {
+				s.advance(c)
+				s.lineIndented = c != '\n'
+				continue
+			}
+
+			s.state = scannerState_Default
+			if s.lineIndented {
+				// a line starting with a blank uses the previous owner
+				s.lineIndented = false
+				return tokenBlank, nil
+			}
+		}
+	}
+}
+
+// scanEnd finishes scanning once no more input can be read: it returns the
+// last token of the input, then a line end for the end of an $INCLUDE'd
+// file, or else io.EOF
+func (s *Scanner) scanEnd() ([]byte, error) {
+	in := &s.in
+	if in.err != io.EOF {
+		return nil, in.err
+	}
+
+	if in.mark < 0 {
+		s.tokenPosition = Position{File: s.fileName, Line: s.line, Column: s.column}
+	}
+
+	if s.state != scannerState_Default &&
+		s.state != scannerState_Space &&
+		s.state != scannerState_Comment {
+		var token string
+		if in.mark >= 0 {
+			token = string(s.take())
+		}
+		return nil, s.errorf(ErrUnexpectedEOF, token, "Unexpected end of input")
+	}
+
+	if in.mark >= 0 {
+		return s.take(), nil
+	}
+
+	// the end of an $INCLUDE'd file ends the current line
+	if s.popInclude() {
+		return tokenNewline, nil
+	}
+
+	return nil, io.EOF
+}
+
+// kindOf reads a token as a class or type, remembering the answer so that
+// the handful of distinct tokens in a zone are only converted to strings
+// once
+func (s *Scanner) kindOf(token []byte) tokenKind {
+	if kind, ok := s.kinds[string(token)]; ok {
+		return kind
+	}
+
+	name := string(token)
+	var kind tokenKind
+	var err error
+	kind.class, err = parseClass(name)
+	kind.isClass = err == nil
+	kind.rtype, err = parseType(name)
+	kind.isType = err == nil
+
+	if len(s.kinds) < maxTokenKinds {
+		if s.kinds == nil {
+			s.kinds = make(map[string]tokenKind)
+		}
+		s.kinds[name] = kind
+	}
+
+	return kind
+}
+
+// allocData returns room for the n Data fields of a record, out of a block
+// shared with other records. Its capacity is limited, so that appending to
+// it never writes into the room of another record.
+func (s *Scanner) allocData(n int) []string {
+	if n > len(s.dataBlock) {
+		s.dataBlock = make([]string, max(n, dataBlockSize))
+	}
+
+	data := s.dataBlock[:n:n]
+	s.dataBlock = s.dataBlock[n:]
+	return data
+}
@@ -0,0 +1,175 @@
+package gozone
+
+import (
+	"fmt"
+	"sort"
+)
+
+type Severity int
+
+const (
+	Severity_Warning Severity = iota // allowed, but likely a mistake
+	Severity_Error                   // violates the RFCs
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Severity_Warning:
+		return "warning"
+	case Severity_Error:
+		return "error"
+	}
+
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// The rule IDs of Lint diagnostics
+const (
+	LintRule_MultipleSOA    = "multiple-soa"    // RFC 1035 section 5.2: a second SOA, or one below the top of the zone
+	LintRule_NoSOA          = "no-soa"          // RFC 1035 section 5.2: the zone has no SOA at all
+	LintRule_CNAMEAndOther  = "cname-and-other" // RFC 1034 section 3.6.2: a CNAME owner has no other data
+	LintRule_NoApexNS       = "no-apex-ns"      // RFC 1035 section 5.2: NS records at the top of the zone
+	LintRule_OutOfZone      = "out-of-zone"     // RFC 1035 section 5.2: every record is within the zone
+	LintRule_MixedTTL       = "mixed-ttl"       // RFC 2181 section 5.2: the records of an RRset have one TTL
+	LintRule_MultipleCNAMEs = "multiple-cnames" // RFC 2181 section 10.1: an alias has exactly one CNAME
+)
+
+// Diagnostic is a problem found by Lint.
+type Diagnostic struct {
+	Position
+	Severity Severity
+	Rule     string
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s [%s]", d.Position, d.Severity, d.Message, d.Rule)
+}
+
+// Lint checks a zone for records which are each valid, but together break
+// the rules of RFC 1034, 1035 and 2181. Diagnostics are ordered by position.
+func Lint(z *Zone) []Diagnostic {
+	var diagnostics []Diagnostic
+	report := func(record Record, severity Severity, rule string, format string, args ...interface{}) {
+		diagnostics = append(diagnostics, Diagnostic{
+			Position: record.Position,
+			Severity: severity,
+			Rule:     rule,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	var soa *Record
+	owners := make(map[string][]*RRset)
+	var ownerOrder []string
+	for _, rrset := range z.RRsets() {
+		labels, _ := nameLabels(rrset.DomainName)
+		if !isSubdomain(labels, z.labels) {
+			for _, record := range rrset.Records {
+				report(record, Severity_Error, LintRule_OutOfZone, "%s record for %s is outside of the zone %s", record.Type, record.DomainName, z.origin)
+			}
+			continue
+		}
+
+		key := labelsKey(labels)
+		if owners[key] == nil {
+			ownerOrder = append(ownerOrder, key)
+		}
+		owners[key] = append(owners[key], rrset)
+
+		for _, record := range rrset.Records[1:] {
+			if record.TimeToLive != rrset.Records[0].TimeToLive {
+				report(record, Severity_Warning, LintRule_MixedTTL, "%s %s record has TTL %d, but the first record of its RRset has TTL %d",
+					record.DomainName, record.Type, record.TimeToLive, rrset.Records[0].TimeToLive)
+			}
+		}
+
+		if rrset.Type == RecordType_SOA {
+			for i, record := range rrset.Records {
+				if soa == nil {
+					soa = &rrset.Records[i]
+					if key != labelsKey(z.labels) {
+						report(record, Severity_Error, LintRule_MultipleSOA, "SOA record for %s is not at the top of the zone %s", record.DomainName, z.origin)
+					}
+					continue
+				}
+				report(record, Severity_Error, LintRule_MultipleSOA, "Zone has more than one SOA record, the first is at %s", soa.Position)
+			}
+		}
+	}
+
+	for _, key := range ownerOrder {
+		var cname *RRset
+		for _, rrset := range owners[key] {
+			if rrset.Type == RecordType_CNAME {
+				cname = rrset
+			}
+		}
+
+		if cname == nil {
+			continue
+		}
+
+		for _, record := range cname.Records[1:] {
+			report(record, Severity_Error, LintRule_MultipleCNAMEs, "%s has more than one CNAME record", record.DomainName)
+		}
+
+		for _, rrset := range owners[key] {
+			switch rrset.Type {
+			case RecordType_CNAME, RecordType_RRSIG, RecordType_NSEC:
+				// RFC 4035 section 2.5 allows DNSSEC records alongside a CNAME
+				continue
+			}
+
+			for _, record := range rrset.Records {
+				report(record, Severity_Error, LintRule_CNAMEAndOther, "%s has a CNAME record, so cannot also have %s records", record.DomainName, record.Type)
+			}
+		}
+	}
+
+	// problems of the zone as a whole are reported at its SOA, when it has one
+	var top Record
+	if soa == nil {
+		report(top, Severity_Error, LintRule_NoSOA, "Zone %s has no SOA record", z.origin)
+	} else {
+		top = *soa
+	}
+
+	if !hasApexNS(z.nodes[labelsKey(z.labels)], soa) {
+		report(top, Severity_Error, LintRule_NoApexNS, "Zone %s has no NS records at its top", z.origin)
+	}
+
+	sortDiagnostics(diagnostics)
+	return diagnostics
+}
+
+// hasApexNS reports whether the top of the zone has NS records, of the class
+// of the SOA when there is one
+func hasApexNS(apex *zoneNode, soa *Record) bool {
+	if soa != nil {
+		return apex.rrset(soa.Class, RecordType_NS) != nil
+	}
+
+	if apex != nil {
+		for _, rrset := range apex.rrsets {
+			if rrset.Type == RecordType_NS {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func sortDiagnostics(diagnostics []Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i].Position, diagnostics[j].Position
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
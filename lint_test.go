@@ -0,0 +1,104 @@
+package gozone
+
+import (
+	"strings"
+	"testing"
+)
+
+func lintZone(t *testing.T, zone string) []Diagnostic {
+	t.Helper()
+
+	z, err := LoadZone(NewScanner(strings.NewReader(zone)))
+	if err != nil {
+		t.Fatalf("Failed to load zone: %s", err)
+	}
+
+	return Lint(z)
+}
+
+func TestLintCleanZone(t *testing.T) {
+	diagnostics := lintZone(t, `$ORIGIN adomain.com.
+@ 3600 IN SOA ns hostmaster 1 10800 3600 604800 300
+@ 3600 IN NS ns.adomain.com.
+ns 3600 IN A 192.168.0.53
+www 3600 IN CNAME ns.adomain.com.
+www 3600 IN RRSIG CNAME 8 3 3600 20300101000000 20200101000000 12345 adomain.com. abcd
+`)
+
+	if len(diagnostics) != 0 {
+		t.Fatalf("Lint of a clean zone returned diagnostics: %v", diagnostics)
+	}
+}
+
+func TestLintDiagnostics(t *testing.T) {
+	diagnostics := lintZone(t, `$ORIGIN adomain.com.
+@ 3600 IN SOA ns hostmaster 1 10800 3600 604800 300
+www 3600 IN A 192.168.0.1
+www 300 IN A 192.168.0.2
+alias 3600 IN CNAME www.adomain.com.
+alias 3600 IN TXT "other"
+alias 3600 IN CNAME ns.adomain.com.
+@ 3600 IN SOA ns hostmaster 2 10800 3600 604800 300
+elsewhere.com. 3600 IN A 192.168.0.3
+`)
+
+	expected := []string{
+		"2:1: error: Zone adomain.com. has no NS records at its top [no-apex-ns]",
+		"4:1: warning: www.adomain.com. A record has TTL 300, but the first record of its RRset has TTL 3600 [mixed-ttl]",
+		"6:1: error: alias.adomain.com. has a CNAME record, so cannot also have TXT records [cname-and-other]",
+		"7:1: error: alias.adomain.com. has more than one CNAME record [multiple-cnames]",
+		"8:1: error: Zone has more than one SOA record, the first is at 2:1 [multiple-soa]",
+		"9:1: error: A record for elsewhere.com. is outside of the zone adomain.com. [out-of-zone]",
+	}
+
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("Lint returned %d diagnostics, expected %d: %v", len(diagnostics), len(expected), diagnostics)
+	}
+
+	for i, d := range diagnostics {
+		if d.String() != expected[i] {
+			t.Fatalf("Lint diagnostic %d was [%s], expected [%s]", i, d, expected[i])
+		}
+	}
+}
+
+func TestLintSOABelowApex(t *testing.T) {
+	z, err := NewZone("adomain.com.")
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+
+	if err = z.Add(parseOneRecord(t, "sub.adomain.com. 3600 IN SOA ns.adomain.com. hostmaster.adomain.com. 1 2 3 4 5")); err != nil {
+		t.Fatalf("Failed to add record: %s", err)
+	}
+
+	diagnostics := Lint(z)
+	if len(diagnostics) != 2 || diagnostics[0].Rule != LintRule_MultipleSOA || diagnostics[1].Rule != LintRule_NoApexNS {
+		t.Fatalf("Lint of a zone with its SOA below the top returned %v", diagnostics)
+	}
+}
+
+func TestLintNoSOA(t *testing.T) {
+	z, err := NewZone("adomain.com.")
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+
+	if err = z.Add(parseOneRecord(t, "www.adomain.com. 3600 IN A 192.168.0.1")); err != nil {
+		t.Fatalf("Failed to add record: %s", err)
+	}
+
+	diagnostics := Lint(z)
+	if len(diagnostics) != 2 || diagnostics[0].Rule != LintRule_NoSOA || diagnostics[1].Rule != LintRule_NoApexNS {
+		t.Fatalf("Lint of a zone without an SOA returned %v", diagnostics)
+	}
+
+	if err = z.Add(parseOneRecord(t, "adomain.com. 3600 IN NS ns.adomain.com.")); err != nil {
+		t.Fatalf("Failed to add record: %s", err)
+	}
+
+	diagnostics = Lint(z)
+	if len(diagnostics) != 1 || diagnostics[0].Rule != LintRule_NoSOA {
+		t.Fatalf("Lint of a zone with NS records but no SOA returned %v", diagnostics)
+	}
+}
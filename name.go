@@ -0,0 +1,211 @@
+package gozone
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Name is a domain name as its labels, with the \X and \DDD escapes of the
+// presentation format decoded, so that a\.b.example. (two labels under
+// example.) and a.b.example. (three labels) are told apart. A Name is
+// absolute when it ends at the root, and relative otherwise; the zero Name
+// is the empty relative name, written as "@".
+type Name struct {
+	labels   []string // decoded, leftmost first
+	absolute bool
+}
+
+// RootName is the absolute name of the root, "."
+var RootName = Name{absolute: true}
+
+// ParseName reads a domain name in presentation format, resolving its
+// escapes. Names ending in an unescaped dot are absolute. Labels longer
+// than MaxLabelLength bytes, and names longer than MaxNameLength bytes in
+// wire format, are rejected.
+func ParseName(name string) (Name, error) {
+	labels, absolute, err := parseLabels(name)
+	if err != nil {
+		return Name{}, nameError(name, err)
+	}
+
+	return Name{labels: labels, absolute: absolute}, nil
+}
+
+func nameError(name string, err error) error {
+	return fmt.Errorf("Domain name '%s' %w: %w", name, ErrInvalidName, err)
+}
+
+// parseLabels splits a domain name in presentation format into its decoded
+// labels, reporting whether it is absolute
+func parseLabels(name string) ([]string, bool, error) {
+	var labels []string
+	absolute, err := walkLabels(name, func(label string) {
+		decoded, _ := decodeEscapes(label)
+		labels = append(labels, decoded)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return labels, absolute, nil
+}
+
+// walkLabels checks a domain name in presentation format against the limits
+// on labels and names, calling visit, when it is not nil, with each label as
+// written. It reports whether the name is absolute, and allocates nothing
+// for a valid name, so that the Scanner can check names still held as bytes.
+func walkLabels[T string | []byte](name T, visit func(T)) (bool, error) {
+	if len(name) == 0 {
+		return false, fmt.Errorf("it is empty")
+	}
+
+	if len(name) == 1 && name[0] == '.' {
+		return true, nil
+	}
+
+	length := 1 // the root label
+	start := 0
+	label := 0 // bytes in the current label, with escapes decoded
+	for i := 0; i <= len(name); i++ {
+		if i < len(name) && name[i] == '\\' {
+			switch {
+			case i+1 >= len(name):
+				return false, fmt.Errorf("it has a dangling escape")
+			case isDigit(name[i+1]):
+				if i+3 >= len(name) || !isDigit(name[i+2]) || !isDigit(name[i+3]) {
+					return false, fmt.Errorf("it has an incomplete \\DDD escape")
+				}
+
+				if int(name[i+1]-'0')*100+int(name[i+2]-'0')*10+int(name[i+3]-'0') > 255 {
+					return false, fmt.Errorf("it has a \\DDD escape out of range")
+				}
+				i += 3
+			default:
+				i++
+			}
+			label++
+			continue
+		}
+
+		if i < len(name) && name[i] != '.' {
+			label++
+			continue
+		}
+
+		// a name ending in an unescaped dot is absolute
+		if i == len(name) && start == len(name) {
+			return true, nil
+		}
+
+		if label == 0 {
+			return false, fmt.Errorf("it has an empty label")
+		}
+
+		if label > MaxLabelLength {
+			return false, fmt.Errorf("label is %d bytes long, the maximum is %d", label, MaxLabelLength)
+		}
+
+		length += 1 + label
+		if length > MaxNameLength {
+			return false, fmt.Errorf("it is longer than %d bytes", MaxNameLength)
+		}
+
+		if visit != nil {
+			visit(name[start:i])
+		}
+		start = i + 1
+		label = 0
+	}
+
+	return false, nil
+}
+
+// Name decodes the owner of the record into a Name. The Scanner checks
+// owners against the limits on names as it reads them, so only records
+// built or changed by hand can fail to decode.
+func (r Record) Name() (Name, error) {
+	return ParseName(r.DomainName)
+}
+
+// Labels returns the decoded labels of the name, leftmost first
+func (n Name) Labels() []string {
+	return append([]string(nil), n.labels...)
+}
+
+// IsAbsolute reports whether the name ends at the root
+func (n Name) IsAbsolute() bool {
+	return n.absolute
+}
+
+// Parent returns the name with its leftmost label removed. The parent of
+// the root, or of the empty relative name, is itself.
+func (n Name) Parent() Name {
+	if len(n.labels) == 0 {
+		return n
+	}
+
+	return Name{labels: n.labels[1:], absolute: n.absolute}
+}
+
+// IsSubdomainOf reports whether the name is at or below parent, comparing
+// labels case-insensitively. An absolute name is never below a relative one,
+// nor the other way around.
+func (n Name) IsSubdomainOf(parent Name) bool {
+	if n.absolute != parent.absolute || len(n.labels) < len(parent.labels) {
+		return false
+	}
+
+	return equalLabels(n.labels[len(n.labels)-len(parent.labels):], parent.labels)
+}
+
+// Relativize returns the name relative to origin, when it is at or below
+// origin; otherwise it returns the name unchanged. The origin itself becomes
+// the empty relative name, written as "@".
+func (n Name) Relativize(origin Name) Name {
+	if !n.absolute || !n.IsSubdomainOf(origin) {
+		return n
+	}
+
+	return Name{labels: n.labels[:len(n.labels)-len(origin.labels)]}
+}
+
+// Equal reports whether two names are the same, comparing ASCII letters
+// case-insensitively, as RFC 4343
+func (n Name) Equal(other Name) bool {
+	return n.absolute == other.absolute && len(n.labels) == len(other.labels) && equalLabels(n.labels, other.labels)
+}
+
+func equalLabels(a, b []string) bool {
+	for i := range a {
+		if lowerASCII([]byte(a[i])) != lowerASCII([]byte(b[i])) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String writes the name in presentation format, escaping the bytes of
+// labels which would otherwise be read differently
+func (n Name) String() string {
+	if len(n.labels) == 0 {
+		if n.absolute {
+			return "."
+		}
+		return "@"
+	}
+
+	var out strings.Builder
+	for i, label := range n.labels {
+		if i > 0 {
+			_ = out.WriteByte('.')
+		}
+		writeLabel(&out, []byte(label))
+	}
+
+	if n.absolute {
+		_ = out.WriteByte('.')
+	}
+
+	return out.String()
+}
@@ -0,0 +1,154 @@
+package gozone
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseName(t *testing.T) {
+	names := []struct {
+		name     string
+		labels   []string
+		absolute bool
+		str      string
+	}{
+		{".", nil, true, "."},
+		{"www.example.com.", []string{"www", "example", "com"}, true, "www.example.com."},
+		{`a\.b.example.com.`, []string{"a.b", "example", "com"}, true, `a\.b.example.com.`},
+		{`\065\066c.example.`, []string{"ABc", "example"}, true, "ABc.example."},
+		{`\000\255.example.`, []string{"\x00\xff", "example"}, true, `\000\255.example.`},
+		{`a\ b.example.`, []string{"a b", "example"}, true, `a\ b.example.`},
+		{"www", []string{"www"}, false, "www"},
+		{`www\.`, []string{"www."}, false, `www\.`},
+		{`a\\.b`, []string{`a\`, "b"}, false, `a\\.b`},
+	}
+
+	for _, n := range names {
+		name, err := ParseName(n.name)
+		if err != nil {
+			t.Fatalf("Failed to parse '%s': %s", n.name, err)
+		}
+
+		if !reflect.DeepEqual(name.Labels(), n.labels) || name.IsAbsolute() != n.absolute {
+			t.Fatalf("'%s' parsed as %q (absolute %t), expected %q (absolute %t)", n.name, name.Labels(), name.IsAbsolute(), n.labels, n.absolute)
+		}
+
+		if name.String() != n.str {
+			t.Fatalf("'%s' was written as '%s', expected '%s'", n.name, name, n.str)
+		}
+	}
+}
+
+func TestParseNameErrors(t *testing.T) {
+	names := []string{
+		"",
+		"a..example.",
+		".example.",
+		`a\`,
+		`a\12.example.`,
+		`a\256.example.`,
+		strings.Repeat("a", 64) + ".example.",
+		strings.Repeat(strings.Repeat("a", 63)+".", 4),
+	}
+
+	for _, n := range names {
+		if name, err := ParseName(n); !errors.Is(err, ErrInvalidName) {
+			t.Fatalf("Parsing '%s' should have failed, got %q, %v", n, name.Labels(), err)
+		}
+	}
+
+	if _, err := ParseName(strings.Repeat("a.", 127)); err != nil {
+		t.Fatalf("A name of exactly %d bytes should have parsed, got %s", MaxNameLength, err)
+	}
+}
+
+func TestNameOperations(t *testing.T) {
+	parse := func(s string) Name {
+		name, err := ParseName(s)
+		if err != nil {
+			t.Fatalf("Failed to parse '%s': %s", s, err)
+		}
+		return name
+	}
+
+	www := parse("WWW.Example.COM.")
+	origin := parse("example.com.")
+
+	if !www.Equal(parse("www.example.com.")) {
+		t.Fatalf("Names differing only in case should be equal")
+	}
+
+	if www.Equal(parse("www.example.com")) || parse(`a\.b.example.`).Equal(parse("a.b.example.")) {
+		t.Fatalf("Different names should not be equal")
+	}
+
+	if parent := www.Parent(); parent.String() != "Example.COM." {
+		t.Fatalf("Parent of %s was %s", www, parent)
+	}
+
+	if parent := RootName.Parent(); !parent.Equal(RootName) {
+		t.Fatalf("Parent of the root was %s", parent)
+	}
+
+	if !www.IsSubdomainOf(origin) || !origin.IsSubdomainOf(origin) || !www.IsSubdomainOf(RootName) {
+		t.Fatalf("%s should be a subdomain of %s and the root", www, origin)
+	}
+
+	if origin.IsSubdomainOf(www) || parse("www.example.org.").IsSubdomainOf(origin) || parse("www.example").IsSubdomainOf(origin) {
+		t.Fatalf("Names outside the parent should not be subdomains")
+	}
+
+	if parse(`b\.example.com.`).IsSubdomainOf(origin) {
+		t.Fatalf("A label with an escaped dot should not be split")
+	}
+
+	relativized := []struct{ name, expected string }{
+		{"WWW.Example.COM.", "WWW"},
+		{"a.b.example.com.", "a.b"},
+		{"example.com.", "@"},
+		{"www.example.org.", "www.example.org."},
+		{"www", "www"},
+	}
+
+	for _, r := range relativized {
+		if got := parse(r.name).Relativize(origin).String(); got != r.expected {
+			t.Fatalf("%s relative to %s was %s, expected %s", r.name, origin, got, r.expected)
+		}
+	}
+}
+
+func TestScannerNames(t *testing.T) {
+	s := NewScanner(strings.NewReader("$ORIGIN example.com.\na\\.b 300 IN A 192.0.2.1\n"))
+
+	var r Record
+	if err := s.Next(&r); err != nil {
+		t.Fatalf("Failed to scan record: %s", err)
+	}
+
+	name, err := r.Name()
+	if err != nil {
+		t.Fatalf("Failed to read owner of record: %s", err)
+	}
+
+	if expected := []string{"a.b", "example", "com"}; !reflect.DeepEqual(name.Labels(), expected) {
+		t.Fatalf("Owner had labels %q, expected %q", name.Labels(), expected)
+	}
+
+	s = NewScanner(strings.NewReader("$ORIGIN .\nwww 300 IN A 192.0.2.1\n"))
+	if err := s.Next(&r); err != nil || r.DomainName != "www." {
+		t.Fatalf("Record relative to the root was '%s', %v", r.DomainName, err)
+	}
+
+	for _, zone := range []string{
+		strings.Repeat("a", 64) + ".example.com. 300 IN A 192.0.2.1\n",
+		"a..example.com. 300 IN A 192.0.2.1\n",
+		"$ORIGIN example.com.\n" + strings.Repeat(strings.Repeat("a", 63)+".", 3) + strings.Repeat("a", 50) + " 300 IN A 192.0.2.1\n",
+	} {
+		s = NewScanner(strings.NewReader(zone))
+		if err := s.Next(&r); !errors.Is(err, ErrInvalidName) {
+			t.Fatalf("Scanning [%s] should have failed with an invalid name, got %v", zone, err)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package gozone
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"iter"
+	"runtime"
+)
+
+// the least number of bytes in each chunk a ParallelLoader parses, unless
+// set otherwise
+const DefaultChunkSize = 4 << 20
+
+// the size of the reads a ParallelLoader makes from its input
+const loaderReadSize = 256 << 10
+
+// a class given to records which inherit the class of a record in an earlier
+// chunk, until that chunk has been parsed
+const recordClass_pending RecordClass = -1
+
+// ParallelLoader reads a zone file by splitting it into chunks, which are
+// parsed at the same time, and returns the records in the order they appear
+// in the file, as a Scanner would.
+//
+// Chunks are split at line ends outside of parentheses, quotes and
+// comments. The $ORIGIN and $TTL in effect at the start of each chunk are
+// worked out while splitting; the owner and class inherited from the
+// previous record are filled in once the chunk before has been parsed.
+// $INCLUDE is not supported, and is reported as an error when reached.
+type ParallelLoader struct {
+	src               io.Reader
+	workers           int
+	chunkSize         int
+	fileName          string
+	origin            string
+	keepRelativeNames bool
+}
+
+func NewParallelLoader(src io.Reader) *ParallelLoader {
+	return &ParallelLoader{
+		src:       src,
+		workers:   runtime.GOMAXPROCS(0),
+		chunkSize: DefaultChunkSize,
+	}
+}
+
+// SetWorkers sets the number of chunks parsed at the same time, which is
+// GOMAXPROCS by default.
+func (l *ParallelLoader) SetWorkers(workers int) {
+	l.workers = max(workers, 1)
+}
+
+// SetChunkSize sets the least number of bytes in each chunk; a chunk runs on
+// to the end of the record which crosses this size.
+func (l *ParallelLoader) SetChunkSize(size int) {
+	l.chunkSize = max(size, 1)
+}
+
+// SetFileName records the name of the file being read, for use in the
+// Position of records and errors.
+func (l *ParallelLoader) SetFileName(name string) {
+	l.fileName = name
+}
+
+// SetOrigin sets the $ORIGIN at the start of the file, as Scanner.SetOrigin.
+func (l *ParallelLoader) SetOrigin(domain string) error {
+	if domain[len(domain)-1] != '.' {
+		return fmt.Errorf("Tried to set $ORIGIN to relative domain")
+	}
+
+	l.origin = domain
+	return nil
+}
+
+// SetKeepRelativeNames chooses whether relative domain names within record
+// data are kept as written, as Scanner.SetKeepRelativeNames.
+func (l *ParallelLoader) SetKeepRelativeNames(keep bool) {
+	l.keepRelativeNames = keep
+}
+
+// zoneChunk is a piece of a zone file ending at a line end, along with the
+// state a Scanner would be in at its start, and the records parsed from it
+type zoneChunk struct {
+	data       []byte
+	line       int
+	origin     string
+	timeToLive int64
+	first      bool
+	end        error // the error which ended reading, after this chunk
+
+	records    []Record
+	err        error
+	lastOwner  string // the owner of the last record, or "" if inherited
+	ownerError error  // the error for the first record inheriting an owner, if there is none
+	lastClass  RecordClass
+	done       chan struct{}
+}
+
+// Records returns an iterator over the records of the zone file, in the
+// order they appear. Iteration ends when the input is exhausted, or after
+// yielding the first error, with a zero Record, as Scanner.Records. Breaking
+// out of the loop stops the parsing of further chunks; the loader cannot be
+// iterated again.
+func (l *ParallelLoader) Records() iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		stop := make(chan struct{})
+		defer close(stop)
+
+		// chunks are handed to the workers, and to this loop in the
+		// order they were split, to wait for their records
+		jobs := make(chan *zoneChunk)
+		order := make(chan *zoneChunk, l.workers)
+		go l.split(jobs, order, stop)
+		for range l.workers {
+			go func() {
+				for chunk := range jobs {
+					l.parse(chunk)
+					close(chunk.done)
+				}
+			}()
+		}
+
+		var owner string
+		var class RecordClass = RecordClass_UNKNOWN
+		for chunk := range order {
+			<-chunk.done
+			for _, record := range chunk.records {
+				if record.DomainName == "" {
+					if owner == "" {
+						yield(Record{}, chunk.ownerError)
+						return
+					}
+					record.DomainName = owner
+				}
+
+				if record.Class == recordClass_pending {
+					record.Class = class
+				}
+
+				if !yield(record, nil) {
+					return
+				}
+			}
+
+			if chunk.err != nil {
+				yield(Record{}, chunk.err)
+				return
+			}
+
+			if chunk.lastOwner != "" {
+				owner = chunk.lastOwner
+			}
+
+			if chunk.lastClass != recordClass_pending {
+				class = chunk.lastClass
+			}
+		}
+	}
+}
+
+// parse reads the records of a chunk. Records inheriting the owner or class
+// of a record in an earlier chunk are left with an empty DomainName, or
+// recordClass_pending.
+func (l *ParallelLoader) parse(chunk *zoneChunk) {
+	s := NewScanner(bytes.NewReader(chunk.data))
+	s.fileName = l.fileName
+	s.origin = chunk.origin
+	s.timeToLive = chunk.timeToLive
+	s.line = chunk.line
+	s.keepRelativeNames = l.keepRelativeNames
+	if !chunk.first {
+		s.ownerPending = true
+		s.lastClass = recordClass_pending
+	}
+
+	for record, err := range s.Records() {
+		if err != nil {
+			chunk.err = err
+			break
+		}
+		chunk.records = append(chunk.records, record)
+	}
+
+	if chunk.err == nil {
+		chunk.err = chunk.end
+	}
+
+	chunk.lastOwner = s.lastOwner
+	chunk.ownerError = s.ownerError
+	chunk.lastClass = s.lastClass
+}
+
+// split reads the input, and cuts it into chunks
+func (l *ParallelLoader) split(jobs, order chan<- *zoneChunk, stop <-chan struct{}) {
+	defer close(jobs)
+	defer close(order)
+
+	z := zoneSplitter{state: scannerState_Default, line: 1, origin: l.origin, timeToLive: -1}
+	first := true
+	send := func(chunk *zoneChunk) bool {
+		chunk.first = first
+		chunk.done = make(chan struct{})
+		first = false
+
+		select {
+		case order <- chunk:
+		case <-stop:
+			return false
+		}
+
+		select {
+		case jobs <- chunk:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+
+	var buf []byte
+	var scanned int
+	chunk := &zoneChunk{line: z.line, origin: z.origin, timeToLive: z.timeToLive}
+	for {
+		if len(buf) == cap(buf) {
+			buf = append(buf, make([]byte, max(loaderReadSize, l.chunkSize-len(buf)))...)[:len(buf)]
+		}
+
+		n, err := l.src.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+
+		for {
+			cut := z.scan(buf, scanned, l.chunkSize)
+			if cut < 0 {
+				scanned = len(buf)
+				break
+			}
+
+			chunk.data = buf[:cut]
+			if !send(chunk) {
+				return
+			}
+
+			// the rest is copied, as the chunk sent is still in use
+			rest := make([]byte, len(buf)-cut, max(len(buf)-cut, l.chunkSize+loaderReadSize))
+			copy(rest, buf[cut:])
+			buf, scanned = rest, 0
+			z.lineStart = 0
+			chunk = &zoneChunk{line: z.line, origin: z.origin, timeToLive: z.timeToLive}
+		}
+
+		if err == io.EOF {
+			if len(buf) > 0 {
+				chunk.data = buf
+				send(chunk)
+			}
+			return
+		}
+
+		if err != nil {
+			// the lines read in whole are still parsed, as a Scanner would
+			chunk.data = buf[:z.lineStart]
+			chunk.end = err
+			send(chunk)
+			return
+		}
+	}
+}
+
+// the bytes which can change the state of a zoneSplitter, outside of
+// comments and escapes
+var splitterBytes = [256]bool{'\n': true, '(': true, ')': true, '\\': true, '"': true, ';': true}
+
+// zoneSplitter follows the quotes, parentheses and comments of a zone file
+// byte by byte, as a Scanner would, to find the line ends where it can be
+// split. It reads $ORIGIN and $TTL control entries, to know the state at
+// each split.
+type zoneSplitter struct {
+	state      scannerState
+	line       int
+	origin     string
+	timeToLive int64
+
+	lineStart int  // the start of the current line, within the chunk
+	lineBegun bool // whether the current line has had anything but blanks
+	control   bool // whether the current line is a control entry
+}
+
+// scan carries on through buf from the offset from, which is where the
+// previous scan ended, and returns the end of the first line which ends at
+// least size bytes into buf, or -1 if there is none yet
+func (z *zoneSplitter) scan(buf []byte, from int, size int) int {
+	for i := from; i < len(buf); i++ {
+		// bytes which cannot change the state are skipped over quickly
+		switch z.state {
+		case scannerState_Comment, scannerState_ParenComment:
+			end := bytes.IndexByte(buf[i:], '\n')
+			if end < 0 {
+				return -1
+			}
+			i += end
+
+		case scannerState_Default, scannerState_Paren, scannerState_String, scannerState_ParenString:
+			if z.state != scannerState_Default || z.lineBegun {
+				for i < len(buf) && !splitterBytes[buf[i]] {
+					i++
+				}
+
+				if i == len(buf) {
+					return -1
+				}
+			}
+		}
+
+		c := buf[i]
+		if c == '\n' {
+			z.line++
+		}
+
+		switch z.state {
+		case scannerState_Default, scannerState_Paren:
+			paren := z.state == scannerState_Paren
+			if !paren && !z.lineBegun && !isSpace(c) {
+				z.lineBegun = true
+				z.control = c == '$'
+			}
+
+			switch {
+			case c == '\n' && !paren:
+				z.endLine(buf, i)
+				if i+1 >= size {
+					return i + 1
+				}
+			case c == '(' && !paren:
+				z.state = scannerState_Paren
+			case c == ')' && paren:
+				z.state = scannerState_Default
+			case c == '\\' && !paren:
+				z.state = scannerState_DefaultEscape
+			case c == '\\':
+				z.state = scannerState_ParenEscape
+			case c == '"' && !paren:
+				z.state = scannerState_String
+			case c == '"':
+				z.state = scannerState_ParenString
+			case c == ';' && !paren:
+				z.state = scannerState_Comment
+			case c == ';':
+				z.state = scannerState_ParenComment
+			}
+
+		case scannerState_String:
+			if c == '"' {
+				z.state = scannerState_Default
+			} else if c == '\\' {
+				z.state = scannerState_StringEscape
+			}
+
+		case scannerState_ParenString:
+			if c == '"' {
+				z.state = scannerState_Paren
+			} else if c == '\\' {
+				z.state = scannerState_ParenStringEscape
+			}
+
+		case scannerState_DefaultEscape:
+			z.state = scannerState_Default
+		case scannerState_StringEscape:
+			z.state = scannerState_String
+		case scannerState_ParenEscape:
+			z.state = scannerState_Paren
+		case scannerState_ParenStringEscape:
+			z.state = scannerState_ParenString
+
+		case scannerState_Comment:
+			if c == '\n' {
+				z.state = scannerState_Default
+				z.endLine(buf, i)
+				if i+1 >= size {
+					return i + 1
+				}
+			}
+
+		case scannerState_ParenComment:
+			if c == '\n' {
+				z.state = scannerState_Paren
+			}
+		}
+	}
+
+	return -1
+}
+
+// endLine finishes the line ending at buf[end], applying it if it was a
+// control entry
+func (z *zoneSplitter) endLine(buf []byte, end int) {
+	if z.control {
+		z.controlEntry(buf[z.lineStart : end+1])
+	}
+
+	z.lineStart = end + 1
+	z.lineBegun = false
+	z.control = false
+}
+
+// controlEntry applies an $ORIGIN or $TTL control entry, using a Scanner so
+// that it is read exactly as it will be when its chunk is parsed. Errors are
+// left for that Scanner to report.
+func (z *zoneSplitter) controlEntry(line []byte) {
+	entry := bytes.TrimLeft(line, " \t\r\v\f")
+	if !bytes.HasPrefix(entry, []byte("$ORIGIN")) && !bytes.HasPrefix(entry, []byte("$TTL")) {
+		return
+	}
+
+	s := NewScanner(bytes.NewReader(entry))
+	s.origin = z.origin
+	s.timeToLive = z.timeToLive
+
+	var record Record
+	if err := s.Next(&record); err == io.EOF {
+		z.origin = s.origin
+		z.timeToLive = s.timeToLive
+	}
+}
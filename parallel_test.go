@@ -0,0 +1,183 @@
+package gozone
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+const parallelZone = `$ORIGIN example.
+$TTL 3600
+@	IN SOA ns1 hostmaster ( 2024010101 ; serial
+		1800 900 604800 86400 )
+	NS	ns1 ; inherits the owner and class
+www	300 CH TXT "a ; quoted
+ line end" ( "and (" ; a comment
+		"parentheses" )
+	TXT	"\"escaped\"" ; inherits CH
+$ORIGIN sub.example.
+$TTL 60
+host	A	192.0.2.1
+	AAAA	2001:db8::1
+$GENERATE 1-3 h$ A 192.0.2.$
+	A	192.0.2.9 ; inherits h3, and CH from before $GENERATE
+a\;b	IN MX	10 mail
+`
+
+// loadParallel reads a zone with a ParallelLoader, returning the records read
+// before any error, as ReadAll
+func loadParallel(src io.Reader, chunkSize int) ([]Record, error) {
+	l := NewParallelLoader(src)
+	l.SetChunkSize(chunkSize)
+	l.SetWorkers(3)
+
+	var records []Record
+	for record, err := range l.Records() {
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func TestParallelLoader(t *testing.T) {
+	zones := []string{
+		parallelZone,
+		lexerZone,
+		string(benchmarkZone(true, 500)),
+		string(benchmarkZone(false, 500)),
+		strings.TrimSuffix(parallelZone, "\n"),
+		"$ORIGIN example.\na 60 IN TXT x\n  $ORIGIN sub.example.\nc 60 IN A 192.0.2.1\n",
+	}
+
+	for _, zone := range zones {
+		expected, err := ParseString(zone)
+		if err != nil {
+			t.Fatalf("Failed to parse zone: %s", err)
+		}
+
+		for _, chunkSize := range []int{1, 7, 64, 1000, DefaultChunkSize} {
+			records, err := loadParallel(strings.NewReader(zone), chunkSize)
+			if err != nil {
+				t.Fatalf("Failed to load zone in chunks of %d: %s", chunkSize, err)
+			}
+
+			if !reflect.DeepEqual(records, expected) {
+				t.Fatalf("Zone loaded in chunks of %d as %v, expected %v", chunkSize, records, expected)
+			}
+		}
+
+		records, err := loadParallel(iotest.OneByteReader(strings.NewReader(zone)), 16)
+		if err != nil || !reflect.DeepEqual(records, expected) {
+			t.Fatalf("Zone read in pieces loaded as %v, %v, expected %v", records, err, expected)
+		}
+	}
+}
+
+func TestParallelLoaderState(t *testing.T) {
+	l := NewParallelLoader(strings.NewReader("www 300 IN CNAME host\n"))
+	l.SetFileName("example.zone")
+	l.SetKeepRelativeNames(true)
+	if err := l.SetOrigin("example."); err != nil {
+		t.Fatalf("Failed to set origin: %s", err)
+	}
+
+	for record, err := range l.Records() {
+		if err != nil {
+			t.Fatalf("Failed to load zone: %s", err)
+		}
+
+		if record.DomainName != "www.example." || record.Data[0] != "host" || record.Position.File != "example.zone" {
+			t.Fatalf("Record was loaded as %v", record)
+		}
+	}
+
+	if err := l.SetOrigin("example"); err == nil {
+		t.Fatalf("Setting a relative origin should have failed")
+	}
+}
+
+func TestParallelLoaderErrors(t *testing.T) {
+	zones := []string{
+		parallelZone + "bad 300 IN A 192.0.2.256\n" + parallelZone,
+		"\tIN A 192.0.2.1\n",
+		"$TTL 60\n\n\tIN A 192.0.2.1\n",
+		"$TTL 60\n\n\t300 IN A 192.0.2.1\n\tIN A 192.0.2.2\n",
+		"example. 300 IN A 192.0.2.1\n$INCLUDE other.zone\n",
+		"example. 300 IN A 192.0.2.1\nexample. 300 IN TXT \"unterminated\n",
+	}
+
+	for _, zone := range zones {
+		expected, expectedErr := ParseString(zone)
+		for _, chunkSize := range []int{1, 64, DefaultChunkSize} {
+			records, err := loadParallel(strings.NewReader(zone), chunkSize)
+
+			var parseError, expectedParseError *ParseError
+			if !errors.As(err, &parseError) || !errors.As(expectedErr, &expectedParseError) ||
+				err.Error() != expectedErr.Error() || parseError.Kind != expectedParseError.Kind {
+				t.Fatalf("Loading [%s] in chunks of %d failed with %v, expected %v", zone, chunkSize, err, expectedErr)
+			}
+
+			if !reflect.DeepEqual(records, expected) {
+				t.Fatalf("Loading [%s] in chunks of %d returned %v before failing, expected %v", zone, chunkSize, records, expected)
+			}
+		}
+	}
+}
+
+func TestParallelLoaderReadError(t *testing.T) {
+	failure := errors.New("read failed")
+	src := io.MultiReader(strings.NewReader("example. 300 IN A 192.0.2.1\nexample. 300 IN TXT \"a\"\nexample. 300 IN TXT \"b"), iotest.ErrReader(failure))
+
+	records, err := loadParallel(src, 1)
+	if !errors.Is(err, failure) || len(records) != 2 {
+		t.Fatalf("Loading a failing input returned %d records, %v", len(records), err)
+	}
+}
+
+func TestParallelLoaderBreak(t *testing.T) {
+	l := NewParallelLoader(bytes.NewReader(benchmarkZone(true, 10000)))
+	l.SetChunkSize(1000)
+
+	var records int
+	for _, err := range l.Records() {
+		if err != nil {
+			t.Fatalf("Failed to load zone: %s", err)
+		}
+
+		if records++; records == 10 {
+			break
+		}
+	}
+
+	if records != 10 {
+		t.Fatalf("Loaded %d records before breaking, expected 10", records)
+	}
+}
+
+func benchmarkParallelLoader(b *testing.B, zone []byte) {
+	b.SetBytes(int64(len(zone)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, err := range NewParallelLoader(bytes.NewReader(zone)).Records() {
+			if err != nil {
+				b.Fatalf("Failed to load benchmark zone: %s", err)
+			}
+		}
+	}
+}
+
+func BenchmarkParallelLoaderDelegations(b *testing.B) {
+	benchmarkParallelLoader(b, benchmarkZone(true, 1000000))
+}
+
+func BenchmarkParallelLoaderHosts(b *testing.B) {
+	benchmarkParallelLoader(b, benchmarkZone(false, 1000000))
+}
@@ -0,0 +1,581 @@
+package gozone
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedRData is returned by Record.RData for types which have no
+// typed RData.
+var ErrUnsupportedRData = errors.New("no typed RData for record type")
+
+// RData is the typed form of a Record's Data.
+type RData interface {
+	Type() RecordType
+	String() string // the data in presentation format
+}
+
+type A struct {
+	Address netip.Addr
+}
+
+type AAAA struct {
+	Address netip.Addr
+}
+
+type NS struct {
+	Host string
+}
+
+type CNAME struct {
+	Target string
+}
+
+type PTR struct {
+	Target string
+}
+
+type MX struct {
+	Preference uint16
+	Exchange   string
+}
+
+type SOA struct {
+	MName   string // the primary name server
+	RName   string // the mailbox of the person responsible for the zone
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	Minimum uint32
+}
+
+type TXT struct {
+	Strings []string // decoded character-strings
+}
+
+type HINFO struct {
+	CPU string
+	OS  string
+}
+
+type MINFO struct {
+	RMailbox string // the mailbox responsible for the mailing list
+	EMailbox string // the mailbox to receive errors
+}
+
+type WKS struct {
+	Address  netip.Addr
+	Protocol uint8
+	Ports    []uint16
+}
+
+// GenericRData is record data given in the RFC 3597 "\#" form.
+type GenericRData struct {
+	RType RecordType
+	Data  []byte
+}
+
+func (rd *A) Type() RecordType            { return RecordType_A }
+func (rd *AAAA) Type() RecordType         { return RecordType_AAAA }
+func (rd *NS) Type() RecordType           { return RecordType_NS }
+func (rd *CNAME) Type() RecordType        { return RecordType_CNAME }
+func (rd *PTR) Type() RecordType          { return RecordType_PTR }
+func (rd *MX) Type() RecordType           { return RecordType_MX }
+func (rd *SOA) Type() RecordType          { return RecordType_SOA }
+func (rd *TXT) Type() RecordType          { return RecordType_TXT }
+func (rd *HINFO) Type() RecordType        { return RecordType_HINFO }
+func (rd *MINFO) Type() RecordType        { return RecordType_MINFO }
+func (rd *WKS) Type() RecordType          { return RecordType_WKS }
+func (rd *GenericRData) Type() RecordType { return rd.RType }
+
+func (rd *A) String() string     { return rd.Address.String() }
+func (rd *AAAA) String() string  { return rd.Address.String() }
+func (rd *NS) String() string    { return rd.Host }
+func (rd *CNAME) String() string { return rd.Target }
+func (rd *PTR) String() string   { return rd.Target }
+
+func (rd *MX) String() string {
+	return fmt.Sprintf("%d %s", rd.Preference, rd.Exchange)
+}
+
+func (rd *SOA) String() string {
+	return fmt.Sprintf("%s %s %d %d %d %d %d",
+		rd.MName, rd.RName, rd.Serial, rd.Refresh, rd.Retry, rd.Expire, rd.Minimum)
+}
+
+func (rd *TXT) String() string {
+	quoted := make([]string, len(rd.Strings))
+	for i, s := range rd.Strings {
+		quoted[i] = quoteCharacterString(s)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+func (rd *HINFO) String() string {
+	return quoteCharacterString(rd.CPU) + " " + quoteCharacterString(rd.OS)
+}
+
+func (rd *MINFO) String() string {
+	return rd.RMailbox + " " + rd.EMailbox
+}
+
+func (rd *WKS) String() string {
+	spec := []string{rd.Address.String(), strconv.Itoa(int(rd.Protocol))}
+	for _, port := range rd.Ports {
+		spec = append(spec, strconv.Itoa(int(port)))
+	}
+
+	return strings.Join(spec, " ")
+}
+
+func (rd *GenericRData) String() string {
+	if len(rd.Data) == 0 {
+		return `\# 0`
+	}
+
+	return fmt.Sprintf(`\# %d %X`, len(rd.Data), rd.Data)
+}
+
+type rdataParser func(fields []string) (RData, error)
+
+var rdataParsers = map[RecordType]rdataParser{
+	RecordType_A:     parseA,
+	RecordType_AAAA:  parseAAAA,
+	RecordType_NS:    parseNS,
+	RecordType_CNAME: parseCNAME,
+	RecordType_PTR:   parsePTR,
+	RecordType_MX:    parseMX,
+	RecordType_SOA:   parseSOA,
+	RecordType_TXT:   parseTXT,
+	RecordType_HINFO: parseHINFO,
+	RecordType_MINFO: parseMINFO,
+	RecordType_WKS:   parseWKS,
+
+	RecordType_DS:         parseDS,
+	RecordType_DNSKEY:     parseDNSKEY,
+	RecordType_RRSIG:      parseRRSIG,
+	RecordType_NSEC:       parseNSEC,
+	RecordType_NSEC3:      parseNSEC3,
+	RecordType_NSEC3PARAM: parseNSEC3PARAM,
+	RecordType_ZONEMD:     parseZONEMD,
+}
+
+// RData decodes and validates the record's Data. Data in the RFC 3597 \#
+// form is decoded into the typed RData of its type, when there is one, and
+// is otherwise returned as GenericRData.
+func (r Record) RData() (RData, error) {
+	fields := stripParens(r.Data)
+	if isGenericData(fields) {
+		data, err := decodeGenericData(fields)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := rdataParsers[r.Type]; ok {
+			return unpackRData(r.Type, data)
+		}
+
+		return &GenericRData{RType: r.Type, Data: data}, nil
+	}
+
+	parse, ok := rdataParsers[r.Type]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnsupportedRData, r.Type)
+	}
+
+	return parse(fields)
+}
+
+// rdataAs decodes the data of a record as typed RData of the kind T
+// expects, failing when the record holds data of any other kind
+func rdataAs[T RData](r Record) (T, error) {
+	var typed T
+	rdata, err := r.RData()
+	if err != nil {
+		return typed, err
+	}
+
+	typed, ok := rdata.(T)
+	if !ok {
+		return typed, fmt.Errorf("%s record does not hold %T data", r.Type, typed)
+	}
+
+	return typed, nil
+}
+
+func checkFieldCount(rtype RecordType, fields []string, count int) error {
+	if len(fields) != count {
+		return fmt.Errorf("%s record has %d fields, expected %d", rtype, len(fields), count)
+	}
+
+	return nil
+}
+
+// checkDomainField ensures a field can be used as a domain name
+func checkDomainField(rtype RecordType, field string) error {
+	if field[0] == '"' {
+		return fmt.Errorf("Invalid domain name %s in %s record", field, rtype)
+	}
+
+	return nil
+}
+
+func parseA(fields []string) (RData, error) {
+	if err := checkFieldCount(RecordType_A, fields, 1); err != nil {
+		return nil, err
+	}
+
+	address, err := netip.ParseAddr(fields[0])
+	if err != nil || !address.Is4() {
+		return nil, fmt.Errorf("Invalid IPv4 address '%s' in A record", fields[0])
+	}
+
+	return &A{Address: address}, nil
+}
+
+func parseAAAA(fields []string) (RData, error) {
+	if err := checkFieldCount(RecordType_AAAA, fields, 1); err != nil {
+		return nil, err
+	}
+
+	address, err := netip.ParseAddr(fields[0])
+	if err != nil || !address.Is6() || address.Zone() != "" {
+		return nil, fmt.Errorf("Invalid IPv6 address '%s' in AAAA record", fields[0])
+	}
+
+	return &AAAA{Address: address}, nil
+}
+
+func parseSingleDomain(rtype RecordType, fields []string) (string, error) {
+	if err := checkFieldCount(rtype, fields, 1); err != nil {
+		return "", err
+	}
+
+	if err := checkDomainField(rtype, fields[0]); err != nil {
+		return "", err
+	}
+
+	return fields[0], nil
+}
+
+func parseNS(fields []string) (RData, error) {
+	host, err := parseSingleDomain(RecordType_NS, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NS{Host: host}, nil
+}
+
+func parseCNAME(fields []string) (RData, error) {
+	target, err := parseSingleDomain(RecordType_CNAME, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CNAME{Target: target}, nil
+}
+
+func parsePTR(fields []string) (RData, error) {
+	target, err := parseSingleDomain(RecordType_PTR, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PTR{Target: target}, nil
+}
+
+func parseMX(fields []string) (RData, error) {
+	if err := checkFieldCount(RecordType_MX, fields, 2); err != nil {
+		return nil, err
+	}
+
+	preference, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid preference '%s' in MX record", fields[0])
+	}
+
+	if err = checkDomainField(RecordType_MX, fields[1]); err != nil {
+		return nil, err
+	}
+
+	return &MX{Preference: uint16(preference), Exchange: fields[1]}, nil
+}
+
+func parseSOA(fields []string) (RData, error) {
+	if err := checkFieldCount(RecordType_SOA, fields, 7); err != nil {
+		return nil, err
+	}
+
+	for _, field := range fields[:2] {
+		if err := checkDomainField(RecordType_SOA, field); err != nil {
+			return nil, err
+		}
+	}
+
+	serial, err := strconv.ParseUint(fields[2], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid serial '%s' in SOA record", fields[2])
+	}
+
+	var timers [4]uint32
+	for i, field := range fields[3:] {
+		if timers[i], err = ParseTTL(field); err != nil {
+			return nil, fmt.Errorf("Invalid timer in SOA record: %s", err)
+		}
+	}
+
+	return &SOA{
+		MName:   fields[0],
+		RName:   fields[1],
+		Serial:  uint32(serial),
+		Refresh: timers[0],
+		Retry:   timers[1],
+		Expire:  timers[2],
+		Minimum: timers[3],
+	}, nil
+}
+
+func parseTXT(fields []string) (RData, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("TXT record has no character-strings")
+	}
+
+	txt := &TXT{Strings: make([]string, len(fields))}
+	for i, field := range fields {
+		s, err := parseCharacterString(field)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid character-string in TXT record: %s", err)
+		}
+		txt.Strings[i] = s
+	}
+
+	return txt, nil
+}
+
+func parseHINFO(fields []string) (RData, error) {
+	if err := checkFieldCount(RecordType_HINFO, fields, 2); err != nil {
+		return nil, err
+	}
+
+	cpu, err := parseCharacterString(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CPU in HINFO record: %s", err)
+	}
+
+	os, err := parseCharacterString(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid OS in HINFO record: %s", err)
+	}
+
+	return &HINFO{CPU: cpu, OS: os}, nil
+}
+
+func parseMINFO(fields []string) (RData, error) {
+	if err := checkFieldCount(RecordType_MINFO, fields, 2); err != nil {
+		return nil, err
+	}
+
+	for _, field := range fields {
+		if err := checkDomainField(RecordType_MINFO, field); err != nil {
+			return nil, err
+		}
+	}
+
+	return &MINFO{RMailbox: fields[0], EMailbox: fields[1]}, nil
+}
+
+var wksProtocols = map[string]uint8{
+	"icmp": 1,
+	"tcp":  6,
+	"udp":  17,
+}
+
+var wksServices = map[string]uint16{
+	"echo":     7,
+	"discard":  9,
+	"daytime":  13,
+	"ftp-data": 20,
+	"ftp":      21,
+	"ssh":      22,
+	"telnet":   23,
+	"smtp":     25,
+	"time":     37,
+	"domain":   53,
+	"finger":   79,
+	"http":     80,
+	"pop3":     110,
+	"nntp":     119,
+	"ntp":      123,
+	"imap":     143,
+	"snmp":     161,
+	"https":    443,
+}
+
+func parseWKS(fields []string) (RData, error) {
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("WKS record has %d fields, expected at least 2", len(fields))
+	}
+
+	address, err := netip.ParseAddr(fields[0])
+	if err != nil || !address.Is4() {
+		return nil, fmt.Errorf("Invalid IPv4 address '%s' in WKS record", fields[0])
+	}
+
+	protocol, ok := wksProtocols[strings.ToLower(fields[1])]
+	if !ok {
+		var value uint64
+		if value, err = strconv.ParseUint(fields[1], 10, 8); err != nil {
+			return nil, fmt.Errorf("Invalid protocol '%s' in WKS record", fields[1])
+		}
+		protocol = uint8(value)
+	}
+
+	wks := &WKS{Address: address, Protocol: protocol}
+	seen := make(map[uint16]bool)
+	for _, field := range fields[2:] {
+		port, ok := wksServices[strings.ToLower(field)]
+		if !ok {
+			var value uint64
+			if value, err = strconv.ParseUint(field, 10, 16); err != nil {
+				return nil, fmt.Errorf("Invalid service '%s' in WKS record", field)
+			}
+			port = uint16(value)
+		}
+
+		if !seen[port] {
+			seen[port] = true
+			wks.Ports = append(wks.Ports, port)
+		}
+	}
+	sort.Slice(wks.Ports, func(i, j int) bool { return wks.Ports[i] < wks.Ports[j] })
+
+	return wks, nil
+}
+
+// parseCharacterString decodes a quoted or unquoted <character-string>,
+// resolving \X and \DDD escapes
+func parseCharacterString(field string) (string, error) {
+	if field[0] == '"' {
+		if len(field) < 2 || field[len(field)-1] != '"' {
+			return "", fmt.Errorf("unterminated quoted string %s", field)
+		}
+		field = field[1 : len(field)-1]
+	}
+
+	decoded, err := decodeEscapes(field)
+	if err != nil {
+		return "", err
+	}
+
+	if len(decoded) > 255 {
+		return "", fmt.Errorf("character-string is %d bytes long, the maximum is 255", len(decoded))
+	}
+
+	return decoded, nil
+}
+
+// decodeEscapes resolves the \X and \DDD escapes of RFC 1035 section 5.1
+func decodeEscapes(text string) (string, error) {
+	if strings.IndexByte(text, '\\') == -1 {
+		return text, nil
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' {
+			_ = out.WriteByte(text[i])
+			continue
+		}
+
+		if i+1 >= len(text) {
+			return "", fmt.Errorf("dangling escape in '%s'", text)
+		}
+
+		if isDigit(text[i+1]) {
+			if i+3 >= len(text) || !isDigit(text[i+2]) || !isDigit(text[i+3]) {
+				return "", fmt.Errorf("incomplete \\DDD escape in '%s'", text)
+			}
+
+			value := int(text[i+1]-'0')*100 + int(text[i+2]-'0')*10 + int(text[i+3]-'0')
+			if value > 255 {
+				return "", fmt.Errorf("\\DDD escape out of range in '%s'", text)
+			}
+
+			_ = out.WriteByte(byte(value))
+			i += 3
+			continue
+		}
+
+		_ = out.WriteByte(text[i+1])
+		i++
+	}
+
+	return out.String(), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// quoteCharacterString writes s as a quoted <character-string>, escaping
+// quotes, backslashes and unprintable bytes
+func quoteCharacterString(s string) string {
+	var out strings.Builder
+	_ = out.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			_ = out.WriteByte('\\')
+			_ = out.WriteByte(c)
+		case c < ' ' || c > '~':
+			_, _ = fmt.Fprintf(&out, "\\%03d", c)
+		default:
+			_ = out.WriteByte(c)
+		}
+	}
+	_ = out.WriteByte('"')
+
+	return out.String()
+}
+
+// rdataDomainFields lists, for each type, which fields of the record data
+// (without parentheses) hold domain names
+var rdataDomainFields = map[RecordType][]int{
+	RecordType_NS:    {0},
+	RecordType_MD:    {0},
+	RecordType_MF:    {0},
+	RecordType_CNAME: {0},
+	RecordType_SOA:   {0, 1},
+	RecordType_MB:    {0},
+	RecordType_MG:    {0},
+	RecordType_MR:    {0},
+	RecordType_PTR:   {0},
+	RecordType_MINFO: {0, 1},
+	RecordType_MX:    {1},
+	RecordType_RP:    {0, 1},
+	RecordType_AFSDB: {1},
+	RecordType_RT:    {1},
+	RecordType_PX:    {1, 2},
+	RecordType_SRV:   {3},
+	RecordType_NAPTR: {5},
+	RecordType_KX:    {1},
+	RecordType_DNAME: {0},
+	RecordType_NSEC:  {0},
+	RecordType_RRSIG: {7},
+}
+
+// rdataStringTypes are the types whose record data is entirely made up of
+// <character-string>s
+var rdataStringTypes = map[RecordType]bool{
+	RecordType_HINFO: true,
+	RecordType_TXT:   true,
+	RecordType_SPF:   true,
+}
@@ -0,0 +1,599 @@
+package gozone
+
+// https://www.ietf.org/rfc/rfc4034.txt
+// https://www.ietf.org/rfc/rfc5155.txt
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DS struct {
+	KeyTag     uint16
+	Algorithm  uint8
+	DigestType uint8
+	Digest     []byte
+}
+
+type DNSKEY struct {
+	Flags     uint16
+	Protocol  uint8
+	Algorithm uint8
+	PublicKey []byte
+}
+
+type RRSIG struct {
+	TypeCovered RecordType
+	Algorithm   uint8
+	Labels      uint8
+	OriginalTTL uint32
+	Expiration  uint32 // seconds since 1970, modulo 2^32
+	Inception   uint32 // seconds since 1970, modulo 2^32
+	KeyTag      uint16
+	SignerName  string
+	Signature   []byte
+}
+
+type NSEC struct {
+	NextDomain string
+	Types      []RecordType // sorted
+}
+
+type NSEC3 struct {
+	HashAlgorithm   uint8
+	Flags           uint8
+	Iterations      uint16
+	Salt            []byte
+	NextHashedOwner []byte
+	Types           []RecordType // sorted
+}
+
+type NSEC3PARAM struct {
+	HashAlgorithm uint8
+	Flags         uint8
+	Iterations    uint16
+	Salt          []byte
+}
+
+// base32hex, as used for hashed owner names, without padding
+var nsec3Encoding = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+func (rd *DS) Type() RecordType         { return RecordType_DS }
+func (rd *DNSKEY) Type() RecordType     { return RecordType_DNSKEY }
+func (rd *RRSIG) Type() RecordType      { return RecordType_RRSIG }
+func (rd *NSEC) Type() RecordType       { return RecordType_NSEC }
+func (rd *NSEC3) Type() RecordType      { return RecordType_NSEC3 }
+func (rd *NSEC3PARAM) Type() RecordType { return RecordType_NSEC3PARAM }
+
+func (rd *DS) String() string {
+	return fmt.Sprintf("%d %d %d %X", rd.KeyTag, rd.Algorithm, rd.DigestType, rd.Digest)
+}
+
+func (rd *DNSKEY) String() string {
+	return fmt.Sprintf("%d %d %d %s", rd.Flags, rd.Protocol, rd.Algorithm, base64.StdEncoding.EncodeToString(rd.PublicKey))
+}
+
+func (rd *RRSIG) String() string {
+	return fmt.Sprintf("%s %d %d %d %s %s %d %s %s",
+		rd.TypeCovered, rd.Algorithm, rd.Labels, rd.OriginalTTL,
+		formatSignatureTime(rd.Expiration), formatSignatureTime(rd.Inception),
+		rd.KeyTag, rd.SignerName, base64.StdEncoding.EncodeToString(rd.Signature))
+}
+
+func (rd *NSEC) String() string {
+	return strings.Join(append([]string{rd.NextDomain}, typeNames(rd.Types)...), " ")
+}
+
+func (rd *NSEC3) String() string {
+	spec := []string{
+		strconv.Itoa(int(rd.HashAlgorithm)),
+		strconv.Itoa(int(rd.Flags)),
+		strconv.Itoa(int(rd.Iterations)),
+		formatSalt(rd.Salt),
+		nsec3Encoding.EncodeToString(rd.NextHashedOwner),
+	}
+
+	return strings.Join(append(spec, typeNames(rd.Types)...), " ")
+}
+
+func (rd *NSEC3PARAM) String() string {
+	return fmt.Sprintf("%d %d %d %s", rd.HashAlgorithm, rd.Flags, rd.Iterations, formatSalt(rd.Salt))
+}
+
+func typeNames(types []RecordType) []string {
+	names := make([]string, len(types))
+	for i, rtype := range types {
+		names[i] = rtype.String()
+	}
+
+	return names
+}
+
+func formatSalt(salt []byte) string {
+	if len(salt) == 0 {
+		return "-"
+	}
+
+	return fmt.Sprintf("%X", salt)
+}
+
+// formatSignatureTime writes an RRSIG time as YYYYMMDDHHmmSS, taking the
+// time within 68 years of now which matches it modulo 2^32
+func formatSignatureTime(t uint32) string {
+	return signatureTime(t, time.Now()).UTC().Format("20060102150405")
+}
+
+// signatureTime resolves an RRSIG time to the time nearest to now which
+// matches it modulo 2^32, using RFC 1982 serial number arithmetic
+func signatureTime(t uint32, now time.Time) time.Time {
+	offset := int64(int32(t - uint32(now.Unix())))
+	return time.Unix(now.Unix()+offset, 0)
+}
+
+func parseSignatureTime(field string) (uint32, error) {
+	if len(field) == 14 {
+		t, err := time.Parse("20060102150405", field)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid time '%s' in RRSIG record", field)
+		}
+		return uint32(t.Unix()), nil
+	}
+
+	seconds, err := strconv.ParseUint(field, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid time '%s' in RRSIG record", field)
+	}
+
+	return uint32(seconds), nil
+}
+
+func parseUint8Field(rtype RecordType, name string, field string) (uint8, error) {
+	value, err := strconv.ParseUint(field, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s '%s' in %s record", name, field, rtype)
+	}
+
+	return uint8(value), nil
+}
+
+func parseUint16Field(rtype RecordType, name string, field string) (uint16, error) {
+	value, err := strconv.ParseUint(field, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s '%s' in %s record", name, field, rtype)
+	}
+
+	return uint16(value), nil
+}
+
+func parseTypeList(rtype RecordType, fields []string) ([]RecordType, error) {
+	seen := make(map[RecordType]bool)
+	var types []RecordType
+	for _, field := range fields {
+		t, err := parseType(strings.ToUpper(field))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid type '%s' in %s record", field, rtype)
+		}
+
+		if !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	return types, nil
+}
+
+func parseSalt(rtype RecordType, field string) ([]byte, error) {
+	if field == "-" {
+		return nil, nil
+	}
+
+	salt, err := hex.DecodeString(field)
+	if err != nil || len(salt) > 255 {
+		return nil, fmt.Errorf("Invalid salt '%s' in %s record", field, rtype)
+	}
+
+	return salt, nil
+}
+
+func parseDS(fields []string) (RData, error) {
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("DS record has %d fields, expected at least 4", len(fields))
+	}
+
+	keyTag, err := parseUint16Field(RecordType_DS, "key tag", fields[0])
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm, err := parseUint8Field(RecordType_DS, "algorithm", fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	digestType, err := parseUint8Field(RecordType_DS, "digest type", fields[2])
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := hex.DecodeString(strings.Join(fields[3:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid digest in DS record: %s", err)
+	}
+
+	return &DS{KeyTag: keyTag, Algorithm: algorithm, DigestType: digestType, Digest: digest}, nil
+}
+
+func parseDNSKEY(fields []string) (RData, error) {
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("DNSKEY record has %d fields, expected at least 4", len(fields))
+	}
+
+	flags, err := parseUint16Field(RecordType_DNSKEY, "flags", fields[0])
+	if err != nil {
+		return nil, err
+	}
+
+	protocol, err := parseUint8Field(RecordType_DNSKEY, "protocol", fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm, err := parseUint8Field(RecordType_DNSKEY, "algorithm", fields[2])
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.Join(fields[3:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid public key in DNSKEY record: %s", err)
+	}
+
+	return &DNSKEY{Flags: flags, Protocol: protocol, Algorithm: algorithm, PublicKey: key}, nil
+}
+
+func parseRRSIG(fields []string) (RData, error) {
+	if len(fields) < 9 {
+		return nil, fmt.Errorf("RRSIG record has %d fields, expected at least 9", len(fields))
+	}
+
+	covered, err := parseType(strings.ToUpper(fields[0]))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid type covered '%s' in RRSIG record", fields[0])
+	}
+
+	rrsig := &RRSIG{TypeCovered: covered, SignerName: fields[7]}
+	if rrsig.Algorithm, err = parseUint8Field(RecordType_RRSIG, "algorithm", fields[1]); err != nil {
+		return nil, err
+	}
+
+	if rrsig.Labels, err = parseUint8Field(RecordType_RRSIG, "labels", fields[2]); err != nil {
+		return nil, err
+	}
+
+	ttl, err := ParseTTL(fields[3])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid original TTL '%s' in RRSIG record", fields[3])
+	}
+	rrsig.OriginalTTL = ttl
+
+	if rrsig.Expiration, err = parseSignatureTime(fields[4]); err != nil {
+		return nil, err
+	}
+
+	if rrsig.Inception, err = parseSignatureTime(fields[5]); err != nil {
+		return nil, err
+	}
+
+	if rrsig.KeyTag, err = parseUint16Field(RecordType_RRSIG, "key tag", fields[6]); err != nil {
+		return nil, err
+	}
+
+	if err = checkDomainField(RecordType_RRSIG, fields[7]); err != nil {
+		return nil, err
+	}
+
+	if rrsig.Signature, err = base64.StdEncoding.DecodeString(strings.Join(fields[8:], "")); err != nil {
+		return nil, fmt.Errorf("Invalid signature in RRSIG record: %s", err)
+	}
+
+	return rrsig, nil
+}
+
+func parseNSEC(fields []string) (RData, error) {
+	if len(fields) < 1 {
+		return nil, fmt.Errorf("NSEC record has no next domain name")
+	}
+
+	if err := checkDomainField(RecordType_NSEC, fields[0]); err != nil {
+		return nil, err
+	}
+
+	types, err := parseTypeList(RecordType_NSEC, fields[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &NSEC{NextDomain: fields[0], Types: types}, nil
+}
+
+func parseNSEC3(fields []string) (RData, error) {
+	if len(fields) < 5 {
+		return nil, fmt.Errorf("NSEC3 record has %d fields, expected at least 5", len(fields))
+	}
+
+	param, err := parseNSEC3PARAM(fields[:4])
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := nsec3Encoding.DecodeString(strings.ToUpper(fields[4]))
+	if err != nil || len(next) == 0 {
+		return nil, fmt.Errorf("Invalid next hashed owner name '%s' in NSEC3 record", fields[4])
+	}
+
+	types, err := parseTypeList(RecordType_NSEC3, fields[5:])
+	if err != nil {
+		return nil, err
+	}
+
+	p := param.(*NSEC3PARAM)
+	return &NSEC3{
+		HashAlgorithm:   p.HashAlgorithm,
+		Flags:           p.Flags,
+		Iterations:      p.Iterations,
+		Salt:            p.Salt,
+		NextHashedOwner: next,
+		Types:           types,
+	}, nil
+}
+
+func parseNSEC3PARAM(fields []string) (RData, error) {
+	if err := checkFieldCount(RecordType_NSEC3PARAM, fields, 4); err != nil {
+		return nil, err
+	}
+
+	algorithm, err := parseUint8Field(RecordType_NSEC3PARAM, "hash algorithm", fields[0])
+	if err != nil {
+		return nil, err
+	}
+
+	flags, err := parseUint8Field(RecordType_NSEC3PARAM, "flags", fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	iterations, err := parseUint16Field(RecordType_NSEC3PARAM, "iterations", fields[2])
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := parseSalt(RecordType_NSEC3PARAM, fields[3])
+	if err != nil {
+		return nil, err
+	}
+
+	return &NSEC3PARAM{HashAlgorithm: algorithm, Flags: flags, Iterations: iterations, Salt: salt}, nil
+}
+
+// packTypeBitmap writes the type bitmap of RFC 4034 section 4.1.2
+func packTypeBitmap(buf []byte, types []RecordType) []byte {
+	var window [32]byte
+	current, length := -1, 0
+	flush := func() {
+		if length != 0 {
+			buf = append(buf, byte(current), byte(length))
+			buf = append(buf, window[:length]...)
+		}
+		window = [32]byte{}
+		length = 0
+	}
+
+	for _, rtype := range types {
+		if int(rtype>>8) != current {
+			flush()
+			current = int(rtype >> 8)
+		}
+
+		octet := int(rtype&0xFF) / 8
+		window[octet] |= 0x80 >> (rtype % 8)
+		if octet+1 > length {
+			length = octet + 1
+		}
+	}
+	flush()
+
+	return buf
+}
+
+func unpackTypeBitmap(r *wireReader, end int) ([]RecordType, error) {
+	var types []RecordType
+	last := -1
+	for r.off < end {
+		window, err := r.uint8()
+		if err != nil {
+			return nil, err
+		}
+
+		length, err := r.uint8()
+		if err != nil {
+			return nil, err
+		}
+
+		if int(window) <= last || length == 0 || length > 32 {
+			return nil, r.errorf("invalid type bitmap window %d of length %d", window, length)
+		}
+		last = int(window)
+
+		bitmap, err := r.bytes(int(length))
+		if err != nil {
+			return nil, err
+		}
+
+		for i, bits := range bitmap {
+			for bit := 0; bit < 8; bit++ {
+				if bits&(0x80>>bit) != 0 {
+					types = append(types, RecordType(int(window)<<8|i*8+bit))
+				}
+			}
+		}
+	}
+
+	return types, nil
+}
+
+func (b *wireBuilder) packDNSSEC(rdata RData) error {
+	switch rd := rdata.(type) {
+	case *DS:
+		b.buf = binary.BigEndian.AppendUint16(b.buf, rd.KeyTag)
+		b.buf = append(b.buf, rd.Algorithm, rd.DigestType)
+		b.buf = append(b.buf, rd.Digest...)
+	case *DNSKEY:
+		b.buf = binary.BigEndian.AppendUint16(b.buf, rd.Flags)
+		b.buf = append(b.buf, rd.Protocol, rd.Algorithm)
+		b.buf = append(b.buf, rd.PublicKey...)
+	case *RRSIG:
+		if err := b.packRRSIGHeader(rd); err != nil {
+			return err
+		}
+		b.buf = append(b.buf, rd.Signature...)
+	case *NSEC:
+		if err := b.packUncompressedName(rd.NextDomain); err != nil {
+			return err
+		}
+		b.buf = packTypeBitmap(b.buf, rd.Types)
+	case *NSEC3:
+		if len(rd.Salt) > 255 || len(rd.NextHashedOwner) > 255 {
+			return fmt.Errorf("NSEC3 record data %w: salt or hash is too long", ErrWireFormat)
+		}
+		b.buf = append(b.buf, rd.HashAlgorithm, rd.Flags)
+		b.buf = binary.BigEndian.AppendUint16(b.buf, rd.Iterations)
+		b.buf = append(b.buf, byte(len(rd.Salt)))
+		b.buf = append(b.buf, rd.Salt...)
+		b.buf = append(b.buf, byte(len(rd.NextHashedOwner)))
+		b.buf = append(b.buf, rd.NextHashedOwner...)
+		b.buf = packTypeBitmap(b.buf, rd.Types)
+	case *NSEC3PARAM:
+		b.buf = append(b.buf, rd.HashAlgorithm, rd.Flags)
+		b.buf = binary.BigEndian.AppendUint16(b.buf, rd.Iterations)
+		b.buf = append(b.buf, byte(len(rd.Salt)))
+		b.buf = append(b.buf, rd.Salt...)
+	default:
+		return fmt.Errorf("%s record data %w", rdata.Type(), ErrWireFormat)
+	}
+
+	return nil
+}
+
+// packRRSIGHeader writes every field of an RRSIG record except the signature
+func (b *wireBuilder) packRRSIGHeader(rd *RRSIG) error {
+	b.buf = binary.BigEndian.AppendUint16(b.buf, uint16(rd.TypeCovered))
+	b.buf = append(b.buf, rd.Algorithm, rd.Labels)
+	b.buf = binary.BigEndian.AppendUint32(b.buf, rd.OriginalTTL)
+	b.buf = binary.BigEndian.AppendUint32(b.buf, rd.Expiration)
+	b.buf = binary.BigEndian.AppendUint32(b.buf, rd.Inception)
+	b.buf = binary.BigEndian.AppendUint16(b.buf, rd.KeyTag)
+
+	// the signer's name is never compressed, but unlike NSEC it is still
+	// lowercased in canonical form (RFC 6840 section 5.1)
+	names := b.names
+	b.names = nil
+	defer func() { b.names = names }()
+
+	return b.packName(rd.SignerName)
+}
+
+func (r *wireReader) dnssecRData(rtype RecordType, end int) (RData, error) {
+	switch rtype {
+	case RecordType_DS, RecordType_DNSKEY:
+		header, err := r.bytes(4)
+		if err != nil {
+			return nil, err
+		}
+
+		rest, _ := r.bytes(end - r.off)
+		rest = append([]byte(nil), rest...)
+		if rtype == RecordType_DS {
+			return &DS{binary.BigEndian.Uint16(header), header[2], header[3], rest}, nil
+		}
+		return &DNSKEY{binary.BigEndian.Uint16(header), header[2], header[3], rest}, nil
+	case RecordType_RRSIG:
+		header, err := r.bytes(18)
+		if err != nil {
+			return nil, err
+		}
+
+		signer, err := r.name()
+		if err != nil {
+			return nil, err
+		}
+
+		signature, _ := r.bytes(end - r.off)
+		return &RRSIG{
+			TypeCovered: RecordType(binary.BigEndian.Uint16(header)),
+			Algorithm:   header[2],
+			Labels:      header[3],
+			OriginalTTL: binary.BigEndian.Uint32(header[4:]),
+			Expiration:  binary.BigEndian.Uint32(header[8:]),
+			Inception:   binary.BigEndian.Uint32(header[12:]),
+			KeyTag:      binary.BigEndian.Uint16(header[16:]),
+			SignerName:  signer,
+			Signature:   append([]byte(nil), signature...),
+		}, nil
+	case RecordType_NSEC:
+		next, err := r.name()
+		if err != nil {
+			return nil, err
+		}
+
+		types, err := unpackTypeBitmap(r, end)
+		if err != nil {
+			return nil, err
+		}
+		return &NSEC{NextDomain: next, Types: types}, nil
+	case RecordType_NSEC3, RecordType_NSEC3PARAM:
+		header, err := r.bytes(4)
+		if err != nil {
+			return nil, err
+		}
+
+		salt, err := r.characterString()
+		if err != nil {
+			return nil, err
+		}
+
+		param := &NSEC3PARAM{header[0], header[1], binary.BigEndian.Uint16(header[2:]), []byte(salt)}
+		if len(salt) == 0 {
+			param.Salt = nil
+		}
+
+		if rtype == RecordType_NSEC3PARAM {
+			return param, nil
+		}
+
+		next, err := r.characterString()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(next) == 0 {
+			return nil, r.errorf("NSEC3 record has an empty next hashed owner name")
+		}
+
+		types, err := unpackTypeBitmap(r, end)
+		if err != nil {
+			return nil, err
+		}
+		return &NSEC3{param.HashAlgorithm, param.Flags, param.Iterations, param.Salt, []byte(next), types}, nil
+	}
+
+	return nil, r.errorf("%s has no typed record data", rtype)
+}
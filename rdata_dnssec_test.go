@@ -0,0 +1,132 @@
+package gozone
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDNSSECRDataParse(t *testing.T) {
+	records := []struct {
+		record   Record
+		expected RData
+	}{
+		{
+			Record{Type: RecordType_DS, Data: []string{"60485", "5", "1", "2BB183AF5F22588179A53B0A", "98631FAD1A292118"}},
+			&DS{60485, 5, 1, []byte{0x2b, 0xb1, 0x83, 0xaf, 0x5f, 0x22, 0x58, 0x81, 0x79, 0xa5, 0x3b, 0x0a, 0x98, 0x63, 0x1f, 0xad, 0x1a, 0x29, 0x21, 0x18}},
+		},
+		{
+			Record{Type: RecordType_DNSKEY, Data: []string{"257", "3", "15", "l02Woi0iS8Aa25FQkUd9RMzZ", "HJpBoRQwAQEX1SxZJA4="}},
+			&DNSKEY{257, 3, 15, []byte{
+				0x97, 0x4d, 0x96, 0xa2, 0x2d, 0x22, 0x4b, 0xc0, 0x1a, 0xdb, 0x91, 0x50, 0x91, 0x47, 0x7d, 0x44,
+				0xcc, 0xd9, 0x1c, 0x9a, 0x41, 0xa1, 0x14, 0x30, 0x01, 0x01, 0x17, 0xd5, 0x2c, 0x59, 0x24, 0x0e,
+			}},
+		},
+		{
+			Record{Type: RecordType_RRSIG, Data: []string{"A", "13", "2", "3600", "20240401000000", "20240301000000", "12345", "example.com.", "AQID"}},
+			&RRSIG{RecordType_A, 13, 2, 3600, 1711929600, 1709251200, 12345, "example.com.", []byte{1, 2, 3}},
+		},
+		{
+			Record{Type: RecordType_RRSIG, Data: []string{"MX", "8", "2", "300", "1711929600", "1709251200", "1", "example.com.", "AQID"}},
+			&RRSIG{RecordType_MX, 8, 2, 300, 1711929600, 1709251200, 1, "example.com.", []byte{1, 2, 3}},
+		},
+		{
+			Record{Type: RecordType_NSEC, Data: []string{"host.example.com.", "RRSIG", "A", "NSEC", "A", "TYPE1234"}},
+			&NSEC{"host.example.com.", []RecordType{RecordType_A, RecordType_RRSIG, RecordType_NSEC, 1234}},
+		},
+		{
+			Record{Type: RecordType_NSEC3, Data: []string{"1", "1", "12", "AABBCCDD", "2T7B4G4VSA5SMI47K61MV5BV1A22BOJR", "NS", "SOA", "RRSIG"}},
+			&NSEC3{1, 1, 12, []byte{0xaa, 0xbb, 0xcc, 0xdd}, []byte{
+				0x17, 0x4e, 0xb2, 0x40, 0x9f, 0xe2, 0x8b, 0xcb, 0x48, 0x87, 0xa1, 0x83, 0x6f, 0x95, 0x7f, 0x0a, 0x84, 0x25, 0xe2, 0x7b,
+			}, []RecordType{RecordType_NS, RecordType_SOA, RecordType_RRSIG}},
+		},
+		{
+			Record{Type: RecordType_NSEC3PARAM, Data: []string{"1", "0", "0", "-"}},
+			&NSEC3PARAM{1, 0, 0, nil},
+		},
+	}
+
+	for _, r := range records {
+		rdata, err := r.record.RData()
+		if err != nil {
+			t.Fatalf("Failed to parse %s record %v: %s", r.record.Type, r.record.Data, err)
+		}
+
+		if !reflect.DeepEqual(rdata, r.expected) {
+			t.Fatalf("%s record %v was parsed as %#v, expected %#v", r.record.Type, r.record.Data, rdata, r.expected)
+		}
+
+		reparsed, err := Record{Type: r.record.Type, Data: presentationFields(rdata.String())}.RData()
+		if err != nil || !reflect.DeepEqual(reparsed, rdata) {
+			t.Fatalf("%s record %q did not survive a round trip: %#v, %v", r.record.Type, rdata.String(), reparsed, err)
+		}
+	}
+}
+
+func TestDNSSECRDataParseErrors(t *testing.T) {
+	records := []Record{
+		{Type: RecordType_DS, Data: []string{"65536", "5", "1", "2BB1"}},
+		{Type: RecordType_DS, Data: []string{"1", "5", "1", "2BB"}},
+		{Type: RecordType_DNSKEY, Data: []string{"257", "3", "15", "not base64!"}},
+		{Type: RecordType_RRSIG, Data: []string{"A", "13", "2", "3600", "2024-04-01", "20240301000000", "1", "example.com.", "AQID"}},
+		{Type: RecordType_RRSIG, Data: []string{"NOTATYPE", "13", "2", "3600", "20240401000000", "20240301000000", "1", "example.com.", "AQID"}},
+		{Type: RecordType_NSEC, Data: []string{"host.example.com.", "NOTATYPE"}},
+		{Type: RecordType_NSEC3, Data: []string{"1", "0", "0", "-", "not-base32hex"}},
+		{Type: RecordType_NSEC3PARAM, Data: []string{"1", "0", "0"}},
+	}
+
+	for _, record := range records {
+		if rdata, err := record.RData(); err == nil {
+			t.Fatalf("Parsing %s record %v should have failed, got %s", record.Type, record.Data, rdata)
+		}
+	}
+}
+
+func TestDNSSECRDataWireRoundTrip(t *testing.T) {
+	rdatas := []RData{
+		&DS{60485, 5, 1, []byte{1, 2, 3, 4}},
+		&DNSKEY{256, 3, 13, []byte{5, 6, 7, 8}},
+		&RRSIG{RecordType_TXT, 13, 3, 60, 1711929600, 1709251200, 2, "example.com.", []byte{9, 10}},
+		&NSEC{"a.example.com.", []RecordType{RecordType_A, RecordType_MX, RecordType_RRSIG, RecordType_NSEC, RecordType_CAA, 1234}},
+		&NSEC3{1, 1, 10, []byte{0xab}, []byte{1, 2, 3, 4, 5}, []RecordType{RecordType_A, RecordType_RRSIG}},
+		&NSEC3{1, 0, 0, nil, []byte{1, 2, 3, 4, 5}, nil},
+		&NSEC3PARAM{1, 0, 10, []byte{0xab, 0xcd}},
+	}
+
+	for _, rdata := range rdatas {
+		record := Record{DomainName: "example.com.", TimeToLive: 60, Class: RecordClass_IN, Type: rdata.Type(), Data: presentationFields(rdata.String())}
+		wire, err := record.PackWire(nil)
+		if err != nil {
+			t.Fatalf("Failed to pack %s record: %s", rdata.Type(), err)
+		}
+
+		unpacked, _, err := UnpackRecord(wire, 0)
+		if err != nil {
+			t.Fatalf("Failed to unpack %s record: %s", rdata.Type(), err)
+		}
+
+		got, err := unpacked.RData()
+		if err != nil {
+			t.Fatalf("Failed to parse unpacked %s record %v: %s", rdata.Type(), unpacked.Data, err)
+		}
+
+		if !reflect.DeepEqual(got, rdata) {
+			t.Fatalf("%s record data was %#v after a round trip, expected %#v", rdata.Type(), got, rdata)
+		}
+	}
+}
+
+func TestTypeBitmap(t *testing.T) {
+	// RFC 4034 section 4.3
+	bitmap := packTypeBitmap(nil, []RecordType{RecordType_A, RecordType_MX, RecordType_RRSIG, RecordType_NSEC, 1234})
+	expected := []byte{
+		0x00, 0x06, 0x40, 0x01, 0x00, 0x00, 0x00, 0x03,
+		0x04, 0x1b, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x20,
+	}
+
+	if !reflect.DeepEqual(bitmap, expected) {
+		t.Fatalf("Type bitmap was %x, expected %x", bitmap, expected)
+	}
+}
@@ -0,0 +1,187 @@
+package gozone
+
+import (
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parseOneRecord(t *testing.T, spec string) Record {
+	t.Helper()
+
+	var r Record
+	s := NewScanner(strings.NewReader(spec))
+	if err := s.Next(&r); err != nil {
+		t.Fatalf("Failed to parse [%s]: %s", spec, err)
+	}
+
+	return r
+}
+
+func TestRDataTypes(t *testing.T) {
+	records := map[string]RData{
+		"adomain.com. 300 IN A 192.168.0.1":                &A{netip.MustParseAddr("192.168.0.1")},
+		"adomain.com. 300 IN AAAA 2001:db8::1":             &AAAA{netip.MustParseAddr("2001:db8::1")},
+		"adomain.com. 300 IN NS ns.ahostdomain.com.":       &NS{"ns.ahostdomain.com."},
+		"www.adomain.com. 300 IN CNAME adomain.com.":       &CNAME{"adomain.com."},
+		"1.0.168.192.in-addr.arpa. 300 IN PTR adomain.com": &PTR{"adomain.com"},
+		"adomain.com. 300 IN MX 10 smtp.ahostdomain.com.":  &MX{10, "smtp.ahostdomain.com."},
+		"adomain.com. 300 IN SOA ns.ahostdomain.com. hostmaster.ahostdomain.com. ( 1271271271 3h 1H 1w 300 )": &SOA{
+			"ns.ahostdomain.com.", "hostmaster.ahostdomain.com.", 1271271271, 10800, 3600, 604800, 300,
+		},
+		`adomain.com. 300 IN TXT "a \"b\" c" plain "\065\\"`:     &TXT{[]string{`a "b" c`, "plain", `A\`}},
+		`adomain.com. 300 IN HINFO "Intel x86" Linux`:            &HINFO{"Intel x86", "Linux"},
+		"adomain.com. 300 IN MINFO admin.adomain.com. errors":    &MINFO{"admin.adomain.com.", "errors"},
+		"adomain.com. 300 IN WKS 192.168.0.1 TCP ( smtp 21 25 )": &WKS{netip.MustParseAddr("192.168.0.1"), 6, []uint16{21, 25}},
+		`adomain.com. 300 IN A \# 4 C0A80001`:                    &A{netip.MustParseAddr("192.168.0.1")},
+		`adomain.com. 300 IN TYPE731 \# 3 ABCDEF`:                &GenericRData{RecordType(731), []byte{0xAB, 0xCD, 0xEF}},
+	}
+
+	for spec, expected := range records {
+		r := parseOneRecord(t, spec)
+		rdata, err := r.RData()
+		if err != nil {
+			t.Fatalf("Failed to decode RData of [%s]: %s", spec, err)
+		}
+
+		if !reflect.DeepEqual(rdata, expected) {
+			t.Fatalf("RData of [%s] was [%#v], expected [%#v]", spec, rdata, expected)
+		}
+
+		if rdata.Type() != r.Type {
+			t.Fatalf("RData of [%s] has Type %s, expected %s", spec, rdata.Type(), r.Type)
+		}
+	}
+}
+
+func TestRDataString(t *testing.T) {
+	records := map[string]string{
+		"adomain.com. 300 IN A 192.168.0.1":                                                          "192.168.0.1",
+		"adomain.com. 300 IN AAAA 2001:DB8:0::1":                                                     "2001:db8::1",
+		"adomain.com. 300 IN MX 10 smtp.ahostdomain.com.":                                            "10 smtp.ahostdomain.com.",
+		"adomain.com. 300 IN SOA ns.ahostdomain.com. hostmaster.ahostdomain.com. ( 1 3h 1H 1w 300 )": "ns.ahostdomain.com. hostmaster.ahostdomain.com. 1 10800 3600 604800 300",
+		`adomain.com. 300 IN TXT "a \"b\" c" plain "\007"`:                                           `"a \"b\" c" "plain" "\007"`,
+		`adomain.com. 300 IN HINFO "Intel x86" Linux`:                                                `"Intel x86" "Linux"`,
+		"adomain.com. 300 IN WKS 192.168.0.1 tcp 25 21":                                              "192.168.0.1 6 21 25",
+		`adomain.com. 300 IN TYPE731 \# 3 abcdef`:                                                    `\# 3 ABCDEF`,
+	}
+
+	for spec, expected := range records {
+		rdata, err := parseOneRecord(t, spec).RData()
+		if err != nil {
+			t.Fatalf("Failed to decode RData of [%s]: %s", spec, err)
+		}
+
+		if rdata.String() != expected {
+			t.Fatalf("RData of [%s] formatted as [%s], expected [%s]", spec, rdata.String(), expected)
+		}
+	}
+}
+
+func TestRDataGenericFormOfKnownTypes(t *testing.T) {
+	records := map[string]RData{
+		`www.adomain.com. 300 IN CNAME \# 5 03666F6F00`:        &CNAME{"foo."},
+		`adomain.com. 300 IN DNSKEY \# 7 0101030F010203`:       &DNSKEY{257, 3, 15, []byte{1, 2, 3}},
+		`adomain.com. 300 IN DNSKEY ( \# 7 0101 030F 010203 )`: &DNSKEY{257, 3, 15, []byte{1, 2, 3}},
+	}
+
+	for spec, expected := range records {
+		rdata, err := parseOneRecord(t, spec).RData()
+		if err != nil {
+			t.Fatalf("Failed to decode RData of [%s]: %s", spec, err)
+		}
+
+		if !reflect.DeepEqual(rdata, expected) {
+			t.Fatalf("RData of [%s] was [%#v], expected [%#v]", spec, rdata, expected)
+		}
+	}
+}
+
+func TestRDataUnsupportedType(t *testing.T) {
+	r := parseOneRecord(t, "_sip._tcp.adomain.com. 300 IN SRV 10 5 5060 sip.adomain.com.")
+	_, err := r.RData()
+	if !errors.Is(err, ErrUnsupportedRData) {
+		t.Fatalf("RData of an unsupported type did not return ErrUnsupportedRData: %v", err)
+	}
+}
+
+func TestInvalidRDataFailsParsing(t *testing.T) {
+	specs := []string{
+		"adomain.com. 300 IN A 192.168.0.256",
+		"adomain.com. 300 IN A 2001:db8::1",
+		"adomain.com. 300 IN A 192.168.0.1 192.168.0.2",
+		"adomain.com. 300 IN AAAA 192.168.0.1",
+		"adomain.com. 300 IN NS a.com. b.com.",
+		`adomain.com. 300 IN CNAME "quoted"`,
+		"adomain.com. 300 IN MX smtp.ahostdomain.com.",
+		"adomain.com. 300 IN MX 65536 smtp.ahostdomain.com.",
+		"adomain.com. 300 IN SOA ns.ahostdomain.com. hostmaster.ahostdomain.com. ( 1 2 3 4 )",
+		"adomain.com. 300 IN SOA ns.ahostdomain.com. hostmaster.ahostdomain.com. ( x 2 3 4 5 )",
+		`adomain.com. 300 IN TXT "\256"`,
+		`adomain.com. 300 IN TXT "\12"`,
+		`adomain.com. 300 IN TXT "` + strings.Repeat("a", 256) + `"`,
+		"adomain.com. 300 IN HINFO x86",
+		"adomain.com. 300 IN MINFO admin.adomain.com.",
+		"adomain.com. 300 IN WKS 192.168.0.1 nope 25",
+		"adomain.com. 300 IN WKS 192.168.0.1 tcp nope",
+		`adomain.com. 300 IN A \# 3 C0A800`,
+		`adomain.com. 300 IN CNAME \# 6 03666F6F0000`,
+	}
+
+	for _, spec := range specs {
+		var r Record
+		s := NewScanner(strings.NewReader(spec))
+		err := s.Next(&r)
+		if !errors.Is(err, ErrInvalidData) {
+			t.Fatalf("Parsing of invalid record [%s] did not return ErrInvalidData: %v", spec, err)
+		}
+	}
+}
+
+func TestScannerQualifiesRData(t *testing.T) {
+	zone := `$ORIGIN example.com.
+@     300 IN SOA ns1 hostmaster ( 1 3600 900 604800 300 )
+@     300 IN NS  ns1
+@     300 IN MX  10 @
+www   300 IN CNAME web.example.net.
+_sip._tcp 300 IN SRV 0 0 5060 sip
+@     300 IN RP  admin\.mail txt
+@     300 IN TXT mail
+`
+	expected := [][]string{
+		{"ns1.example.com.", "hostmaster.example.com.", "(", "1", "3600", "900", "604800", "300", ")"},
+		{"ns1.example.com."},
+		{"10", "example.com."},
+		{"web.example.net."},
+		{"0", "0", "5060", "sip.example.com."},
+		{`admin\.mail.example.com.`, "txt.example.com."},
+		{"mail"},
+	}
+
+	records := readAllRecords(t, zone)
+	for i, r := range records {
+		if !reflect.DeepEqual(r.Data, expected[i]) {
+			t.Fatalf("%s record had data %q, expected %q", r.Type, r.Data, expected[i])
+		}
+	}
+
+	s := NewScanner(strings.NewReader(zone))
+	s.SetKeepRelativeNames(true)
+	var r Record
+	for i := 0; i < 3; i++ {
+		if err := s.Next(&r); err != nil {
+			t.Fatalf("Failed to parse record: %s", err)
+		}
+	}
+
+	if !reflect.DeepEqual(r.Data, []string{"10", "@"}) {
+		t.Fatalf("MX record kept data %q, expected it as written", r.Data)
+	}
+
+	s = NewScanner(strings.NewReader("$ORIGIN example.com.\n@ 300 IN CNAME a..b\n"))
+	if err := s.Next(&r); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("An invalid name in record data should have failed, got %v", err)
+	}
+}
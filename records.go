@@ -0,0 +1,56 @@
+package gozone
+
+import (
+	"io"
+	"iter"
+	"strings"
+)
+
+// Records returns an iterator over the remaining records of the Scanner.
+// Iteration ends when the input is exhausted, without yielding io.EOF, or
+// after yielding the first error Next returns, with a zero Record. Breaking
+// out of the loop early leaves the Scanner positioned after the last record
+// yielded, so that Next or Records carry on from there; Close releases any
+// $INCLUDE'd files which are still open.
+func (s *Scanner) Records() iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		for {
+			var record Record
+			err := s.Next(&record)
+			if err == io.EOF {
+				return
+			}
+
+			if err != nil {
+				yield(Record{}, err)
+				return
+			}
+
+			if !yield(record, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ReadAll reads every record of a zone file. On error, it returns the
+// records read before the error along with it.
+func ReadAll(src io.Reader) ([]Record, error) {
+	s := NewScanner(src)
+	defer s.Close()
+
+	var records []Record
+	for record, err := range s.Records() {
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// ParseString reads every record of a zone file held in a string, as ReadAll.
+func ParseString(zone string) ([]Record, error) {
+	return ReadAll(strings.NewReader(zone))
+}
@@ -0,0 +1,91 @@
+package gozone
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const recordsZone = `$ORIGIN adomain.com.
+@   300 IN A 192.168.0.1
+www 300 IN A 192.168.0.2
+ftp 300 IN A 192.168.0.3
+`
+
+func TestScannerRecords(t *testing.T) {
+	var names []string
+	for record, err := range NewScanner(strings.NewReader(recordsZone)).Records() {
+		if err != nil {
+			t.Fatalf("Unexpected error when iterating records: %s", err)
+		}
+		names = append(names, record.DomainName)
+	}
+
+	if expected := "adomain.com. www.adomain.com. ftp.adomain.com."; strings.Join(names, " ") != expected {
+		t.Fatalf("Iterated records %v, expected %s", names, expected)
+	}
+}
+
+func TestScannerRecordsBreak(t *testing.T) {
+	s := NewScanner(strings.NewReader(recordsZone))
+	for record := range s.Records() {
+		if record.DomainName != "adomain.com." {
+			t.Fatalf("First record was for '%s', expected adomain.com.", record.DomainName)
+		}
+		break
+	}
+
+	var r Record
+	if err := s.Next(&r); err != nil || r.DomainName != "www.adomain.com." {
+		t.Fatalf("Next after breaking out of Records returned '%s', %v", r.DomainName, err)
+	}
+
+	var rest []string
+	for record, err := range s.Records() {
+		if err != nil {
+			t.Fatalf("Unexpected error when resuming records: %s", err)
+		}
+		rest = append(rest, record.DomainName)
+	}
+
+	if len(rest) != 1 || rest[0] != "ftp.adomain.com." {
+		t.Fatalf("Resumed iteration returned %v, expected ftp.adomain.com.", rest)
+	}
+}
+
+func TestScannerRecordsError(t *testing.T) {
+	s := NewScanner(strings.NewReader("adomain.com. 300 IN A 192.168.0.1\nadomain.com. 300 IN FAKE data\nadomain.com. 300 IN A 192.168.0.2\n"))
+
+	var records int
+	var errs []error
+	for record, err := range s.Records() {
+		if err != nil {
+			if record.DomainName != "" {
+				t.Fatalf("Error was yielded with a record for '%s'", record.DomainName)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		records++
+	}
+
+	if records != 1 || len(errs) != 1 || !errors.Is(errs[0], ErrUnknownType) {
+		t.Fatalf("Iteration returned %d records and errors %v, expected 1 record then an unknown type", records, errs)
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	records, err := ParseString(recordsZone)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("ParseString returned %d records, %v, expected 3", len(records), err)
+	}
+
+	records, err = ReadAll(strings.NewReader("adomain.com. 300 IN A 192.168.0.1\n@ 300 IN A 192.168.0.2\n"))
+	if !errors.Is(err, ErrNoOrigin) || len(records) != 1 {
+		t.Fatalf("ReadAll returned %d records, %v, expected 1 record and a missing origin", len(records), err)
+	}
+
+	if records, err = ParseString(""); err != nil || len(records) != 0 {
+		t.Fatalf("ParseString of nothing returned %d records, %v", len(records), err)
+	}
+}
@@ -0,0 +1,27 @@
+package gozone
+
+// SetErrorRecovery enables or disables error recovery. When enabled, Next
+// does not return ParseErrors: it records them, skips ahead to the start of
+// the next line outside of any parentheses, and carries on parsing. The
+// recorded errors are available from Errors once Next returns io.EOF.
+func (s *Scanner) SetErrorRecovery(recover bool) {
+	s.recover = recover
+}
+
+// Errors returns the ParseErrors recorded while error recovery was enabled,
+// in the order they were found.
+func (s *Scanner) Errors() []*ParseError {
+	return s.errors
+}
+
+// resync skips the remainder of the current line, so that parsing can
+// continue with the next record
+func (s *Scanner) resync() error {
+	for !s.lineEnded {
+		if _, err := s.nextToken(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package gozone
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestErrorRecoveryCollectsAllErrors(t *testing.T) {
+	zone := strings.Join([]string{
+		"$ORIGIN adomain.com.",
+		"www 300 IN A 192.168.1.1",
+		"bad 300 IN FAKE ( 192.168.1.2",
+		"  192.168.1.3 )",
+		"mail 300 IN MX 10 smtp.ahostdomain.com.",
+		"$UNKNOWN entry",
+		"nodata 300 IN A",
+		"ftp 300 IN A 192.168.1.4",
+		"",
+	}, "\n")
+
+	var r Record
+	var domains []string
+	s := NewScanner(strings.NewReader(zone))
+	s.SetErrorRecovery(true)
+
+	var err error
+	for err = s.Next(&r); err == nil; err = s.Next(&r) {
+		domains = append(domains, r.DomainName)
+	}
+
+	if err != io.EOF {
+		t.Fatalf("Parsing in error recovery mode did not end with EOF: %v", err)
+	}
+
+	expected := []string{"www.adomain.com.", "mail.adomain.com.", "ftp.adomain.com."}
+	if strings.Join(domains, " ") != strings.Join(expected, " ") {
+		t.Fatalf("Parsing in error recovery mode returned records %v, expected %v", domains, expected)
+	}
+
+	errs := s.Errors()
+	if len(errs) != 3 {
+		t.Fatalf("Parsing in error recovery mode recorded %d errors, expected 3: %v", len(errs), errs)
+	}
+
+	kinds := []error{ErrUnknownType, ErrUnknownControlEntry, ErrIncompleteRecord}
+	lines := []int{3, 6, 7}
+	for i, parseError := range errs {
+		if !errors.Is(parseError, kinds[i]) {
+			t.Fatalf("Error %d [%s] does not match [%s]", i, parseError, kinds[i])
+		}
+
+		if parseError.Line != lines[i] {
+			t.Fatalf("Error %d [%s] is not on line %d", i, parseError, lines[i])
+		}
+	}
+}
+
+func TestErrorRecoveryAfterUnexpectedEOF(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("www.adomain.com. 300 IN A 192.168.1.1\nbad.adomain.com. 300 IN TXT \"unterminated"))
+	s.SetErrorRecovery(true)
+
+	if err := s.Next(&r); err != nil {
+		t.Fatalf("Unexpected error when parsing in error recovery mode: %s", err)
+	}
+
+	if err := s.Next(&r); err != io.EOF {
+		t.Fatalf("Parsing in error recovery mode did not end with EOF: %v", err)
+	}
+
+	if len(s.Errors()) != 1 || !errors.Is(s.Errors()[0], ErrUnexpectedEOF) {
+		t.Fatalf("Parsing in error recovery mode did not record the unexpected end of input: %v", s.Errors())
+	}
+}
+
+func TestWithoutErrorRecoveryStopsAtFirstError(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("bad.adomain.com. 300 IN FAKE 192.168.1.1\nwww.adomain.com. 300 IN A 192.168.1.1\n"))
+
+	if err := s.Next(&r); err == nil {
+		t.Fatalf("Parsing without error recovery did not return an error")
+	}
+
+	if len(s.Errors()) != 0 {
+		t.Fatalf("Parsing without error recovery recorded errors")
+	}
+}
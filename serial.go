@@ -0,0 +1,153 @@
+package gozone
+
+// https://www.ietf.org/rfc/rfc1982.txt
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type SerialStrategy int
+
+const (
+	SerialStrategy_Increment SerialStrategy = iota // add one to the serial
+	SerialStrategy_Date                            // YYYYMMDDnn, today's date and a two-digit change number
+	SerialStrategy_UnixTime                        // the current time, in seconds since 1970
+)
+
+// CompareSerial compares SOA serial numbers using RFC 1982 serial number
+// arithmetic, returning -1, 0 or 1 as a is less than, equal to or greater
+// than b. ok is false where the comparison is undefined, which is when a and
+// b are exactly 2^31 apart.
+func CompareSerial(a, b uint32) (result int, ok bool) {
+	switch difference := a - b; {
+	case difference == 0:
+		return 0, true
+	case difference == 1<<31:
+		return 0, false
+	case difference < 1<<31:
+		return 1, true
+	}
+
+	return -1, true
+}
+
+// NextSerial returns the serial to follow current under strategy. It fails
+// where the strategy would not produce a serial greater than current.
+func NextSerial(current uint32, strategy SerialStrategy, now time.Time) (uint32, error) {
+	var next uint32
+	switch strategy {
+	case SerialStrategy_Increment:
+		next = current + 1
+	case SerialStrategy_Date:
+		now = now.UTC()
+		today := uint32(now.Year()*10000+int(now.Month())*100+now.Day()) * 100
+		next = today
+		if current >= today && current < today+99 {
+			next = current + 1
+		}
+	case SerialStrategy_UnixTime:
+		next = uint32(now.Unix())
+	default:
+		return 0, fmt.Errorf("Unknown serial strategy %d", strategy)
+	}
+
+	if result, ok := CompareSerial(next, current); !ok || result != 1 {
+		return 0, fmt.Errorf("Serial %d would not be greater than the current serial %d", next, current)
+	}
+
+	return next, nil
+}
+
+// serialField returns the index within Data of the serial of an SOA record
+func (r Record) serialField() (int, error) {
+	if r.Type != RecordType_SOA {
+		return 0, fmt.Errorf("%s record has no serial", r.Type)
+	}
+
+	if isGenericData(r.Data) {
+		return 0, fmt.Errorf("Cannot change the serial of an SOA record in the generic \\# form")
+	}
+
+	n := 0
+	for i, field := range r.Data {
+		if field == "(" || field == ")" {
+			continue
+		}
+
+		if n == 2 {
+			return i, nil
+		}
+		n++
+	}
+
+	return 0, fmt.Errorf("SOA record has no serial")
+}
+
+// Serial returns the serial of an SOA record.
+func (r Record) Serial() (uint32, error) {
+	rdata, err := r.RData()
+	if err != nil {
+		return 0, err
+	}
+
+	soa, ok := rdata.(*SOA)
+	if !ok {
+		return 0, fmt.Errorf("%s record has no serial", r.Type)
+	}
+
+	return soa.Serial, nil
+}
+
+// SetSerial changes the serial of an SOA record, leaving the rest of its
+// Data as written. It refuses a serial which is not greater than the current
+// one.
+func (r *Record) SetSerial(serial uint32) error {
+	current, err := r.Serial()
+	if err != nil {
+		return err
+	}
+
+	i, err := r.serialField()
+	if err != nil {
+		return err
+	}
+
+	if result, ok := CompareSerial(serial, current); !ok || result != 1 {
+		return fmt.Errorf("Serial %d is not greater than the current serial %d", serial, current)
+	}
+
+	data := append([]string{}, r.Data...)
+	data[i] = strconv.FormatUint(uint64(serial), 10)
+	r.Data = data
+	return nil
+}
+
+// BumpSerial changes the serial of an SOA record to the one which follows it
+// under strategy, returning the new serial.
+func (r *Record) BumpSerial(strategy SerialStrategy, now time.Time) (uint32, error) {
+	current, err := r.Serial()
+	if err != nil {
+		return 0, err
+	}
+
+	next, err := NextSerial(current, strategy, now)
+	if err != nil {
+		return 0, err
+	}
+
+	return next, r.SetSerial(next)
+}
+
+// BumpSerial changes the serial of the zone's SOA record, as
+// Record.BumpSerial.
+func (z *Zone) BumpSerial(strategy SerialStrategy, now time.Time) (uint32, error) {
+	for _, rrset := range z.nodes[labelsKey(z.labels)].rrsets {
+		if rrset.Type == RecordType_SOA {
+			return rrset.Records[0].BumpSerial(strategy, now)
+		}
+	}
+
+	return 0, fmt.Errorf("Zone %s has no SOA record", z.origin)
+}
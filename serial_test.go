@@ -0,0 +1,120 @@
+package gozone
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareSerial(t *testing.T) {
+	comparisons := []struct {
+		a, b   uint32
+		result int
+		ok     bool
+	}{
+		{1, 1, 0, true},
+		{2, 1, 1, true},
+		{1, 2, -1, true},
+		{0, 4294967295, 1, true},
+		{4294967295, 0, -1, true},
+		{2147483647, 0, 1, true},
+		{2147483648, 0, 0, false},
+		{0, 2147483648, 0, false},
+		{2147483649, 0, -1, true},
+	}
+
+	for _, c := range comparisons {
+		result, ok := CompareSerial(c.a, c.b)
+		if result != c.result || ok != c.ok {
+			t.Fatalf("CompareSerial(%d, %d) was (%d, %t), expected (%d, %t)", c.a, c.b, result, ok, c.result, c.ok)
+		}
+	}
+}
+
+func TestNextSerial(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	serials := []struct {
+		current  uint32
+		strategy SerialStrategy
+		next     uint32
+	}{
+		{1, SerialStrategy_Increment, 2},
+		{4294967295, SerialStrategy_Increment, 0},
+		{2024030400, SerialStrategy_Date, 2024030500},
+		{2024030500, SerialStrategy_Date, 2024030501},
+		{2024030517, SerialStrategy_Date, 2024030518},
+		{1, SerialStrategy_Date, 2024030500},
+		{1700000000, SerialStrategy_UnixTime, uint32(now.Unix())},
+	}
+
+	for _, s := range serials {
+		next, err := NextSerial(s.current, s.strategy, now)
+		if err != nil {
+			t.Fatalf("NextSerial(%d, %d) failed: %s", s.current, s.strategy, err)
+		}
+
+		if next != s.next {
+			t.Fatalf("NextSerial(%d, %d) was %d, expected %d", s.current, s.strategy, next, s.next)
+		}
+	}
+}
+
+func TestNextSerialRefusesToGoBackwards(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	serials := []struct {
+		current  uint32
+		strategy SerialStrategy
+	}{
+		{2024030599, SerialStrategy_Date},
+		{2024030600, SerialStrategy_Date},
+		{2024030500, SerialStrategy_UnixTime},
+		{uint32(now.Unix()), SerialStrategy_UnixTime},
+		{1, SerialStrategy(99)},
+	}
+
+	for _, s := range serials {
+		if next, err := NextSerial(s.current, s.strategy, now); err == nil {
+			t.Fatalf("NextSerial(%d, %d) gave %d rather than an error", s.current, s.strategy, next)
+		}
+	}
+}
+
+func TestRecordBumpSerial(t *testing.T) {
+	r := parseOneRecord(t, "adomain.com. 3600 IN SOA ns.adomain.com. hostmaster.adomain.com. ( 2024030400 ; serial\n 3h 1h 1w 300 )")
+
+	next, err := r.BumpSerial(SerialStrategy_Date, time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Failed to bump serial: %s", err)
+	}
+
+	if next != 2024030500 || strings.Join(r.Data, " ") != "ns.adomain.com. hostmaster.adomain.com. ( 2024030500 3h 1h 1w 300 )" {
+		t.Fatalf("Bumped serial to %d, with Data [%s]", next, strings.Join(r.Data, " "))
+	}
+
+	if err = r.SetSerial(2024030400); err == nil {
+		t.Fatalf("Setting the serial backwards did not return an error")
+	}
+
+	if err = r.SetSerial(2024030500); err == nil {
+		t.Fatalf("Setting the serial to its current value did not return an error")
+	}
+
+	a := parseOneRecord(t, "adomain.com. 3600 IN A 192.168.0.1")
+	if err = a.SetSerial(1); err == nil {
+		t.Fatalf("Setting the serial of an A record did not return an error")
+	}
+}
+
+func TestZoneBumpSerial(t *testing.T) {
+	z := loadLookupZone(t)
+
+	next, err := z.BumpSerial(SerialStrategy_Increment, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to bump zone serial: %s", err)
+	}
+
+	serial, err := z.RRset("adomain.com.", RecordClass_IN, RecordType_SOA).Records[0].Serial()
+	if err != nil || next != 2 || serial != 2 {
+		t.Fatalf("Bumped zone serial to %d, SOA has serial %d: %v", next, serial, err)
+	}
+}
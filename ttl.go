@@ -0,0 +1,108 @@
+package gozone
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type TTLFormat int
+
+const (
+	TTLFormat_Seconds TTLFormat = iota // plain seconds, eg: 5400
+	TTLFormat_Units                    // BIND-style units, eg: 1h30m
+)
+
+var ttlUnits = []struct {
+	unit    byte
+	seconds uint64
+}{
+	{'w', 7 * 24 * 60 * 60},
+	{'d', 24 * 60 * 60},
+	{'h', 60 * 60},
+	{'m', 60},
+	{'s', 1},
+}
+
+// ParseTTL parses a TTL given either in seconds, or as a series of numbers
+// with BIND-style unit suffixes (w, d, h, m or s, in any case), such as "1h30m"
+// or "2W". A trailing number without a suffix is counted in seconds.
+func ParseTTL(token string) (uint32, error) {
+	return parseTTL(token)
+}
+
+// parseTTL is ParseTTL for tokens still held as bytes, so that the Scanner
+// need not allocate strings for them
+func parseTTL[T string | []byte](token T) (uint32, error) {
+	if len(token) == 0 {
+		return 0, fmt.Errorf("Empty TimeToLive")
+	}
+
+	var total uint64
+	var number uint64
+	var hasNumber bool
+	for i := 0; i < len(token); i++ {
+		c := token[i]
+		if c >= '0' && c <= '9' {
+			number = number*10 + uint64(c-'0')
+			if number > math.MaxUint32 {
+				return 0, fmt.Errorf("TimeToLive '%s' is greater than MaxUint32", token)
+			}
+			hasNumber = true
+			continue
+		}
+
+		if !hasNumber {
+			return 0, fmt.Errorf("Invalid TimeToLive '%s'", token)
+		}
+
+		var seconds uint64
+		for _, unit := range ttlUnits {
+			if c|0x20 == unit.unit {
+				seconds = unit.seconds
+				break
+			}
+		}
+
+		if seconds == 0 {
+			return 0, fmt.Errorf("Invalid unit '%c' in TimeToLive '%s'", c, token)
+		}
+
+		total += number * seconds
+		if total > math.MaxUint32 {
+			return 0, fmt.Errorf("TimeToLive '%s' is greater than MaxUint32", token)
+		}
+		number = 0
+		hasNumber = false
+	}
+
+	total += number
+	if total > math.MaxUint32 {
+		return 0, fmt.Errorf("TimeToLive '%s' is greater than MaxUint32", token)
+	}
+
+	return uint32(total), nil
+}
+
+// FormatTTL writes a TTL either as plain seconds, or in the compact unit form
+// accepted by ParseTTL.
+func FormatTTL(ttl uint32, format TTLFormat) string {
+	if format != TTLFormat_Units || ttl == 0 {
+		return strconv.FormatUint(uint64(ttl), 10)
+	}
+
+	var out strings.Builder
+	remaining := uint64(ttl)
+	for _, unit := range ttlUnits {
+		if remaining < unit.seconds {
+			continue
+		}
+
+		_, _ = out.WriteString(strconv.FormatUint(remaining/unit.seconds, 10))
+		_ = out.WriteByte(unit.unit)
+		remaining %= unit.seconds
+	}
+
+	return out.String()
+}
@@ -0,0 +1,115 @@
+package gozone
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTTL(t *testing.T) {
+	check := map[string]uint32{
+		"0":          0,
+		"3600":       3600,
+		"1h":         3600,
+		"1H":         3600,
+		"30m":        1800,
+		"1h30m":      5400,
+		"1H30M15":    5415,
+		"2d":         172800,
+		"1w":         604800,
+		"1W1D1H1M1S": 694861,
+		"90s":        90,
+		"4294967295": 4294967295,
+	}
+
+	for spec, expected := range check {
+		ttl, err := ParseTTL(spec)
+		if err != nil {
+			t.Fatalf("Failed to parse TTL '%s': %s", spec, err)
+		}
+
+		if ttl != expected {
+			t.Fatalf("Parsing of TTL '%s' gave %d, expected %d", spec, ttl, expected)
+		}
+	}
+}
+
+func TestParseTTLInvalid(t *testing.T) {
+	specs := []string{"", "h", "1x", "1hh", "-1", "4294967296", "7102w", "1h 2m", "IN"}
+
+	for _, spec := range specs {
+		if _, err := ParseTTL(spec); err == nil {
+			t.Fatalf("Parsing of invalid TTL '%s' did not return an error", spec)
+		}
+	}
+}
+
+func TestFormatTTL(t *testing.T) {
+	check := map[uint32]string{
+		0:      "0",
+		59:     "59s",
+		3600:   "1h",
+		5400:   "1h30m",
+		86400:  "1d",
+		694861: "1w1d1h1m1s",
+	}
+
+	for ttl, expected := range check {
+		formatted := FormatTTL(ttl, TTLFormat_Units)
+		if formatted != expected {
+			t.Fatalf("Formatting of TTL %d gave '%s', expected '%s'", ttl, formatted, expected)
+		}
+
+		parsed, err := ParseTTL(formatted)
+		if err != nil || parsed != ttl {
+			t.Fatalf("Formatted TTL '%s' did not parse back to %d", formatted, ttl)
+		}
+	}
+
+	if formatted := FormatTTL(5400, TTLFormat_Seconds); formatted != "5400" {
+		t.Fatalf("Formatting of TTL in seconds gave '%s', expected '5400'", formatted)
+	}
+}
+
+func TestTimeToLiveControlEntryWithUnits(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("$TTL 1h\nadomain.com. IN A 192.168.1.1"))
+	err := s.Next(&r)
+	if err != nil {
+		t.Fatalf("Parsing of $TTL control entry with units returned an error: %s", err)
+	}
+
+	if r.TimeToLive != 3600 {
+		t.Fatalf("Parsing of $TTL control entry with units did not set the default TTL")
+	}
+}
+
+func TestRecordTimeToLiveWithUnits(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("adomain.com. 1H IN A 192.168.1.1"))
+	err := s.Next(&r)
+	if err != nil {
+		t.Fatalf("Parsing of record with TTL units returned an error: %s", err)
+	}
+
+	if r.TimeToLive != 3600 || r.Class != RecordClass_IN || r.Type != RecordType_A {
+		t.Fatalf("Parsing of record with TTL units gave unexpected record [%#v]", r)
+	}
+}
+
+func TestSOATimersWithUnits(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("adomain.com. 300 IN SOA ns.ahostdomain.com. hostmaster.ahostdomain.com. ( 1271271271 3h 1H 1w 5m )"))
+	err := s.Next(&r)
+	if err != nil {
+		t.Fatalf("Parsing of SOA record with timer units returned an error: %s", err)
+	}
+}
+
+func TestSOAInvalidTimerFails(t *testing.T) {
+	var r Record
+	s := NewScanner(strings.NewReader("adomain.com. 300 IN SOA ns.ahostdomain.com. hostmaster.ahostdomain.com. ( 1271271271 3x 1H 1w 5m )"))
+	err := s.Next(&r)
+	if err == nil {
+		t.Fatalf("Parsing of SOA record with an invalid timer did not return an error")
+	}
+}
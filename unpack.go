@@ -0,0 +1,489 @@
+package gozone
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+var ErrMalformedMessage = errors.New("malformed wire format message")
+
+// maxPointers bounds how many compression pointers a single name may follow
+const maxPointers = 126
+
+// wireReader reads a message, tracking the offset of the next field
+type wireReader struct {
+	msg []byte
+	off int
+}
+
+func (r *wireReader) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: at offset %d: %s", ErrMalformedMessage, r.off, fmt.Sprintf(format, args...))
+}
+
+func (r *wireReader) need(n int) error {
+	if n > len(r.msg)-r.off {
+		return r.errorf("need %d bytes, only %d remain", n, len(r.msg)-r.off)
+	}
+
+	return nil
+}
+
+func (r *wireReader) uint8() (uint8, error) {
+	if err := r.need(1); err != nil {
+		return 0, err
+	}
+	r.off++
+
+	return r.msg[r.off-1], nil
+}
+
+func (r *wireReader) uint16() (uint16, error) {
+	if err := r.need(2); err != nil {
+		return 0, err
+	}
+	r.off += 2
+
+	return binary.BigEndian.Uint16(r.msg[r.off-2:]), nil
+}
+
+func (r *wireReader) uint32() (uint32, error) {
+	if err := r.need(4); err != nil {
+		return 0, err
+	}
+	r.off += 4
+
+	return binary.BigEndian.Uint32(r.msg[r.off-4:]), nil
+}
+
+func (r *wireReader) bytes(n int) ([]byte, error) {
+	if err := r.need(n); err != nil {
+		return nil, err
+	}
+	r.off += n
+
+	return r.msg[r.off-n : r.off], nil
+}
+
+func (r *wireReader) characterString() (string, error) {
+	length, err := r.uint8()
+	if err != nil {
+		return "", err
+	}
+
+	s, err := r.bytes(int(length))
+	return string(s), err
+}
+
+// name reads a domain name in presentation format, following compression
+// pointers. Pointers must point backwards and are limited in number, which
+// rules out loops.
+func (r *wireReader) name() (string, error) {
+	var out strings.Builder
+	off := r.off
+	end := -1 // where reading continues, after the first pointer
+	pointers := 0
+	length := 1
+
+	for {
+		if off >= len(r.msg) {
+			return "", r.errorf("domain name runs past the end of the message")
+		}
+
+		c := int(r.msg[off])
+		switch c & 0xC0 {
+		case 0x00:
+		case 0xC0:
+			if off+1 >= len(r.msg) {
+				return "", r.errorf("compression pointer runs past the end of the message")
+			}
+
+			target := (c&0x3F)<<8 | int(r.msg[off+1])
+			if target >= off {
+				return "", r.errorf("compression pointer to offset %d does not point backwards", target)
+			}
+
+			pointers++
+			if pointers > maxPointers {
+				return "", r.errorf("domain name follows too many compression pointers")
+			}
+
+			if end == -1 {
+				end = off + 2
+			}
+			off = target
+			continue
+		default:
+			return "", r.errorf("unsupported label type 0x%02X", c&0xC0)
+		}
+
+		off++
+		if c == 0 {
+			break
+		}
+
+		if off+c > len(r.msg) {
+			return "", r.errorf("label runs past the end of the message")
+		}
+
+		length += 1 + c
+		if length > MaxNameLength {
+			return "", r.errorf("domain name is longer than %d bytes", MaxNameLength)
+		}
+
+		writeLabel(&out, r.msg[off:off+c])
+		_ = out.WriteByte('.')
+		off += c
+	}
+
+	if end == -1 {
+		end = off
+	}
+	r.off = end
+
+	if out.Len() == 0 {
+		return ".", nil
+	}
+
+	return out.String(), nil
+}
+
+// writeLabel writes a label in presentation format, escaping the characters
+// which would otherwise be read as something else
+func writeLabel(out *strings.Builder, label []byte) {
+	for _, c := range label {
+		switch {
+		case strings.IndexByte(`."\();@$`, c) != -1 || c == ' ':
+			_ = out.WriteByte('\\')
+			_ = out.WriteByte(c)
+		case c < ' ' || c > '~':
+			_, _ = fmt.Fprintf(out, "\\%03d", c)
+		default:
+			_ = out.WriteByte(c)
+		}
+	}
+}
+
+// UnpackMessage decodes a DNS message in wire format. The Data of each
+// record is given in presentation format, as read by a Scanner.
+func UnpackMessage(msg []byte) (*Message, error) {
+	r := &wireReader{msg: msg}
+	if err := r.need(12); err != nil {
+		return nil, err
+	}
+
+	id, _ := r.uint16()
+	flags, _ := r.uint16()
+	var counts [4]uint16
+	for i := range counts {
+		counts[i], _ = r.uint16()
+	}
+
+	m := &Message{
+		Header: MessageHeader{
+			ID:                 id,
+			Response:           flags&(1<<15) != 0,
+			Opcode:             uint8(flags>>11) & 0xF,
+			Authoritative:      flags&(1<<10) != 0,
+			Truncated:          flags&(1<<9) != 0,
+			RecursionDesired:   flags&(1<<8) != 0,
+			RecursionAvailable: flags&(1<<7) != 0,
+			AuthenticData:      flags&(1<<5) != 0,
+			CheckingDisabled:   flags&(1<<4) != 0,
+			RCode:              uint8(flags) & 0xF,
+		},
+	}
+
+	for i := 0; i < int(counts[0]); i++ {
+		name, err := r.name()
+		if err != nil {
+			return nil, err
+		}
+
+		rtype, err := r.uint16()
+		if err != nil {
+			return nil, err
+		}
+
+		class, err := r.uint16()
+		if err != nil {
+			return nil, err
+		}
+
+		m.Questions = append(m.Questions, Question{name, RecordType(rtype), RecordClass(class)})
+	}
+
+	for i, section := range []*[]Record{&m.Answers, &m.Authority, &m.Additional} {
+		for j := 0; j < int(counts[i+1]); j++ {
+			record, err := r.record()
+			if err != nil {
+				return nil, err
+			}
+			*section = append(*section, record)
+		}
+	}
+
+	if r.off != len(msg) {
+		return nil, r.errorf("%d bytes follow the last record", len(msg)-r.off)
+	}
+
+	return m, nil
+}
+
+// UnpackRecord decodes one wire format record, starting at offset within
+// msg, returning the record and the offset which follows it. Compression
+// pointers are followed within msg.
+func UnpackRecord(msg []byte, offset int) (Record, int, error) {
+	if offset < 0 || offset > len(msg) {
+		return Record{}, offset, fmt.Errorf("%w: offset %d is outside of the message", ErrMalformedMessage, offset)
+	}
+
+	r := &wireReader{msg: msg, off: offset}
+	record, err := r.record()
+	if err != nil {
+		return Record{}, offset, err
+	}
+
+	return record, r.off, nil
+}
+
+func (r *wireReader) record() (Record, error) {
+	name, err := r.name()
+	if err != nil {
+		return Record{}, err
+	}
+
+	rtype, err := r.uint16()
+	if err != nil {
+		return Record{}, err
+	}
+
+	class, err := r.uint16()
+	if err != nil {
+		return Record{}, err
+	}
+
+	ttl, err := r.uint32()
+	if err != nil {
+		return Record{}, err
+	}
+
+	length, err := r.uint16()
+	if err != nil {
+		return Record{}, err
+	}
+
+	if err = r.need(int(length)); err != nil {
+		return Record{}, err
+	}
+
+	record := Record{
+		DomainName: name,
+		TimeToLive: int64(ttl),
+		Class:      RecordClass(class),
+		Type:       RecordType(rtype),
+	}
+
+	end := r.off + int(length)
+	if schema := rdataSchemas[record.Type]; schema != nil {
+		record.Data, err = r.unpackFields(record.Type, schema, end)
+	} else {
+		var rdata RData
+		if rdata, err = r.rdata(record.Type, end); err == nil {
+			record.Data = presentationFields(rdata.String())
+		}
+	}
+	if err != nil {
+		return Record{}, err
+	}
+
+	if r.off != end {
+		return Record{}, r.errorf("%s record data is %d bytes long, but %d were read", record.Type, length, int(length)-(end-r.off))
+	}
+
+	return record, nil
+}
+
+// unpackRData decodes record data held in wire format on its own, as given
+// in the RFC 3597 \# form, so that names within it cannot be compressed
+func unpackRData(rtype RecordType, data []byte) (RData, error) {
+	r := &wireReader{msg: data}
+	rdata, err := r.rdata(rtype, len(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if r.off != len(data) {
+		return nil, r.errorf("%s record data is %d bytes long, but %d were read", rtype, len(data), r.off)
+	}
+
+	return rdata, nil
+}
+
+// rdata reads typed record data ending at end. Types without typed RData are
+// read as GenericRData; record reads those with a schema by unpackFields.
+func (r *wireReader) rdata(rtype RecordType, end int) (RData, error) {
+	// names within the data must stay within it, but may point anywhere before
+	bounded := &wireReader{msg: r.msg[:end], off: r.off}
+	defer func() { r.off = bounded.off }()
+
+	switch rtype {
+	case RecordType_A, RecordType_AAAA:
+		size := 4
+		if rtype == RecordType_AAAA {
+			size = 16
+		}
+		if end-r.off != size {
+			return nil, r.errorf("%s record data is %d bytes long, expected %d", rtype, end-r.off, size)
+		}
+
+		b, _ := bounded.bytes(size)
+		address, _ := netip.AddrFromSlice(b)
+		if rtype == RecordType_A {
+			return &A{Address: address}, nil
+		}
+		return &AAAA{Address: address}, nil
+	case RecordType_NS, RecordType_CNAME, RecordType_PTR:
+		name, err := bounded.name()
+		if err != nil {
+			return nil, err
+		}
+
+		switch rtype {
+		case RecordType_NS:
+			return &NS{Host: name}, nil
+		case RecordType_CNAME:
+			return &CNAME{Target: name}, nil
+		}
+		return &PTR{Target: name}, nil
+	case RecordType_MX:
+		preference, err := bounded.uint16()
+		if err != nil {
+			return nil, err
+		}
+
+		exchange, err := bounded.name()
+		if err != nil {
+			return nil, err
+		}
+		return &MX{Preference: preference, Exchange: exchange}, nil
+	case RecordType_SOA:
+		mname, err := bounded.name()
+		if err != nil {
+			return nil, err
+		}
+
+		rname, err := bounded.name()
+		if err != nil {
+			return nil, err
+		}
+
+		var values [5]uint32
+		for i := range values {
+			if values[i], err = bounded.uint32(); err != nil {
+				return nil, err
+			}
+		}
+		return &SOA{mname, rname, values[0], values[1], values[2], values[3], values[4]}, nil
+	case RecordType_TXT:
+		txt := &TXT{}
+		for bounded.off < end || len(txt.Strings) == 0 {
+			s, err := bounded.characterString()
+			if err != nil {
+				return nil, err
+			}
+			txt.Strings = append(txt.Strings, s)
+		}
+		return txt, nil
+	case RecordType_HINFO:
+		cpu, err := bounded.characterString()
+		if err != nil {
+			return nil, err
+		}
+
+		os, err := bounded.characterString()
+		if err != nil {
+			return nil, err
+		}
+		return &HINFO{CPU: cpu, OS: os}, nil
+	case RecordType_MINFO:
+		rmailbox, err := bounded.name()
+		if err != nil {
+			return nil, err
+		}
+
+		emailbox, err := bounded.name()
+		if err != nil {
+			return nil, err
+		}
+		return &MINFO{RMailbox: rmailbox, EMailbox: emailbox}, nil
+	case RecordType_WKS:
+		b, err := bounded.bytes(5)
+		if err != nil {
+			return nil, err
+		}
+
+		// the bitmap has a bit for each port, up to 65535
+		if end-bounded.off > 65536/8 {
+			return nil, bounded.errorf("WKS bitmap is %d bytes long, past port 65535", end-bounded.off)
+		}
+
+		address, _ := netip.AddrFromSlice(b[:4])
+		wks := &WKS{Address: address, Protocol: b[4]}
+		bitmap, _ := bounded.bytes(end - bounded.off)
+		for i, bits := range bitmap {
+			for bit := 0; bit < 8; bit++ {
+				if bits&(0x80>>bit) != 0 {
+					wks.Ports = append(wks.Ports, uint16(i*8+bit))
+				}
+			}
+		}
+		return wks, nil
+	case RecordType_ZONEMD:
+		serial, err := bounded.uint32()
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := bounded.bytes(2)
+		if err != nil {
+			return nil, err
+		}
+
+		digest, _ := bounded.bytes(end - bounded.off)
+		return &ZONEMD{Serial: serial, Scheme: b[0], HashAlgorithm: b[1], Digest: append([]byte(nil), digest...)}, nil
+	}
+
+	if _, ok := rdataParsers[rtype]; ok {
+		return bounded.dnssecRData(rtype, end)
+	}
+
+	data, _ := bounded.bytes(end - bounded.off)
+	return &GenericRData{RType: rtype, Data: append([]byte(nil), data...)}, nil
+}
+
+// presentationFields splits presentation format record data into the fields
+// a Scanner would read from it
+func presentationFields(text string) []string {
+	var fields []string
+	inParens := false
+	for len(text) != 0 {
+		token, err := lexCSTToken(text, inParens)
+		if err != nil {
+			// only produced by unterminated quoted strings, which String never writes
+			return append(fields, text)
+		}
+		text = text[len(token.Text):]
+
+		switch token.Kind {
+		case CSTKind_Word, CSTKind_String:
+			fields = append(fields, token.Text)
+		case CSTKind_OpenParen, CSTKind_CloseParen:
+			inParens = token.Kind == CSTKind_OpenParen
+		}
+	}
+
+	return fields
+}
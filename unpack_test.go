@@ -0,0 +1,136 @@
+package gozone
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnpackMessageRoundTrip(t *testing.T) {
+	zone := `$ORIGIN adomain.com.
+@ 3600 IN SOA ns.adomain.com. hostmaster.adomain.com. 1271271271 10800 3600 604800 300
+@ 3600 IN NS ns.adomain.com.
+@ 3600 IN MX 10 smtp.adomain.com.
+www 300 IN A 192.168.0.1
+www 300 IN AAAA 2001:db8::1
+txt 300 IN TXT "a \"quoted\" string" "\000\255"
+hinfo 300 IN HINFO "Intel x86" Linux
+minfo 300 IN MINFO admin.adomain.com. errors.adomain.com.
+wks 300 IN WKS 192.168.0.1 tcp 21 25
+ptr 300 IN PTR www.adomain.com.
+cname 300 IN CNAME www.adomain.com.
+generic 300 IN TYPE731 \# 3 abcdef
+srv 300 IN SRV 10 5 80 www.adomain.com.
+caa 300 IN CAA 0 issue "ca.example.net"
+`
+	records := readAllRecords(t, zone)
+
+	m := &Message{
+		Header:     MessageHeader{ID: 0xBEEF, Response: true, Opcode: 2, Truncated: true, RecursionDesired: true, RecursionAvailable: true, AuthenticData: true, CheckingDisabled: true, RCode: 5},
+		Questions:  []Question{{"adomain.com.", RecordType_SOA, RecordClass_IN}},
+		Answers:    records[:1],
+		Authority:  records[1:3],
+		Additional: records[3:],
+	}
+
+	packed, err := m.Pack()
+	if err != nil {
+		t.Fatalf("Failed to pack message: %s", err)
+	}
+
+	unpacked, err := UnpackMessage(packed)
+	if err != nil {
+		t.Fatalf("Failed to unpack message: %s", err)
+	}
+
+	if unpacked.Header != m.Header || !reflect.DeepEqual(unpacked.Questions, m.Questions) {
+		t.Fatalf("Unpacked message header and question [%v %v], expected [%v %v]", unpacked.Header, unpacked.Questions, m.Header, m.Questions)
+	}
+
+	sections := [][2][]Record{
+		{unpacked.Answers, m.Answers},
+		{unpacked.Authority, m.Authority},
+		{unpacked.Additional, m.Additional},
+	}
+	for _, section := range sections {
+		if len(section[0]) != len(section[1]) {
+			t.Fatalf("Unpacked section has %d records, expected %d", len(section[0]), len(section[1]))
+		}
+
+		for i, r := range section[0] {
+			expected := section[1][i]
+			expected.Position = Position{}
+			if !sameRecord(r, expected) {
+				t.Fatalf("Unpacked record [%s], expected [%s]", r, expected)
+			}
+		}
+	}
+}
+
+func TestUnpackRecordData(t *testing.T) {
+	records := map[string]string{
+		"a.com. 300 IN A 192.168.0.1":                "192.168.0.1",
+		`a.com. 300 IN TXT "a b" c`:                  `"a b" "c"`,
+		`a.com. 300 IN NS n\.s.a.com.`:               `n\.s.a.com.`,
+		`a.com. 300 IN PTR \032\(\;.`:                `\ \(\;.`,
+		`a.com. 300 IN CNAME .`:                      ".",
+		`a.com. 300 IN TYPE731 \# 0`:                 `\# 0`,
+		"a.com. 300 IN WKS 192.168.0.1 6 25 21 1000": "192.168.0.1 6 21 25 1000",
+		`a.com. 300 IN SRV \# 7 000A0005005000`:      "10 5 80 .",
+		`a.com. 300 IN CAA 0 issue "ca;\"x\""`:       `0 issue "ca;\"x\""`,
+		"a.com. 300 IN TLSA 3 1 1 ( abcd ef01 )":     "3 1 1 ABCDEF01",
+		"a.com. 300 IN OPENPGPKEY AQID":              "AQID",
+		`a.com. 300 IN SPF "v=spf1" "-all"`:          `"v=spf1" "-all"`,
+		`a.com. 300 IN SSHFP \# 2 0102`:              `\# 2 0102`,
+	}
+
+	for spec, expected := range records {
+		packed, err := parseOneRecord(t, spec).PackWire(nil)
+		if err != nil {
+			t.Fatalf("Failed to pack [%s]: %s", spec, err)
+		}
+
+		r, next, err := UnpackRecord(packed, 0)
+		if err != nil {
+			t.Fatalf("Failed to unpack [%s]: %s", spec, err)
+		}
+
+		if next != len(packed) {
+			t.Fatalf("Unpacking [%s] ended at offset %d, expected %d", spec, next, len(packed))
+		}
+
+		if strings.Join(r.Data, " ") != expected {
+			t.Fatalf("Unpacked data of [%s] as [%s], expected [%s]", spec, strings.Join(r.Data, " "), expected)
+		}
+	}
+}
+
+func TestUnpackMalformedMessages(t *testing.T) {
+	header := []byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	messages := map[string][]byte{
+		"short header":             header[:11],
+		"missing question":         header,
+		"truncated label":          append(header, 3, 'a', 'b'),
+		"pointer past end":         append(header, 0xC0),
+		"pointer to itself":        append(header, 0xC0, 12, 0, 1, 0, 1),
+		"pointer forwards":         append(header, 0xC0, 14, 0, 0, 1, 0, 1),
+		"pointer loop":             append(header, 1, 'a', 0xC0, 12, 0, 1, 0, 1),
+		"extended label":           append(header, 0x40, 0, 0, 1, 0, 1),
+		"trailing bytes":           append(header, 0, 0, 1, 0, 1, 0),
+		"truncated question":       append(header, 0, 0, 1),
+		"name too long":            append(append(header, []byte(strings.Repeat("\x3f"+strings.Repeat("a", 63), 4))...), 0, 0, 1, 0, 1),
+		"answer past end":          {0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 1, 0, 1, 0, 0, 0, 0, 0, 4, 1},
+		"answer with short A data": {0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 1, 0, 1, 0, 0, 0, 0, 0, 3, 1, 2, 3},
+		"rdata name past rdata":    {0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 2, 0, 1, 0, 0, 0, 0, 0, 1, 1, 0},
+		"rdata with extra bytes":   {0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 2, 0, 1, 0, 0, 0, 0, 0, 2, 0, 0},
+		"short SRV data":           {0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 33, 0, 1, 0, 0, 0, 0, 0, 3, 0, 1, 0},
+		"WKS bitmap past 65535":    append([]byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 11, 0, 1, 0, 0, 0, 0, 0x20, 6, 192, 168, 0, 1, 6}, make([]byte, 8193)...),
+	}
+
+	for description, msg := range messages {
+		if _, err := UnpackMessage(msg); !errors.Is(err, ErrMalformedMessage) {
+			t.Fatalf("Unpacking malformed message (%s) did not return ErrMalformedMessage: %v", description, err)
+		}
+	}
+}
@@ -0,0 +1,491 @@
+package gozone
+
+// https://www.ietf.org/rfc/rfc4035.txt
+// https://www.ietf.org/rfc/rfc5155.txt
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The rule IDs of Validate diagnostics
+const (
+	DNSSECRule_NoDNSKEY         = "no-dnskey"         // RFC 4035 section 2.1: the apex has the zone's DNSKEY RRset
+	DNSSECRule_UnknownKey       = "unknown-key"       // RFC 4035 section 5.3.1: an RRSIG names a key the zone does not have
+	DNSSECRule_BadSignature     = "bad-signature"     // RFC 4035 section 5.3: the signature does not verify
+	DNSSECRule_ExpiredSignature = "expired-signature" // RFC 4035 section 5.3.1: the signature expiration has passed
+	DNSSECRule_FutureSignature  = "future-signature"  // RFC 4035 section 5.3.1: the signature inception has not yet come
+	DNSSECRule_MissingSignature = "missing-signature" // RFC 4035 section 2.2: each authoritative RRset is signed with each algorithm
+	DNSSECRule_Orphan           = "orphan"            // a signature or NSEC/NSEC3 record for data the zone does not hold
+	DNSSECRule_BrokenChain      = "broken-chain"      // RFC 4035 section 2.3, RFC 5155 section 7.1: the chain covers every name, in order
+	DNSSECRule_DSMismatch       = "ds-mismatch"       // RFC 4035 section 5.2: a DS record matches a key which signs the DNSKEY RRset
+)
+
+// ValidateOptions control Validate.
+type ValidateOptions struct {
+	Now time.Time // the time signatures must be valid at; the current time when zero
+	DS  []Record  // the zone's DS records in its parent, to check the DNSKEY RRset against
+}
+
+// ValidateZone loads a signed zone from s and checks it, as Validate.
+func ValidateZone(s *Scanner, options ValidateOptions) ([]Diagnostic, error) {
+	z, err := LoadZone(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return Validate(z, options), nil
+}
+
+// Validate checks a signed zone without querying the DNS: every RRSIG must
+// verify with one of the zone's DNSKEYs and be within its validity period,
+// every authoritative RRset must be signed, and the NSEC or NSEC3 chain must
+// cover every name and be closed. Diagnostics are ordered by position.
+func Validate(z *Zone, options ValidateOptions) []Diagnostic {
+	if options.Now.IsZero() {
+		options.Now = time.Now()
+	}
+
+	v := &validator{z: z, now: uint32(options.Now.Unix()), signsKeys: make(map[*DNSKEY]bool)}
+	if soa := z.apexSOA(); soa != nil {
+		v.soa = soa.Records[0]
+		v.class = soa.Class
+	}
+
+	v.dnskeys = z.nodes[labelsKey(z.labels)].rrset(v.class, RecordType_DNSKEY)
+	if v.dnskeys == nil {
+		v.report(v.soa.Position, Severity_Error, DNSSECRule_NoDNSKEY, "Zone %s has no DNSKEY records", z.origin)
+	} else {
+		v.loadKeys()
+	}
+
+	v.checkSignatures()
+	v.checkChain()
+	if len(options.DS) != 0 {
+		v.checkDS(options.DS)
+	}
+
+	sortDiagnostics(v.diagnostics)
+	return v.diagnostics
+}
+
+type validator struct {
+	z           *Zone
+	now         uint32
+	soa         Record
+	class       RecordClass
+	dnskeys     *RRset
+	keys        []*DNSKEY
+	algorithms  []uint8
+	signsKeys   map[*DNSKEY]bool // keys with a valid signature over the DNSKEY RRset
+	diagnostics []Diagnostic
+}
+
+func (v *validator) report(position Position, severity Severity, rule string, format string, args ...interface{}) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{
+		Position: position,
+		Severity: severity,
+		Rule:     rule,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// loadKeys collects the zone keys of the DNSKEY RRset, and their algorithms
+func (v *validator) loadKeys() {
+	for _, record := range v.dnskeys.Records {
+		key, err := rdataAs[*DNSKEY](record)
+		if err != nil {
+			v.report(record.Position, Severity_Error, DNSSECRule_NoDNSKEY, "DNSKEY record is malformed: %s", err)
+			continue
+		}
+
+		if key.Flags&DNSKEYFlag_ZoneKey == 0 || key.Protocol != 3 {
+			continue
+		}
+
+		v.keys = append(v.keys, key)
+		known := false
+		for _, algorithm := range v.algorithms {
+			known = known || algorithm == key.Algorithm
+		}
+		if !known {
+			v.algorithms = append(v.algorithms, key.Algorithm)
+		}
+	}
+
+	if len(v.keys) == 0 {
+		v.report(v.dnskeys.Records[0].Position, Severity_Error, DNSSECRule_NoDNSKEY, "Zone %s has no DNSKEY records with the zone key flag", v.z.origin)
+	}
+}
+
+func (v *validator) checkSignatures() {
+	algorithms := make(map[*RRset]map[uint8]bool)
+	for _, rrset := range v.z.rrsets {
+		if rrset.Type != RecordType_RRSIG {
+			continue
+		}
+
+		for _, record := range rrset.Records {
+			rrsig, err := rdataAs[*RRSIG](record)
+			if err != nil {
+				v.report(record.Position, Severity_Error, DNSSECRule_BadSignature, "RRSIG record for %s is malformed: %s", record.DomainName, err)
+				continue
+			}
+
+			labels, _ := nameLabels(record.DomainName)
+			covered := v.z.nodes[labelsKey(labels)].rrset(record.Class, rrsig.TypeCovered)
+			if covered == nil || !v.z.isSigned(covered) {
+				v.report(record.Position, Severity_Warning, DNSSECRule_Orphan, "RRSIG record for %s covers %s, which is not signed data of the zone", record.DomainName, rrsig.TypeCovered)
+				continue
+			}
+
+			if algorithms[covered] == nil {
+				algorithms[covered] = make(map[uint8]bool)
+			}
+			algorithms[covered][rrsig.Algorithm] = true
+
+			v.checkSignature(record, rrsig, labels, covered)
+		}
+	}
+
+	for _, rrset := range v.z.rrsets {
+		if rrset.Type == RecordType_RRSIG || !v.z.isSigned(rrset) {
+			continue
+		}
+
+		if len(algorithms[rrset]) == 0 {
+			v.report(rrset.Records[0].Position, Severity_Error, DNSSECRule_MissingSignature, "%s %s is not signed", rrset.DomainName, rrset.Type)
+			continue
+		}
+
+		for _, algorithm := range v.algorithms {
+			if !algorithms[rrset][algorithm] {
+				v.report(rrset.Records[0].Position, Severity_Error, DNSSECRule_MissingSignature, "%s %s has no signature with algorithm %d", rrset.DomainName, rrset.Type, algorithm)
+			}
+		}
+	}
+}
+
+func (v *validator) checkSignature(record Record, rrsig *RRSIG, labels []string, covered *RRset) {
+	if ownerKey(rrsig.SignerName) != labelsKey(v.z.labels) {
+		v.report(record.Position, Severity_Error, DNSSECRule_BadSignature, "RRSIG record for %s %s is signed by %s, not by the zone %s", record.DomainName, rrsig.TypeCovered, rrsig.SignerName, v.z.origin)
+		return
+	}
+
+	count := len(labels)
+	if count != 0 && labels[0] == "*" {
+		count--
+	}
+	if int(rrsig.Labels) != count {
+		v.report(record.Position, Severity_Error, DNSSECRule_BadSignature, "RRSIG record for %s %s has a label count of %d, expected %d", record.DomainName, rrsig.TypeCovered, rrsig.Labels, count)
+		return
+	}
+
+	var keys []*DNSKEY
+	for _, key := range v.keys {
+		if key.Algorithm == rrsig.Algorithm && key.KeyTag() == rrsig.KeyTag {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		v.report(record.Position, Severity_Error, DNSSECRule_UnknownKey, "RRSIG record for %s %s is signed by key %d with algorithm %d, which is not in the DNSKEY RRset",
+			record.DomainName, rrsig.TypeCovered, rrsig.KeyTag, rrsig.Algorithm)
+		return
+	}
+
+	data, err := signedData(rrsig, covered)
+	if err != nil {
+		v.report(record.Position, Severity_Error, DNSSECRule_BadSignature, "RRSIG record for %s %s covers data which cannot be written in wire format: %s", record.DomainName, rrsig.TypeCovered, err)
+		return
+	}
+
+	var signer *DNSKEY
+	for _, key := range keys {
+		if verify(key, data, rrsig.Signature) == nil {
+			signer = key
+			break
+		}
+	}
+
+	if signer == nil {
+		v.report(record.Position, Severity_Error, DNSSECRule_BadSignature, "RRSIG record for %s %s by key %d does not verify", record.DomainName, rrsig.TypeCovered, rrsig.KeyTag)
+		return
+	}
+
+	timely := true
+	if result, _ := CompareSerial(v.now, rrsig.Inception); result < 0 {
+		timely = false
+		v.report(record.Position, Severity_Error, DNSSECRule_FutureSignature, "RRSIG record for %s %s is not valid until %s", record.DomainName, rrsig.TypeCovered, formatSignatureTime(rrsig.Inception))
+	}
+
+	if result, _ := CompareSerial(v.now, rrsig.Expiration); result > 0 {
+		timely = false
+		v.report(record.Position, Severity_Error, DNSSECRule_ExpiredSignature, "RRSIG record for %s %s expired at %s", record.DomainName, rrsig.TypeCovered, formatSignatureTime(rrsig.Expiration))
+	}
+
+	if timely && covered == v.dnskeys {
+		v.signsKeys[signer] = true
+	}
+}
+
+// position returns where the records of a name start, or the SOA record for
+// an empty non-terminal
+func (v *validator) position(node *zoneNode) Position {
+	if len(node.rrsets) != 0 {
+		return node.rrsets[0].Records[0].Position
+	}
+
+	return v.soa.Position
+}
+
+// chainTypes returns the types an NSEC or NSEC3 record should list for a node
+func (v *validator) chainTypes(node *zoneNode) []RecordType {
+	types := v.z.chainTypes(node)
+	if v.z.status(node.labels) == nameStatus_Delegation {
+		for _, rtype := range []RecordType{RecordType_RRSIG, RecordType_NSEC} {
+			if node.hasType(rtype) {
+				types = append(types, rtype)
+			}
+		}
+	}
+
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	unique := types[:0]
+	for i, rtype := range types {
+		if i == 0 || rtype != types[i-1] {
+			unique = append(unique, rtype)
+		}
+	}
+
+	return unique
+}
+
+func (v *validator) checkTypes(position Position, rtype RecordType, name string, listed []RecordType, node *zoneNode) {
+	expected := v.chainTypes(node)
+	if strings.Join(typeNames(listed), " ") != strings.Join(typeNames(expected), " ") {
+		v.report(position, Severity_Error, DNSSECRule_BrokenChain, "%s record for %s lists types [%s], but the name has [%s]",
+			rtype, name, strings.Join(typeNames(listed), " "), strings.Join(typeNames(expected), " "))
+	}
+}
+
+func (v *validator) checkChain() {
+	hasNSEC, hasNSEC3 := false, false
+	for _, rrset := range v.z.rrsets {
+		hasNSEC = hasNSEC || rrset.Type == RecordType_NSEC
+		hasNSEC3 = hasNSEC3 || rrset.Type == RecordType_NSEC3
+	}
+
+	apex := v.z.nodes[labelsKey(v.z.labels)]
+	switch {
+	case hasNSEC3 || apex.hasType(RecordType_NSEC3PARAM):
+		v.checkNSEC3Chain(apex)
+	case hasNSEC:
+		v.checkNSECChain()
+	default:
+		v.report(v.soa.Position, Severity_Error, DNSSECRule_BrokenChain, "Zone %s has no NSEC or NSEC3 records", v.z.origin)
+	}
+}
+
+func (v *validator) checkNSECChain() {
+	nodes := v.z.chainNodes(false)
+	inChain := make(map[string]bool)
+	for i, node := range nodes {
+		name := joinLabels(node.labels)
+		inChain[labelsKey(node.labels)] = true
+
+		nsec := node.rrset(v.class, RecordType_NSEC)
+		if nsec == nil {
+			v.report(v.position(node), Severity_Error, DNSSECRule_BrokenChain, "%s has no NSEC record", name)
+			continue
+		}
+
+		record := nsec.Records[0]
+		if len(nsec.Records) > 1 {
+			v.report(nsec.Records[1].Position, Severity_Error, DNSSECRule_BrokenChain, "%s has more than one NSEC record", name)
+		}
+
+		nsecData, err := rdataAs[*NSEC](record)
+		if err != nil {
+			v.report(record.Position, Severity_Error, DNSSECRule_BrokenChain, "NSEC record for %s is malformed: %s", name, err)
+			continue
+		}
+
+		next := nodes[(i+1)%len(nodes)]
+		if ownerKey(nsecData.NextDomain) != labelsKey(next.labels) {
+			v.report(record.Position, Severity_Error, DNSSECRule_BrokenChain, "NSEC record for %s points to %s, expected %s", name, nsecData.NextDomain, joinLabels(next.labels))
+		}
+
+		v.checkTypes(record.Position, RecordType_NSEC, name, nsecData.Types, node)
+	}
+
+	for _, rrset := range v.z.rrsets {
+		labels, _ := nameLabels(rrset.DomainName)
+		if rrset.Type == RecordType_NSEC && !inChain[labelsKey(labels)] {
+			v.report(rrset.Records[0].Position, Severity_Warning, DNSSECRule_Orphan, "NSEC record for %s is not part of the chain, as the name is not authoritative", rrset.DomainName)
+		}
+	}
+}
+
+type nsec3Link struct {
+	record  Record
+	nsec3   *NSEC3
+	hash    []byte
+	matched bool
+}
+
+func (v *validator) checkNSEC3Chain(apex *zoneNode) {
+	var param *NSEC3PARAM
+	if rrset := apex.rrset(v.class, RecordType_NSEC3PARAM); rrset != nil {
+		var err error
+		if param, err = rdataAs[*NSEC3PARAM](rrset.Records[0]); err != nil {
+			v.report(rrset.Records[0].Position, Severity_Error, DNSSECRule_BrokenChain, "NSEC3PARAM record is malformed: %s", err)
+		}
+	}
+
+	var links []*nsec3Link
+	for _, rrset := range v.z.rrsets {
+		if rrset.Type != RecordType_NSEC3 {
+			continue
+		}
+
+		for _, record := range rrset.Records {
+			nsec3, err := rdataAs[*NSEC3](record)
+			if err != nil {
+				v.report(record.Position, Severity_Error, DNSSECRule_BrokenChain, "NSEC3 record for %s is malformed: %s", record.DomainName, err)
+				continue
+			}
+
+			labels, err := nameLabels(record.DomainName)
+			var hash []byte
+			if err == nil && len(labels) > 0 {
+				hash, err = nsec3Encoding.DecodeString(strings.ToUpper(labels[0]))
+			}
+
+			if err != nil || len(labels) == 0 || len(labels) != len(v.z.labels)+1 || !isSubdomain(labels, v.z.labels) {
+				v.report(record.Position, Severity_Error, DNSSECRule_BrokenChain, "NSEC3 record owner %s is not a hash directly below the zone %s", record.DomainName, v.z.origin)
+				continue
+			}
+
+			if param == nil {
+				// without an NSEC3PARAM record, the first NSEC3 record sets the parameters
+				param = &NSEC3PARAM{HashAlgorithm: nsec3.HashAlgorithm, Iterations: nsec3.Iterations, Salt: nsec3.Salt}
+				v.report(v.soa.Position, Severity_Error, DNSSECRule_BrokenChain, "Zone %s has NSEC3 records, but no NSEC3PARAM record", v.z.origin)
+			}
+
+			if nsec3.HashAlgorithm != param.HashAlgorithm || nsec3.Iterations != param.Iterations || !bytes.Equal(nsec3.Salt, param.Salt) {
+				v.report(record.Position, Severity_Error, DNSSECRule_BrokenChain, "NSEC3 record for %s has different hash parameters from the NSEC3PARAM record", record.DomainName)
+				continue
+			}
+
+			links = append(links, &nsec3Link{record: record, nsec3: nsec3, hash: hash})
+		}
+	}
+
+	if param == nil {
+		v.report(v.soa.Position, Severity_Error, DNSSECRule_BrokenChain, "Zone %s has an NSEC3PARAM record, but no NSEC3 records", v.z.origin)
+		return
+	}
+
+	if param.HashAlgorithm != nsec3HashSHA1 {
+		v.report(v.soa.Position, Severity_Error, DNSSECRule_BrokenChain, "Zone %s uses unknown NSEC3 hash algorithm %d", v.z.origin, param.HashAlgorithm)
+		return
+	}
+
+	sort.Slice(links, func(i, j int) bool { return bytes.Compare(links[i].hash, links[j].hash) < 0 })
+
+	optOut := false
+	byHash := make(map[string]*nsec3Link)
+	for i, link := range links {
+		optOut = optOut || link.nsec3.Flags&NSEC3Flag_OptOut != 0
+		byHash[string(link.hash)] = link
+
+		next := links[(i+1)%len(links)]
+		if !bytes.Equal(link.nsec3.NextHashedOwner, next.hash) {
+			v.report(link.record.Position, Severity_Error, DNSSECRule_BrokenChain, "NSEC3 record for %s points to %s, expected %s",
+				link.record.DomainName, nsec3Encoding.EncodeToString(link.nsec3.NextHashedOwner), nsec3Encoding.EncodeToString(next.hash))
+		}
+	}
+
+	for _, node := range v.z.chainNodes(true) {
+		name := joinLabels(node.labels)
+		link := byHash[string(nsec3Hash(node.labels, param.Salt, param.Iterations))]
+		if link == nil {
+			if !optOut || !v.mayOptOut(node) {
+				v.report(v.position(node), Severity_Error, DNSSECRule_BrokenChain, "%s has no NSEC3 record", name)
+			}
+			continue
+		}
+
+		link.matched = true
+		v.checkTypes(link.record.Position, RecordType_NSEC3, name, link.nsec3.Types, node)
+	}
+
+	for _, link := range links {
+		if !link.matched {
+			v.report(link.record.Position, Severity_Warning, DNSSECRule_Orphan, "NSEC3 record for %s matches no authoritative name of the zone", link.record.DomainName)
+		}
+	}
+}
+
+// mayOptOut reports whether an opt-out chain may leave a name out: it is an
+// insecure delegation, or an empty non-terminal above only insecure
+// delegations (RFC 5155 section 7.1)
+func (v *validator) mayOptOut(node *zoneNode) bool {
+	if len(node.rrsets) != 0 {
+		return v.z.status(node.labels) == nameStatus_Delegation && !node.hasType(RecordType_DS)
+	}
+
+	for _, other := range v.z.nodes {
+		if len(other.labels) <= len(node.labels) || !isSubdomain(other.labels, node.labels) || len(other.rrsets) == 0 {
+			continue
+		}
+
+		switch v.z.status(other.labels) {
+		case nameStatus_Authoritative:
+			return false
+		case nameStatus_Delegation:
+			if other.hasType(RecordType_DS) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (v *validator) checkDS(records []Record) {
+	matched, secure := false, false
+	for _, record := range records {
+		ds, err := rdataAs[*DS](record)
+		if err != nil {
+			v.report(record.Position, Severity_Error, DNSSECRule_DSMismatch, "Record for %s is not a valid DS record: %s", record.DomainName, err)
+			continue
+		}
+
+		found := false
+		for _, key := range v.keys {
+			if key.Algorithm != ds.Algorithm || key.KeyTag() != ds.KeyTag {
+				continue
+			}
+
+			digest, err := NewDS(v.z.origin, key, ds.DigestType)
+			if err == nil && bytes.Equal(digest.Digest, ds.Digest) {
+				found = true
+				secure = secure || v.signsKeys[key]
+			}
+		}
+
+		if !found {
+			v.report(record.Position, Severity_Error, DNSSECRule_DSMismatch, "DS record %d %d %d matches no DNSKEY of the zone %s", ds.KeyTag, ds.Algorithm, ds.DigestType, v.z.origin)
+		}
+		matched = matched || found
+	}
+
+	if matched && !secure {
+		v.report(v.dnskeys.Records[0].Position, Severity_Error, DNSSECRule_DSMismatch, "No key matching a DS record has a valid signature over the DNSKEY RRset")
+	}
+}
@@ -0,0 +1,256 @@
+package gozone
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var validateOptions = ValidateOptions{Now: time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC)}
+
+func signedRecords(t *testing.T, options SignOptions) ([]Record, *SigningKey) {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	key := newSigningKey(t, DNSKEYFlag_ZoneKey|DNSKEYFlag_SEP, private, err)
+
+	z := loadSigningZone(t)
+	if err = SignZone(z, []*SigningKey{key}, options); err != nil {
+		t.Fatalf("Failed to sign zone: %s", err)
+	}
+
+	var records []Record
+	for _, rrset := range z.RRsets() {
+		records = append(records, rrset.Records...)
+	}
+
+	return records, key
+}
+
+// validateRecords writes records as a zone file, and validates it
+func validateRecords(t *testing.T, records []Record, options ValidateOptions) []Diagnostic {
+	t.Helper()
+
+	var out strings.Builder
+	writeAllRecords(t, NewWriter(&out), records)
+
+	diagnostics, err := ValidateZone(NewScanner(strings.NewReader(out.String())), options)
+	if err != nil {
+		t.Fatalf("Failed to load signed zone: %s", err)
+	}
+
+	return diagnostics
+}
+
+func diagnosticRules(diagnostics []Diagnostic) string {
+	seen := make(map[string]bool)
+	var rules []string
+	for _, d := range diagnostics {
+		if !seen[d.Rule] {
+			seen[d.Rule] = true
+			rules = append(rules, d.Rule)
+		}
+	}
+	sort.Strings(rules)
+
+	return strings.Join(rules, " ")
+}
+
+func TestValidateSignedZone(t *testing.T) {
+	for _, nsec3 := range []*NSEC3Options{nil, {Iterations: 0}, {Iterations: 2, Salt: []byte{1, 2}, OptOut: true}} {
+		options := signOptions
+		options.NSEC3 = nsec3
+
+		records, key := signedRecords(t, options)
+		ds, err := NewDS("example.com.", &key.DNSKEY, DSDigest_SHA256)
+		if err != nil {
+			t.Fatalf("Failed to create DS record: %s", err)
+		}
+
+		validate := validateOptions
+		validate.DS = []Record{{DomainName: "example.com.", Class: RecordClass_IN, Type: RecordType_DS, Data: presentationFields(ds.String())}}
+		if diagnostics := validateRecords(t, records, validate); len(diagnostics) != 0 {
+			t.Fatalf("Validating a freshly signed zone (NSEC3 %v) found %v", nsec3, diagnostics)
+		}
+	}
+}
+
+func TestValidateGenericForm(t *testing.T) {
+	records, _ := signedRecords(t, signOptions)
+	for i, record := range records {
+		if record.Type == RecordType_DNSKEY {
+			data, err := record.CanonicalRData()
+			if err != nil {
+				t.Fatalf("Failed to write DNSKEY record data: %s", err)
+			}
+			records[i].Data = []string{`\#`, strconv.Itoa(len(data)), fmt.Sprintf("%X", data)}
+		}
+	}
+
+	if diagnostics := validateRecords(t, records, validateOptions); len(diagnostics) != 0 {
+		t.Fatalf("Validating a zone with its DNSKEY record in the generic form found %v", diagnostics)
+	}
+
+	z := loadSigningZone(t)
+	if err := z.Add(Record{DomainName: "example.com.", TimeToLive: 3600, Class: RecordClass_IN, Type: RecordType_DNSKEY, Data: []string{`\#`, "2", "0101"}}); err != nil {
+		t.Fatalf("Failed to add record: %s", err)
+	}
+
+	diagnostics := Validate(z, validateOptions)
+	if len(diagnostics) == 0 || diagnostics[0].Rule != DNSSECRule_NoDNSKEY || !strings.Contains(diagnostics[0].Message, "malformed") {
+		t.Fatalf("Validating a zone with a malformed generic DNSKEY record found %v", diagnostics)
+	}
+}
+
+func TestValidateSignatureTimes(t *testing.T) {
+	records, _ := signedRecords(t, signOptions)
+
+	times := []struct {
+		now   time.Time
+		rules string
+	}{
+		{time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC), DNSSECRule_FutureSignature},
+		{time.Date(2024, time.April, 2, 0, 0, 0, 0, time.UTC), DNSSECRule_ExpiredSignature},
+	}
+
+	for _, tm := range times {
+		if rules := diagnosticRules(validateRecords(t, records, ValidateOptions{Now: tm.now})); rules != tm.rules {
+			t.Fatalf("Validating at %s found [%s], expected [%s]", tm.now, rules, tm.rules)
+		}
+	}
+}
+
+func TestValidateProblems(t *testing.T) {
+	problems := []struct {
+		name   string
+		change func(records []Record) []Record
+		rules  string
+	}{
+		{"changed data", func(records []Record) []Record {
+			for i, r := range records {
+				if r.DomainName == "www.example.com." && r.Type == RecordType_A {
+					records[i].Data = []string{"192.0.2.9"}
+					break
+				}
+			}
+			return records
+		}, DNSSECRule_BadSignature},
+		{"unsigned name", func(records []Record) []Record {
+			return append(records, Record{DomainName: "new.example.com.", TimeToLive: 3600, Class: RecordClass_IN, Type: RecordType_A, Data: []string{"192.0.2.5"}})
+		}, DNSSECRule_BrokenChain + " " + DNSSECRule_MissingSignature},
+		{"missing NSEC", func(records []Record) []Record {
+			var kept []Record
+			for _, r := range records {
+				if r.DomainName != "ns1.example.com." || r.Type != RecordType_NSEC {
+					kept = append(kept, r)
+				}
+			}
+			return kept
+		}, DNSSECRule_BrokenChain + " " + DNSSECRule_Orphan},
+		{"signed glue", func(records []Record) []Record {
+			for _, r := range records {
+				if r.DomainName == "ns1.example.com." && r.Type == RecordType_RRSIG {
+					r.DomainName = "ns.sub.example.com."
+					r.Data = append([]string{}, r.Data...)
+					r.Data[2] = "4"
+					return append(records, r)
+				}
+			}
+			return records
+		}, DNSSECRule_Orphan},
+		{"unknown key", func(records []Record) []Record {
+			var kept []Record
+			for _, r := range records {
+				if r.Type != RecordType_DNSKEY {
+					kept = append(kept, r)
+				}
+			}
+			return kept
+		}, DNSSECRule_BrokenChain + " " + DNSSECRule_NoDNSKEY + " " + DNSSECRule_Orphan + " " + DNSSECRule_UnknownKey},
+	}
+
+	for _, p := range problems {
+		records, _ := signedRecords(t, signOptions)
+		if rules := diagnosticRules(validateRecords(t, p.change(records), validateOptions)); rules != p.rules {
+			t.Fatalf("Validating a zone with %s found [%s], expected [%s]", p.name, rules, p.rules)
+		}
+	}
+}
+
+func TestValidateNSEC3Chain(t *testing.T) {
+	options := signOptions
+	options.NSEC3 = &NSEC3Options{Iterations: 1}
+	records, _ := signedRecords(t, options)
+
+	var kept []Record
+	removed := false
+	for _, r := range records {
+		if r.Type == RecordType_NSEC3 && !removed {
+			removed = true
+			continue
+		}
+		kept = append(kept, r)
+	}
+
+	diagnostics := validateRecords(t, kept, validateOptions)
+	if rules := diagnosticRules(diagnostics); rules != DNSSECRule_BrokenChain+" "+DNSSECRule_Orphan {
+		t.Fatalf("Validating an NSEC3 chain with a missing link found %v", diagnostics)
+	}
+}
+
+func TestValidateNSEC3AtRoot(t *testing.T) {
+	options := signOptions
+	options.NSEC3 = &NSEC3Options{Iterations: 1}
+	records, _ := signedRecords(t, options)
+
+	for _, r := range records {
+		if r.Type == RecordType_NSEC3 {
+			r.DomainName = "."
+			records = append(records, r)
+			break
+		}
+	}
+
+	diagnostics := validateRecords(t, records, validateOptions)
+	for _, d := range diagnostics {
+		if d.Rule == DNSSECRule_BrokenChain && strings.Contains(d.Message, "owner . ") {
+			return
+		}
+	}
+
+	t.Fatalf("Validating a zone with an NSEC3 record at the root found %v", diagnostics)
+}
+
+func TestValidateDSMismatch(t *testing.T) {
+	records, key := signedRecords(t, signOptions)
+	ds, _ := NewDS("example.com.", &key.DNSKEY, DSDigest_SHA256)
+	ds.Digest[0] ^= 0xff
+
+	options := validateOptions
+	options.DS = []Record{{DomainName: "example.com.", Class: RecordClass_IN, Type: RecordType_DS, Data: presentationFields(ds.String())}}
+	if rules := diagnosticRules(validateRecords(t, records, options)); rules != DNSSECRule_DSMismatch {
+		t.Fatalf("Validating against a wrong DS record found [%s], expected [%s]", rules, DNSSECRule_DSMismatch)
+	}
+}
+
+func TestNewDS(t *testing.T) {
+	// RFC 8080 section 6.1
+	key, err := Record{Type: RecordType_DNSKEY, Data: []string{"257", "3", "15", "l02Woi0iS8Aa25FQkUd9RMzZHJpBoRQwAQEX1SxZJA4="}}.RData()
+	if err != nil {
+		t.Fatalf("Failed to parse DNSKEY: %s", err)
+	}
+
+	ds, err := NewDS("example.com.", key.(*DNSKEY), DSDigest_SHA256)
+	if err != nil {
+		t.Fatalf("Failed to create DS: %s", err)
+	}
+
+	if expected := "3613 15 2 3AA5AB37EFCE57F737FC1627013FEE07BDF241BD10F3B1964AB55C78E79A304B"; ds.String() != expected {
+		t.Fatalf("DS was %s, expected %s", ds, expected)
+	}
+}
@@ -0,0 +1,296 @@
+package gozone
+
+// https://www.ietf.org/rfc/rfc1035.txt section 4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	MaxLabelLength = 63  // the longest label of a domain name, in bytes
+	MaxNameLength  = 255 // the longest domain name, in wire format
+)
+
+var ErrWireFormat = errors.New("cannot be written in wire format")
+
+type MessageHeader struct {
+	ID                 uint16
+	Response           bool
+	Opcode             uint8
+	Authoritative      bool
+	Truncated          bool
+	RecursionDesired   bool
+	RecursionAvailable bool
+	AuthenticData      bool
+	CheckingDisabled   bool
+	RCode              uint8
+}
+
+type Question struct {
+	DomainName string
+	Type       RecordType
+	Class      RecordClass
+}
+
+// Message is a DNS message, with the records of each section.
+type Message struct {
+	Header     MessageHeader
+	Questions  []Question
+	Answers    []Record
+	Authority  []Record
+	Additional []Record
+}
+
+// wireBuilder appends to a message, remembering where each name was written
+// so that later names can point to it
+type wireBuilder struct {
+	buf       []byte
+	names     map[string]int // offsets of written names, keyed by their lowercased wire form
+	lowercase bool           // write names in the lowercase canonical form of RFC 4034 section 6.2
+}
+
+// PackWire appends the record to buf in wire format, without compression.
+func (r Record) PackWire(buf []byte) ([]byte, error) {
+	b := &wireBuilder{buf: buf}
+	if err := b.packRecord(r); err != nil {
+		return buf, err
+	}
+
+	return b.buf, nil
+}
+
+// Pack writes the message in wire format, compressing domain names.
+func (m *Message) Pack() ([]byte, error) {
+	b := &wireBuilder{buf: make([]byte, 12, 512), names: make(map[string]int)}
+
+	h := m.Header
+	flags := uint16(h.Opcode&0xF)<<11 | uint16(h.RCode&0xF)
+	for _, bit := range []struct {
+		set   bool
+		value uint16
+	}{
+		{h.Response, 1 << 15},
+		{h.Authoritative, 1 << 10},
+		{h.Truncated, 1 << 9},
+		{h.RecursionDesired, 1 << 8},
+		{h.RecursionAvailable, 1 << 7},
+		{h.AuthenticData, 1 << 5},
+		{h.CheckingDisabled, 1 << 4},
+	} {
+		if bit.set {
+			flags |= bit.value
+		}
+	}
+
+	sections := [][]Record{m.Answers, m.Authority, m.Additional}
+	counts := []int{len(m.Questions), len(m.Answers), len(m.Authority), len(m.Additional)}
+	binary.BigEndian.PutUint16(b.buf[0:], h.ID)
+	binary.BigEndian.PutUint16(b.buf[2:], flags)
+	for i, count := range counts {
+		if count > math.MaxUint16 {
+			return nil, fmt.Errorf("Message has too many entries in one section: %d", count)
+		}
+		binary.BigEndian.PutUint16(b.buf[4+2*i:], uint16(count))
+	}
+
+	for _, q := range m.Questions {
+		if err := b.packName(q.DomainName); err != nil {
+			return nil, err
+		}
+		b.buf = binary.BigEndian.AppendUint16(b.buf, uint16(q.Type))
+		b.buf = binary.BigEndian.AppendUint16(b.buf, uint16(q.Class))
+	}
+
+	for _, section := range sections {
+		for _, r := range section {
+			if err := b.packRecord(r); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return b.buf, nil
+}
+
+func (b *wireBuilder) packRecord(r Record) error {
+	if r.Type == RecordType_UNKNOWN || r.Class == RecordClass_UNKNOWN {
+		return fmt.Errorf("Record for '%s' %w: it has no Type or Class", r.DomainName, ErrWireFormat)
+	}
+
+	if r.TimeToLive < 0 || r.TimeToLive > math.MaxUint32 {
+		return fmt.Errorf("Record for '%s' %w: invalid TimeToLive %d", r.DomainName, ErrWireFormat, r.TimeToLive)
+	}
+
+	if err := b.packName(r.DomainName); err != nil {
+		return err
+	}
+
+	b.buf = binary.BigEndian.AppendUint16(b.buf, uint16(r.Type))
+	b.buf = binary.BigEndian.AppendUint16(b.buf, uint16(r.Class))
+	b.buf = binary.BigEndian.AppendUint32(b.buf, uint32(r.TimeToLive))
+
+	lengthAt := len(b.buf)
+	b.buf = append(b.buf, 0, 0)
+	if err := b.packRecordData(r); err != nil {
+		return err
+	}
+
+	length := len(b.buf) - lengthAt - 2
+	if length > math.MaxUint16 {
+		return fmt.Errorf("Record for '%s' %w: data is %d bytes long", r.DomainName, ErrWireFormat, length)
+	}
+	binary.BigEndian.PutUint16(b.buf[lengthAt:], uint16(length))
+
+	return nil
+}
+
+// packRData writes typed record data. Names within the data of the RFC 1035
+// types are compressed; RFC 3597 forbids compression for any other type.
+func (b *wireBuilder) packRData(rdata RData) error {
+	switch rd := rdata.(type) {
+	case *A:
+		b.buf = append(b.buf, rd.Address.AsSlice()...)
+	case *AAAA:
+		b.buf = append(b.buf, rd.Address.AsSlice()...)
+	case *NS:
+		return b.packName(rd.Host)
+	case *CNAME:
+		return b.packName(rd.Target)
+	case *PTR:
+		return b.packName(rd.Target)
+	case *MX:
+		b.buf = binary.BigEndian.AppendUint16(b.buf, rd.Preference)
+		return b.packName(rd.Exchange)
+	case *SOA:
+		if err := b.packName(rd.MName); err != nil {
+			return err
+		}
+		if err := b.packName(rd.RName); err != nil {
+			return err
+		}
+		for _, value := range []uint32{rd.Serial, rd.Refresh, rd.Retry, rd.Expire, rd.Minimum} {
+			b.buf = binary.BigEndian.AppendUint32(b.buf, value)
+		}
+	case *TXT:
+		for _, s := range rd.Strings {
+			b.packCharacterString(s)
+		}
+	case *HINFO:
+		b.packCharacterString(rd.CPU)
+		b.packCharacterString(rd.OS)
+	case *MINFO:
+		if err := b.packName(rd.RMailbox); err != nil {
+			return err
+		}
+		return b.packName(rd.EMailbox)
+	case *WKS:
+		b.buf = append(b.buf, rd.Address.AsSlice()...)
+		b.buf = append(b.buf, rd.Protocol)
+		if len(rd.Ports) != 0 {
+			bitmap := make([]byte, rd.Ports[len(rd.Ports)-1]/8+1)
+			for _, port := range rd.Ports {
+				bitmap[port/8] |= 0x80 >> (port % 8)
+			}
+			b.buf = append(b.buf, bitmap...)
+		}
+	case *ZONEMD:
+		b.buf = binary.BigEndian.AppendUint32(b.buf, rd.Serial)
+		b.buf = append(b.buf, rd.Scheme, rd.HashAlgorithm)
+		b.buf = append(b.buf, rd.Digest...)
+	case *GenericRData:
+		b.buf = append(b.buf, rd.Data...)
+	default:
+		return b.packDNSSEC(rdata)
+	}
+
+	return nil
+}
+
+func (b *wireBuilder) packCharacterString(s string) {
+	b.buf = append(b.buf, byte(len(s)))
+	b.buf = append(b.buf, s...)
+}
+
+// packName writes an absolute domain name, pointing to an earlier copy of
+// its longest possible suffix when compressing
+func (b *wireBuilder) packName(name string) error {
+	labels, err := splitName(name)
+	if err != nil {
+		return err
+	}
+
+	wire := make([]byte, 0, len(name)+1)
+	for _, label := range labels {
+		wire = append(wire, byte(len(label)))
+		wire = append(wire, label...)
+	}
+	wire = append(wire, 0)
+
+	if b.lowercase {
+		wire = []byte(lowerASCII(wire))
+	}
+
+	if len(wire) > MaxNameLength {
+		return fmt.Errorf("Domain name '%s' %w: it is %d bytes long, the maximum is %d", name, ErrWireFormat, len(wire), MaxNameLength)
+	}
+
+	suffix := 0
+	for _, label := range labels {
+		key := lowerASCII(wire[suffix:])
+		if offset, ok := b.names[key]; ok {
+			b.buf = append(b.buf, wire[:suffix]...)
+			b.buf = binary.BigEndian.AppendUint16(b.buf, 0xC000|uint16(offset))
+			return nil
+		}
+
+		if b.names != nil && len(b.buf)+suffix < 0x4000 {
+			b.names[key] = len(b.buf) + suffix
+		}
+		suffix += 1 + len(label)
+	}
+
+	b.buf = append(b.buf, wire...)
+	return nil
+}
+
+// packUncompressedName writes an absolute domain name as written, for the
+// types whose names are neither compressed nor made canonical
+func (b *wireBuilder) packUncompressedName(name string) error {
+	names, lowercase := b.names, b.lowercase
+	b.names, b.lowercase = nil, false
+	defer func() { b.names, b.lowercase = names, lowercase }()
+
+	return b.packName(name)
+}
+
+// splitName splits an absolute domain name in presentation format into its
+// labels, resolving \X and \DDD escapes
+func splitName(name string) ([]string, error) {
+	labels, absolute, err := parseLabels(name)
+	if err != nil {
+		return nil, fmt.Errorf("Domain name '%s' %w: %w", name, ErrWireFormat, err)
+	}
+
+	if !absolute {
+		return nil, fmt.Errorf("Domain name '%s' %w: it is not absolute", name, ErrWireFormat)
+	}
+
+	return labels, nil
+}
+
+// lowerASCII lowercases only the ASCII letters of b, as domain names compare
+// case-insensitively only for those
+func lowerASCII(b []byte) string {
+	lower := make([]byte, len(b))
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		lower[i] = c
+	}
+
+	return string(lower)
+}
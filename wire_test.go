@@ -0,0 +1,122 @@
+package gozone
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPackWireRecord(t *testing.T) {
+	r := parseOneRecord(t, "a.com. 300 IN A 192.168.0.1")
+	packed, err := r.PackWire(nil)
+	if err != nil {
+		t.Fatalf("Failed to pack [%s]: %s", r, err)
+	}
+
+	expected := []byte{
+		1, 'a', 3, 'c', 'o', 'm', 0,
+		0, 1, 0, 1,
+		0, 0, 1, 44,
+		0, 4, 192, 168, 0, 1,
+	}
+	if !bytes.Equal(packed, expected) {
+		t.Fatalf("Packed [%s] as %v, expected %v", r, packed, expected)
+	}
+}
+
+func TestPackWireRData(t *testing.T) {
+	records := map[string][]byte{
+		"a.com. 300 IN MX 10 b.com.":                {0, 10, 1, 'b', 3, 'c', 'o', 'm', 0},
+		`a.com. 300 IN TXT "ab" "" "\065"`:          {2, 'a', 'b', 0, 1, 'A'},
+		`a.com. 300 IN HINFO x86 Linux`:             {3, 'x', '8', '6', 5, 'L', 'i', 'n', 'u', 'x'},
+		"a.com. 300 IN WKS 192.168.0.1 tcp 21 25":   {192, 168, 0, 1, 6, 0, 0, 0x04, 0x40},
+		`a.com. 300 IN TYPE731 \# 3 abcdef`:         {0xab, 0xcd, 0xef},
+		`a.com. 300 IN NS ns\.x.com.`:               {4, 'n', 's', '.', 'x', 3, 'c', 'o', 'm', 0},
+		"a.com. 300 IN SOA . . ( 1 2 3 4 5 )":       {0, 0, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 4, 0, 0, 0, 5},
+		"a.com. 300 IN AAAA 2001:db8::1":            {0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+		`a.com. 300 IN PTR \100\101\102.`:           {3, 'd', 'e', 'f', 0},
+		"a.com. 300 IN MINFO a.com. b.com.":         {1, 'a', 3, 'c', 'o', 'm', 0, 1, 'b', 3, 'c', 'o', 'm', 0},
+		"a.com. 300 IN CNAME a.com.":                {1, 'a', 3, 'c', 'o', 'm', 0},
+		"a.com. 300 IN A \\# 4 C0A80001":            {192, 168, 0, 1},
+		"a.com. 300 IN WKS 192.168.0.1 udp":         {192, 168, 0, 1, 17},
+		"a.com. 300 IN TXT x":                       {1, 'x'},
+		"a.com. 300 IN SOA a.com. a.com. 0 0 0 0 0": append(bytes.Repeat([]byte{1, 'a', 3, 'c', 'o', 'm', 0}, 2), make([]byte, 20)...),
+	}
+
+	for spec, expected := range records {
+		r := parseOneRecord(t, spec)
+		packed, err := r.PackWire(nil)
+		if err != nil {
+			t.Fatalf("Failed to pack [%s]: %s", spec, err)
+		}
+
+		// skip the owner, type, class, ttl and length
+		data := packed[len("\x01a\x03com\x00")+10:]
+		if !bytes.Equal(data, expected) {
+			t.Fatalf("Packed data of [%s] as %v, expected %v", spec, data, expected)
+		}
+	}
+}
+
+func TestMessagePackCompression(t *testing.T) {
+	m := &Message{
+		Header:    MessageHeader{ID: 0x1234, Response: true, Authoritative: true, RCode: 3},
+		Questions: []Question{{"www.a.com.", RecordType_A, RecordClass_IN}},
+		Answers: []Record{
+			parseOneRecord(t, "WWW.A.COM. 300 IN CNAME a.com."),
+			parseOneRecord(t, "a.com. 300 IN MX 10 mail.a.com."),
+		},
+	}
+
+	packed, err := m.Pack()
+	if err != nil {
+		t.Fatalf("Failed to pack message: %s", err)
+	}
+
+	expected := []byte{
+		0x12, 0x34, 0x84, 0x03, 0, 1, 0, 2, 0, 0, 0, 0,
+		// question at offset 12, "a.com." at 16
+		3, 'w', 'w', 'w', 1, 'a', 3, 'c', 'o', 'm', 0, 0, 1, 0, 1,
+		0xC0, 12, 0, 5, 0, 1, 0, 0, 1, 44, 0, 2, 0xC0, 16,
+		0xC0, 16, 0, 15, 0, 1, 0, 0, 1, 44, 0, 9, 0, 10, 4, 'm', 'a', 'i', 'l', 0xC0, 16,
+	}
+	if !bytes.Equal(packed, expected) {
+		t.Fatalf("Packed message as %v, expected %v", packed, expected)
+	}
+}
+
+func TestPackWireLimits(t *testing.T) {
+	long := strings.Repeat("a", 63)
+	if _, err := parseOneRecord(t, long+".com. 300 IN A 192.168.0.1").PackWire(nil); err != nil {
+		t.Fatalf("Failed to pack a record with a %d byte label: %s", len(long), err)
+	}
+
+	names := []string{
+		long + "a.com.",
+		strings.Repeat(long+".", 4) + "com.",
+		"a..com.",
+		"relative",
+		`a.com\.`,
+	}
+
+	for _, name := range names {
+		r := Record{name, 300, RecordClass_IN, RecordType_A, []string{"192.168.0.1"}, "", Position{}}
+		if _, err := r.PackWire(nil); !errors.Is(err, ErrWireFormat) {
+			t.Fatalf("Packing record for invalid name [%s] did not return ErrWireFormat: %v", name, err)
+		}
+	}
+
+	records := []Record{
+		{"a.com.", -1, RecordClass_IN, RecordType_A, []string{"192.168.0.1"}, "", Position{}},
+		{"a.com.", 300, RecordClass_UNKNOWN, RecordType_A, []string{"192.168.0.1"}, "", Position{}},
+		{"a.com.", 300, RecordClass_IN, RecordType_LOC, []string{"52", "22", "23.000", "N", "4", "53", "32.000", "E", "-2.00m"}, "", Position{}},
+		{"a.com.", 300, RecordClass_IN, RecordType_MX, []string{"10", "mail"}, "", Position{}},
+	}
+
+	for _, r := range records {
+		if _, err := r.PackWire(nil); !errors.Is(err, ErrWireFormat) {
+			t.Fatalf("Packing unpackable record [%s] did not return ErrWireFormat: %v", r, err)
+		}
+	}
+}
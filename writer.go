@@ -0,0 +1,249 @@
+package gozone
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Writer writes Records in zone file format, such that a Scanner reading the
+// output produces records with the same DomainName, TimeToLive, Class, Type
+// and (typed) data. Columns are aligned, so output must be flushed with Flush
+// once all records have been written.
+type Writer struct {
+	dst           *tabwriter.Writer
+	origin        string
+	timeToLive    int64
+	lastClass     RecordClass
+	ttlFormat     TTLFormat
+	relativeNames bool
+}
+
+var soaTimerNames = []string{"serial", "refresh", "retry", "expire", "minimum"}
+
+func NewWriter(dst io.Writer) *Writer {
+	return &Writer{
+		dst:           tabwriter.NewWriter(dst, 0, 8, 1, ' ', tabwriter.DiscardEmptyColumns),
+		timeToLive:    -1,
+		relativeNames: true,
+	}
+}
+
+// SetTTLFormat chooses how TimeToLive values are written. The default is
+// TTLFormat_Seconds.
+func (w *Writer) SetTTLFormat(format TTLFormat) {
+	w.ttlFormat = format
+}
+
+// SetRelativeNames chooses whether domain names within record data are also
+// written relative to the origin, as owner names are. The default is true. A
+// Scanner makes them absolute again when reading them back, unless it is told
+// to keep relative names.
+func (w *Writer) SetRelativeNames(relative bool) {
+	w.relativeNames = relative
+}
+
+// SetOrigin writes an $ORIGIN control entry. Domain names of the following
+// records are written relative to origin where possible.
+func (w *Writer) SetOrigin(origin string) error {
+	if !strings.HasSuffix(origin, ".") {
+		return fmt.Errorf("Origin '%s' is not an absolute domain name", origin)
+	}
+
+	if _, err := fmt.Fprintf(w.dst, "$ORIGIN %s\n", escapeField(origin)); err != nil {
+		return err
+	}
+
+	w.origin = origin
+	return nil
+}
+
+// SetTimeToLive writes a $TTL control entry. The TimeToLive of the following
+// records is left out where it matches ttl.
+func (w *Writer) SetTimeToLive(ttl uint32) error {
+	if _, err := fmt.Fprintf(w.dst, "$TTL %s\n", FormatTTL(ttl, w.ttlFormat)); err != nil {
+		return err
+	}
+
+	w.timeToLive = int64(ttl)
+	return nil
+}
+
+func (w *Writer) Write(record Record) error {
+	if !strings.HasSuffix(record.DomainName, ".") {
+		return fmt.Errorf("DomainName '%s' is not an absolute domain name", record.DomainName)
+	}
+
+	if record.Type == RecordType_UNKNOWN {
+		return fmt.Errorf("Record for '%s' has no Type", record.DomainName)
+	}
+
+	var ttl string
+	switch {
+	case record.TimeToLive == w.timeToLive:
+	case record.TimeToLive < 0 || record.TimeToLive > int64(^uint32(0)):
+		if record.TimeToLive == -1 {
+			return fmt.Errorf("Record for '%s' has no TimeToLive, but $TTL is set", record.DomainName)
+		}
+		return fmt.Errorf("Record for '%s' has invalid TimeToLive %d", record.DomainName, record.TimeToLive)
+	default:
+		ttl = FormatTTL(uint32(record.TimeToLive), w.ttlFormat)
+	}
+
+	var class string
+	if record.Class != RecordClass_UNKNOWN {
+		class = record.Class.String()
+	} else if w.lastClass != RecordClass_UNKNOWN {
+		return fmt.Errorf("Record for '%s' has no Class, but follows a record with one", record.DomainName)
+	}
+
+	data, err := w.formatData(record)
+	if err != nil {
+		return err
+	}
+
+	owner := w.relativize(record.DomainName)
+	switch {
+	case owner == "@":
+	case owner[0] == '$':
+		// a leading $ would start a control entry; only a bare @ is
+		// the origin, so no other owner needs escaping
+		owner = "\\" + escapeField(owner)
+	default:
+		owner = escapeField(owner)
+	}
+
+	var comment string
+	if record.Comment != "" {
+		comment = strings.Map(func(r rune) rune {
+			if r < ' ' {
+				return ' '
+			}
+			return r
+		}, record.Comment)
+
+		if comment[0] != ';' {
+			comment = "; " + comment
+		}
+	}
+
+	var out strings.Builder
+	_, _ = fmt.Fprintf(&out, "%s\t%s\t%s\t%s\t", owner, ttl, class, record.Type)
+	if record.Type == RecordType_SOA && len(data) == 2+len(soaTimerNames) {
+		_, _ = fmt.Fprintf(&out, "%s %s (\n", data[0], data[1])
+		for i, name := range soaTimerNames {
+			_, _ = fmt.Fprintf(&out, "\t\t\t\t%s ; %s\n", data[2+i], name)
+		}
+		_, _ = out.WriteString("\t\t\t\t)")
+	} else {
+		_, _ = out.WriteString(strings.Join(data, " "))
+	}
+
+	if comment != "" {
+		_, _ = out.WriteString(" " + comment)
+	}
+	_ = out.WriteByte('\n')
+
+	if _, err = io.WriteString(w.dst, out.String()); err != nil {
+		return err
+	}
+
+	w.lastClass = record.Class
+	return nil
+}
+
+// Flush writes out any records held back to align their columns.
+func (w *Writer) Flush() error {
+	return w.dst.Flush()
+}
+
+// formatData returns the fields of the record's data, quoted and escaped
+// such that they are read back as the same fields
+func (w *Writer) formatData(record Record) ([]string, error) {
+	fields := stripParens(record.Data)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("Record for '%s' has no Data", record.DomainName)
+	}
+
+	if isGenericData(fields) {
+		if _, err := decodeGenericData(fields); err != nil {
+			return nil, err
+		}
+		return fields, nil
+	}
+
+	data := make([]string, len(fields))
+	for i, field := range fields {
+		switch {
+		case rdataStringTypes[record.Type]:
+			decoded, err := parseCharacterString(field)
+			if err != nil {
+				return nil, err
+			}
+			data[i] = quoteCharacterString(decoded)
+		case field[0] == '"':
+			data[i] = field
+		default:
+			data[i] = escapeField(field)
+		}
+	}
+
+	if w.relativeNames {
+		for _, i := range rdataDomainFields[record.Type] {
+			if i < len(data) {
+				data[i] = w.relativize(data[i])
+			}
+		}
+	}
+
+	return data, nil
+}
+
+// relativize writes name relative to the origin, when it is within it
+func (w *Writer) relativize(name string) string {
+	if w.origin == "" || w.origin == "." {
+		return name
+	}
+
+	if name == w.origin {
+		return "@"
+	}
+
+	prefix, found := strings.CutSuffix(name, "."+w.origin)
+	if !found || prefix == "" || prefix == "@" {
+		return name
+	}
+
+	// the dot must separate labels, rather than be escaped
+	escapes := len(prefix) - len(strings.TrimRight(prefix, "\\"))
+	if escapes%2 != 0 {
+		return name
+	}
+
+	return prefix
+}
+
+// escapeField escapes the characters of field which would otherwise end it,
+// leaving existing escapes as they are
+func escapeField(field string) string {
+	var out strings.Builder
+	for i := 0; i < len(field); i++ {
+		c := field[i]
+		switch {
+		case c == '\\' && i+1 < len(field):
+			_ = out.WriteByte(c)
+			_ = out.WriteByte(field[i+1])
+			i++
+		case c == '\\' || c == ' ' || c == '"' || c == '(' || c == ')' || c == ';':
+			_ = out.WriteByte('\\')
+			_ = out.WriteByte(c)
+		case c < ' ' || c == 0x7f:
+			_, _ = fmt.Fprintf(&out, "\\%03d", c)
+		default:
+			_ = out.WriteByte(c)
+		}
+	}
+
+	return out.String()
+}
@@ -0,0 +1,265 @@
+package gozone
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const writerZone = `$ORIGIN adomain.com.
+$TTL 1h
+@	IN	SOA	ns.adomain.com. hostmaster.adomain.com. ( 1271271271 3h 1h 1w 300 )
+	IN	NS	ns.adomain.com.
+	IN	NS	ns.ahostdomain.com.
+	IN	MX	10 smtp.adomain.com.
+www	300	IN	A	192.168.0.1 ; web
+	300	IN	A	192.168.0.2
+txt	IN	TXT	"a \"quoted\" ; string" plain "\009tab"
+generic	IN	TYPE731	\# 3 abcdef
+srv	IN	SRV	10 5 5060 sip.adomain.com.
+escaped\ name.adomain.com.	IN	A	192.168.0.3
+other.ahostdomain.com.	IN	A	192.168.0.4
+`
+
+func readAllRecords(t *testing.T, zone string) []Record {
+	t.Helper()
+
+	var records []Record
+	var r Record
+	s := NewScanner(strings.NewReader(zone))
+	for {
+		err := s.Next(&r)
+		if err == io.EOF {
+			return records
+		}
+
+		if err != nil {
+			t.Fatalf("Failed to parse [%s]: %s", zone, err)
+		}
+		records = append(records, r)
+	}
+}
+
+// sameRecord compares records by meaning, rather than by their spelling
+func sameRecord(a, b Record) bool {
+	if a.DomainName != b.DomainName || a.TimeToLive != b.TimeToLive ||
+		a.Class != b.Class || a.Type != b.Type || a.Comment != b.Comment {
+		return false
+	}
+
+	aData, aErr := a.RData()
+	bData, bErr := b.RData()
+	if aErr != nil || bErr != nil {
+		return reflect.DeepEqual(stripParens(a.Data), stripParens(b.Data))
+	}
+
+	return reflect.DeepEqual(aData, bData)
+}
+
+func writeAllRecords(t *testing.T, w *Writer, records []Record) {
+	t.Helper()
+
+	for _, r := range records {
+		if err := w.Write(r); err != nil {
+			t.Fatalf("Failed to write record [%s]: %s", r, err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Failed to flush Writer: %s", err)
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	records := readAllRecords(t, writerZone)
+
+	for _, format := range []TTLFormat{TTLFormat_Seconds, TTLFormat_Units} {
+		var out strings.Builder
+		w := NewWriter(&out)
+		w.SetTTLFormat(format)
+		if err := w.SetOrigin("adomain.com."); err != nil {
+			t.Fatalf("Failed to set Writer origin: %s", err)
+		}
+		if err := w.SetTimeToLive(3600); err != nil {
+			t.Fatalf("Failed to set Writer TimeToLive: %s", err)
+		}
+		writeAllRecords(t, w, records)
+
+		reread := readAllRecords(t, out.String())
+		if len(reread) != len(records) {
+			t.Fatalf("Writer output [%s] has %d records, expected %d", out.String(), len(reread), len(records))
+		}
+
+		for i := range records {
+			if !sameRecord(records[i], reread[i]) {
+				t.Fatalf("Writer output [%s] record %d read back as [%s], expected [%s]", out.String(), i, reread[i], records[i])
+			}
+		}
+	}
+}
+
+func TestWriterRoundTripWithoutHeaders(t *testing.T) {
+	records := readAllRecords(t, writerZone)
+
+	var out strings.Builder
+	writeAllRecords(t, NewWriter(&out), records)
+
+	reread := readAllRecords(t, out.String())
+	for i := range records {
+		if !sameRecord(records[i], reread[i]) {
+			t.Fatalf("Writer output [%s] record %d read back as [%s], expected [%s]", out.String(), i, reread[i], records[i])
+		}
+	}
+}
+
+func TestWriterOutput(t *testing.T) {
+	records := readAllRecords(t, `$ORIGIN adomain.com.
+@ 3600 IN SOA ns.adomain.com. hostmaster.adomain.com. 1 10800 3600 604800 300
+www 300 IN A 192.168.0.1
+mail.adomain.com. 3600 IN MX 10 smtp.ahostdomain.com. ; mail
+`)
+
+	var out strings.Builder
+	w := NewWriter(&out)
+	if err := w.SetOrigin("adomain.com."); err != nil {
+		t.Fatalf("Failed to set Writer origin: %s", err)
+	}
+	if err := w.SetTimeToLive(3600); err != nil {
+		t.Fatalf("Failed to set Writer TimeToLive: %s", err)
+	}
+	writeAllRecords(t, w, records)
+
+	expected := `$ORIGIN adomain.com.
+$TTL 3600
+@        IN SOA ns hostmaster (
+                1 ; serial
+                10800 ; refresh
+                3600 ; retry
+                604800 ; expire
+                300 ; minimum
+                )
+www  300 IN A   192.168.0.1
+mail     IN MX  10 smtp.ahostdomain.com. ; mail
+`
+	if out.String() != expected {
+		t.Fatalf("Writer output [%s] not equal to expected [%s]", out.String(), expected)
+	}
+}
+
+func TestWriterRelativeNames(t *testing.T) {
+	records := readAllRecords(t, "adomain.com. 300 IN MX 10 smtp.adomain.com.\nadomain.com. 300 IN NS adomain.com.\n")
+
+	outputs := map[bool]string{
+		true:  "$ORIGIN adomain.com.\n@ 300 IN MX 10 smtp\n@ 300 IN NS @\n",
+		false: "$ORIGIN adomain.com.\n@ 300 IN MX 10 smtp.adomain.com.\n@ 300 IN NS adomain.com.\n",
+	}
+
+	for relative, expected := range outputs {
+		var out strings.Builder
+		w := NewWriter(&out)
+		if !relative {
+			w.SetRelativeNames(false)
+		}
+		if err := w.SetOrigin("adomain.com."); err != nil {
+			t.Fatalf("Failed to set Writer origin: %s", err)
+		}
+		writeAllRecords(t, w, records)
+
+		if out.String() != expected {
+			t.Fatalf("Writer output [%s] not equal to expected [%s]", out.String(), expected)
+		}
+
+		reread := readAllRecords(t, out.String())
+		for i, r := range reread {
+			r.Position = records[i].Position
+			if !reflect.DeepEqual(r, records[i]) {
+				t.Fatalf("Writer output [%s] read back as %v, expected %v", out.String(), r, records[i])
+			}
+		}
+	}
+}
+
+func TestWriterEscapesFields(t *testing.T) {
+	records := []Record{
+		{"$weird.adomain.com.", 300, RecordClass_IN, RecordType_TXT, []string{"two words", `"quoted"`}, "", Position{}},
+		{"@.adomain.com.", 300, RecordClass_IN, RecordType_CNAME, []string{"semi;colon.adomain.com."}, "", Position{}},
+	}
+
+	var out strings.Builder
+	w := NewWriter(&out)
+	if err := w.SetOrigin("adomain.com."); err != nil {
+		t.Fatalf("Failed to set Writer origin: %s", err)
+	}
+	writeAllRecords(t, w, records)
+
+	reread := readAllRecords(t, out.String())
+	if len(reread) != 2 {
+		t.Fatalf("Writer output [%s] has %d records, expected 2", out.String(), len(reread))
+	}
+
+	if reread[0].DomainName != `\$weird.adomain.com.` || reread[1].DomainName != "@.adomain.com." {
+		t.Fatalf("Writer output [%s] did not escape leading special characters of owners", out.String())
+	}
+
+	rdata, err := reread[0].RData()
+	if err != nil || !reflect.DeepEqual(rdata, &TXT{[]string{"two words", "quoted"}}) {
+		t.Fatalf("Writer output [%s] did not quote character-strings: %v", out.String(), rdata)
+	}
+
+	if reread[1].Data[0] != `semi\;colon.adomain.com.` {
+		t.Fatalf("Writer output [%s] did not escape special characters of names", out.String())
+	}
+}
+
+func TestWriterOwnerStartingWithAt(t *testing.T) {
+	records := []Record{
+		{"@x.adomain.com.", 300, RecordClass_IN, RecordType_A, []string{"192.168.0.1"}, "", Position{}},
+		{"adomain.com.", 300, RecordClass_IN, RecordType_A, []string{"192.168.0.2"}, "", Position{}},
+	}
+
+	for _, relative := range []bool{false, true} {
+		var out strings.Builder
+		w := NewWriter(&out)
+		w.SetRelativeNames(relative)
+		if err := w.SetOrigin("adomain.com."); err != nil {
+			t.Fatalf("Failed to set Writer origin: %s", err)
+		}
+		writeAllRecords(t, w, records)
+
+		reread := readAllRecords(t, out.String())
+		if len(reread) != 2 || reread[0].DomainName != "@x.adomain.com." || reread[1].DomainName != "adomain.com." {
+			t.Fatalf("Writer output [%s] read back as %v", out.String(), reread)
+		}
+	}
+}
+
+func TestWriterRejectsUnwritableRecords(t *testing.T) {
+	records := []Record{
+		{"relative", 300, RecordClass_IN, RecordType_A, []string{"192.168.0.1"}, "", Position{}},
+		{"adomain.com.", -1, RecordClass_IN, RecordType_A, []string{"192.168.0.1"}, "", Position{}},
+		{"adomain.com.", 300, RecordClass_IN, RecordType_UNKNOWN, []string{"192.168.0.1"}, "", Position{}},
+		{"adomain.com.", 300, RecordClass_IN, RecordType_A, []string{}, "", Position{}},
+		{"adomain.com.", 300, RecordClass_IN, RecordType_TXT, []string{`"\999"`}, "", Position{}},
+	}
+
+	for _, r := range records {
+		w := NewWriter(io.Discard)
+		if err := w.SetTimeToLive(3600); err != nil {
+			t.Fatalf("Failed to set Writer TimeToLive: %s", err)
+		}
+
+		if err := w.Write(r); err == nil {
+			t.Fatalf("Writing unwritable record [%s] did not return an error", r)
+		}
+	}
+
+	w := NewWriter(io.Discard)
+	if err := w.Write(Record{"a.com.", 300, RecordClass_IN, RecordType_A, []string{"192.168.0.1"}, "", Position{}}); err != nil {
+		t.Fatalf("Failed to write record: %s", err)
+	}
+
+	if err := w.Write(Record{"b.com.", 300, RecordClass_UNKNOWN, RecordType_A, []string{"192.168.0.1"}, "", Position{}}); err == nil {
+		t.Fatalf("Writing a record without a Class after one with a Class did not return an error")
+	}
+}
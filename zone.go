@@ -0,0 +1,445 @@
+package gozone
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxLookupChain limits how many CNAME and DNAME records a lookup follows
+const maxLookupChain = 16
+
+// RRset is every record of a zone with the same owner, class and type.
+type RRset struct {
+	DomainName string
+	Class      RecordClass
+	Type       RecordType
+	Records    []Record
+}
+
+// Zone holds the records of a zone, grouped into RRsets.
+type Zone struct {
+	origin string
+	labels []string
+	rrsets []*RRset
+	nodes  map[string]*zoneNode // keyed by canonical name, including empty non-terminals
+}
+
+type zoneNode struct {
+	labels []string
+	rrsets []*RRset
+}
+
+type LookupKind int
+
+const (
+	LookupKind_Answer           LookupKind = iota // the answer holds the requested data
+	LookupKind_NoData                             // the name exists, but has no data of the requested type
+	LookupKind_NXDomain                           // the name does not exist
+	LookupKind_Delegation                         // the name is below a zone cut, see the authority section
+	LookupKind_NotAuthoritative                   // the name is not within the zone
+	LookupKind_Alias                              // the answer ends in an alias to a name outside the zone
+	LookupKind_AliasLoop                          // the answer ends after maxLookupChain aliases, which may loop
+)
+
+func (k LookupKind) String() string {
+	switch k {
+	case LookupKind_Answer:
+		return "Answer"
+	case LookupKind_NoData:
+		return "NoData"
+	case LookupKind_NXDomain:
+		return "NXDomain"
+	case LookupKind_Delegation:
+		return "Delegation"
+	case LookupKind_NotAuthoritative:
+		return "NotAuthoritative"
+	case LookupKind_Alias:
+		return "Alias"
+	case LookupKind_AliasLoop:
+		return "AliasLoop"
+	}
+
+	return fmt.Sprintf("LookupKind(%d)", int(k))
+}
+
+// LookupResult is an authoritative answer, as a nameserver for the zone
+// would give it.
+type LookupResult struct {
+	Kind       LookupKind
+	Answer     []Record // the answer, including any CNAME and DNAME records followed
+	Authority  []Record // the NS records of a delegation, or the SOA record of a negative answer
+	Additional []Record // glue addresses for a delegation
+}
+
+// NewZone creates an empty zone for the absolute domain name origin.
+func NewZone(origin string) (*Zone, error) {
+	labels, err := nameLabels(origin)
+	if err != nil {
+		return nil, err
+	}
+
+	z := &Zone{origin: origin, labels: labels, nodes: make(map[string]*zoneNode)}
+	z.nodes[labelsKey(labels)] = &zoneNode{labels: labels}
+	return z, nil
+}
+
+// LoadZone reads every record from s into a new Zone. The origin of the zone
+// is the owner of the first SOA record.
+func LoadZone(s *Scanner) (*Zone, error) {
+	var records []Record
+	var z *Zone
+	for {
+		var record Record
+		err := s.Next(&record)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if z == nil && record.Type == RecordType_SOA {
+			if z, err = NewZone(record.DomainName); err != nil {
+				return nil, err
+			}
+		}
+		records = append(records, record)
+	}
+
+	if z == nil {
+		return nil, fmt.Errorf("Zone has no SOA record")
+	}
+
+	for _, record := range records {
+		if err := z.Add(record); err != nil {
+			return nil, err
+		}
+	}
+
+	return z, nil
+}
+
+func (z *Zone) Origin() string {
+	return z.origin
+}
+
+// Add adds record to the RRset with the same owner, class and type.
+func (z *Zone) Add(record Record) error {
+	labels, err := nameLabels(record.DomainName)
+	if err != nil {
+		return err
+	}
+
+	node := z.nodes[labelsKey(labels)]
+	if node == nil {
+		node = &zoneNode{labels: labels}
+		z.nodes[labelsKey(labels)] = node
+
+		// create the empty non-terminals between the record and the origin
+		if isSubdomain(labels, z.labels) {
+			for i := 1; i < len(labels)-len(z.labels); i++ {
+				key := labelsKey(labels[i:])
+				if z.nodes[key] == nil {
+					z.nodes[key] = &zoneNode{labels: labels[i:]}
+				}
+			}
+		}
+	}
+
+	for _, rrset := range node.rrsets {
+		if rrset.Class == record.Class && rrset.Type == record.Type {
+			rrset.Records = append(rrset.Records, record)
+			return nil
+		}
+	}
+
+	rrset := &RRset{DomainName: record.DomainName, Class: record.Class, Type: record.Type, Records: []Record{record}}
+	node.rrsets = append(node.rrsets, rrset)
+	z.rrsets = append(z.rrsets, rrset)
+	return nil
+}
+
+// clone returns a copy of the zone, which can be added to without changing z
+func (z *Zone) clone() *Zone {
+	c := &Zone{origin: z.origin, labels: z.labels, nodes: make(map[string]*zoneNode)}
+	c.nodes[labelsKey(c.labels)] = &zoneNode{labels: c.labels}
+	for _, rrset := range z.rrsets {
+		for _, record := range rrset.Records {
+			// the records were already accepted by z
+			_ = c.Add(record)
+		}
+	}
+
+	return c
+}
+
+// RRsets returns every RRset of the zone, in the order each was first added.
+func (z *Zone) RRsets() []*RRset {
+	return z.rrsets
+}
+
+// RRset returns the RRset with the given owner, class and type, or nil.
+func (z *Zone) RRset(name string, class RecordClass, rtype RecordType) *RRset {
+	labels, err := nameLabels(name)
+	if err != nil {
+		return nil
+	}
+
+	return z.nodes[labelsKey(labels)].rrset(class, rtype)
+}
+
+func (n *zoneNode) rrset(class RecordClass, rtype RecordType) *RRset {
+	if n == nil {
+		return nil
+	}
+
+	for _, rrset := range n.rrsets {
+		if rrset.Class == class && rrset.Type == rtype {
+			return rrset
+		}
+	}
+
+	return nil
+}
+
+// Lookup answers q, following CNAME and DNAME records within the zone,
+// synthesizing answers from wildcards and stopping at delegations.
+func (z *Zone) Lookup(q Question) LookupResult {
+	var result LookupResult
+	name := q.DomainName
+
+	for chain := 0; ; chain++ {
+		labels, err := nameLabels(name)
+		switch {
+		case err != nil || !isSubdomain(labels, z.labels):
+			result.Kind = LookupKind_Alias
+			if chain == 0 {
+				result.Kind = LookupKind_NotAuthoritative
+			}
+			return result
+		case chain > maxLookupChain:
+			result.Kind = LookupKind_AliasLoop
+			return result
+		}
+
+		next, done := z.lookupName(&result, name, labels, q)
+		if done {
+			return result
+		}
+		name = next
+	}
+}
+
+// lookupName adds the answer for name to result, returning the name to look
+// up next when the answer is an alias
+func (z *Zone) lookupName(result *LookupResult, name string, labels []string, q Question) (string, bool) {
+	// walk down from the origin, looking for zone cuts and DNAMEs
+	var node *zoneNode
+	for i := len(labels) - len(z.labels); i >= 0; i-- {
+		next := z.nodes[labelsKey(labels[i:])]
+		if next == nil {
+			break
+		}
+		node = next
+
+		isApex := i == len(labels)-len(z.labels)
+		if ns := node.rrset(q.Class, RecordType_NS); ns != nil && !isApex && !(i == 0 && q.Type == RecordType_DS) {
+			result.Kind = LookupKind_Delegation
+			result.Authority = append(result.Authority, ns.Records...)
+			result.Additional = append(result.Additional, z.glue(ns)...)
+			return "", true
+		}
+
+		if dname := node.rrset(q.Class, RecordType_DNAME); dname != nil && i != 0 {
+			target, ok := z.substituteDName(dname.Records[0], labels[:i])
+			if !ok {
+				result.Kind = LookupKind_NXDomain
+				return "", true
+			}
+
+			result.Answer = append(result.Answer, dname.Records[0])
+			result.Answer = append(result.Answer, Record{
+				DomainName: name,
+				TimeToLive: dname.Records[0].TimeToLive,
+				Class:      q.Class,
+				Type:       RecordType_CNAME,
+				Data:       []string{target},
+			})
+			if q.Type == RecordType_CNAME {
+				result.Kind = LookupKind_Answer
+				return "", true
+			}
+			return target, false
+		}
+
+		if i == 0 {
+			return z.answerFrom(result, node, name, q)
+		}
+	}
+
+	// the name does not exist, but a wildcard at its closest encloser may
+	wildcard := z.nodes[labelsKey(append([]string{"*"}, node.labels...))]
+	if wildcard != nil {
+		return z.answerFrom(result, wildcard, name, q)
+	}
+
+	result.Kind = LookupKind_NXDomain
+	result.Authority = z.soa(q.Class)
+	return "", true
+}
+
+// answerFrom answers q from the records of node, written with owner name
+func (z *Zone) answerFrom(result *LookupResult, node *zoneNode, name string, q Question) (string, bool) {
+	var answer []Record
+	for _, rrset := range node.rrsets {
+		if rrset.Class == q.Class && (rrset.Type == q.Type || q.Type == RecordType_all) {
+			answer = append(answer, rrset.Records...)
+		}
+	}
+
+	if len(answer) == 0 && q.Type != RecordType_CNAME {
+		if cname := node.rrset(q.Class, RecordType_CNAME); cname != nil {
+			record := cname.Records[0]
+			record.DomainName = name
+			result.Answer = append(result.Answer, record)
+
+			// a malformed CNAME record cannot be followed
+			rdata, err := record.RData()
+			if err != nil {
+				result.Kind = LookupKind_Answer
+				return "", true
+			}
+
+			target, ok := rdata.(*CNAME)
+			if !ok {
+				result.Kind = LookupKind_Answer
+				return "", true
+			}
+			return target.Target, false
+		}
+	}
+
+	if len(answer) == 0 {
+		result.Kind = LookupKind_NoData
+		result.Authority = z.soa(q.Class)
+		return "", true
+	}
+
+	for _, record := range answer {
+		record.DomainName = name
+		result.Answer = append(result.Answer, record)
+	}
+	result.Kind = LookupKind_Answer
+	return "", true
+}
+
+// substituteDName replaces the owner of a DNAME record at the end of a name
+// with its target, given the labels which come before the owner
+func (z *Zone) substituteDName(dname Record, prefix []string) (string, bool) {
+	fields := stripParens(dname.Data)
+	if len(fields) != 1 || isGenericData(fields) {
+		return "", false
+	}
+
+	target, err := nameLabels(fields[0])
+	if err != nil {
+		return "", false
+	}
+
+	name := joinLabels(append(append([]string{}, prefix...), target...))
+	if _, err = nameLabels(name); err != nil {
+		return "", false
+	}
+
+	return name, true
+}
+
+// glue returns the addresses held by the zone for the hosts of an NS RRset
+func (z *Zone) glue(ns *RRset) []Record {
+	var glue []Record
+	for _, record := range ns.Records {
+		rdata, err := record.RData()
+		if err != nil {
+			continue
+		}
+
+		host, ok := rdata.(*NS)
+		if !ok {
+			continue
+		}
+
+		labels, err := nameLabels(host.Host)
+		if err != nil || !isSubdomain(labels, z.labels) {
+			continue
+		}
+
+		node := z.nodes[labelsKey(labels)]
+		for _, rtype := range []RecordType{RecordType_A, RecordType_AAAA} {
+			if addresses := node.rrset(ns.Class, rtype); addresses != nil {
+				glue = append(glue, addresses.Records...)
+			}
+		}
+	}
+
+	return glue
+}
+
+func (z *Zone) soa(class RecordClass) []Record {
+	soa := z.nodes[labelsKey(z.labels)].rrset(class, RecordType_SOA)
+	if soa == nil {
+		return nil
+	}
+
+	return soa.Records[:1]
+}
+
+// apexSOA returns the SOA RRset at the top of the zone, in whichever class
+// it has, or nil
+func (z *Zone) apexSOA() *RRset {
+	apex := z.nodes[labelsKey(z.labels)]
+	for _, rrset := range z.rrsets {
+		if rrset.Type == RecordType_SOA && apex.rrset(rrset.Class, rrset.Type) == rrset {
+			return rrset
+		}
+	}
+
+	return nil
+}
+
+// nameLabels splits an absolute domain name into its decoded labels
+func nameLabels(name string) ([]string, error) {
+	return splitName(name)
+}
+
+// joinLabels writes decoded labels as an absolute domain name in
+// presentation format
+func joinLabels(labels []string) string {
+	if len(labels) == 0 {
+		return "."
+	}
+
+	var out strings.Builder
+	for _, label := range labels {
+		writeLabel(&out, []byte(label))
+		_ = out.WriteByte('.')
+	}
+
+	return out.String()
+}
+
+// labelsKey returns a key which is equal for names which compare equal
+func labelsKey(labels []string) string {
+	return lowerASCII([]byte(joinLabels(labels)))
+}
+
+// isSubdomain reports whether the name given by labels is at or below the
+// name given by parent
+func isSubdomain(labels []string, parent []string) bool {
+	if len(labels) < len(parent) {
+		return false
+	}
+
+	return labelsKey(labels[len(labels)-len(parent):]) == labelsKey(parent)
+}
@@ -0,0 +1,156 @@
+package gozone
+
+import (
+	"strings"
+	"testing"
+)
+
+const lookupZone = `$ORIGIN adomain.com.
+$TTL 3600
+@ IN SOA ns.adomain.com. hostmaster.adomain.com. 1 10800 3600 604800 300
+@ IN NS ns.adomain.com.
+ns IN A 192.168.0.53
+www IN A 192.168.0.1
+www IN A 192.168.0.2
+alias IN CNAME www.adomain.com.
+outside IN CNAME www.ahostdomain.com.
+loop1 IN CNAME loop2.adomain.com.
+loop2 IN CNAME loop1.adomain.com.
+*.wild IN TXT "wildcard"
+*.wildalias IN CNAME www.adomain.com.
+a.b.c IN A 192.168.0.3
+sub IN NS ns.sub.adomain.com.
+sub IN NS ns.ahostdomain.com.
+sub IN DS 1 8 2 abcdef
+ns.sub IN A 192.168.0.54
+old IN DNAME new.adomain.com.
+x.new IN A 192.168.0.4
+`
+
+func loadLookupZone(t *testing.T) *Zone {
+	t.Helper()
+
+	z, err := LoadZone(NewScanner(strings.NewReader(lookupZone)))
+	if err != nil {
+		t.Fatalf("Failed to load zone: %s", err)
+	}
+
+	return z
+}
+
+func recordNames(records []Record) string {
+	var names []string
+	for _, r := range records {
+		names = append(names, r.DomainName+" "+r.Type.String()+" "+strings.Join(r.Data, " "))
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func TestZoneGroupsRRsets(t *testing.T) {
+	z := loadLookupZone(t)
+
+	if z.Origin() != "adomain.com." {
+		t.Fatalf("Zone origin was [%s], expected [adomain.com.]", z.Origin())
+	}
+
+	www := z.RRset("WWW.adomain.com.", RecordClass_IN, RecordType_A)
+	if www == nil || len(www.Records) != 2 {
+		t.Fatalf("Zone did not group both www A records into one RRset: %v", www)
+	}
+
+	if len(z.RRsets()) != 16 {
+		t.Fatalf("Zone has %d RRsets, expected 16", len(z.RRsets()))
+	}
+
+	if z.RRset("www.adomain.com.", RecordClass_IN, RecordType_AAAA) != nil {
+		t.Fatalf("Zone returned an RRset which does not exist")
+	}
+}
+
+func TestZoneLookup(t *testing.T) {
+	z := loadLookupZone(t)
+
+	lookups := []struct {
+		name       string
+		rtype      RecordType
+		kind       LookupKind
+		answer     string
+		authority  string
+		additional string
+	}{
+		{"www.adomain.com.", RecordType_A, LookupKind_Answer,
+			"www.adomain.com. A 192.168.0.1, www.adomain.com. A 192.168.0.2", "", ""},
+		{"www.adomain.com.", RecordType_TXT, LookupKind_NoData,
+			"", "adomain.com. SOA ns.adomain.com. hostmaster.adomain.com. 1 10800 3600 604800 300", ""},
+		{"nope.adomain.com.", RecordType_A, LookupKind_NXDomain,
+			"", "adomain.com. SOA ns.adomain.com. hostmaster.adomain.com. 1 10800 3600 604800 300", ""},
+		{"b.c.adomain.com.", RecordType_A, LookupKind_NoData,
+			"", "adomain.com. SOA ns.adomain.com. hostmaster.adomain.com. 1 10800 3600 604800 300", ""},
+		{"alias.adomain.com.", RecordType_A, LookupKind_Answer,
+			"alias.adomain.com. CNAME www.adomain.com., www.adomain.com. A 192.168.0.1, www.adomain.com. A 192.168.0.2", "", ""},
+		{"alias.adomain.com.", RecordType_CNAME, LookupKind_Answer,
+			"alias.adomain.com. CNAME www.adomain.com.", "", ""},
+		{"outside.adomain.com.", RecordType_A, LookupKind_Alias,
+			"outside.adomain.com. CNAME www.ahostdomain.com.", "", ""},
+		{"x.y.wild.adomain.com.", RecordType_TXT, LookupKind_Answer,
+			`x.y.wild.adomain.com. TXT "wildcard"`, "", ""},
+		{"x.wild.adomain.com.", RecordType_A, LookupKind_NoData,
+			"", "adomain.com. SOA ns.adomain.com. hostmaster.adomain.com. 1 10800 3600 604800 300", ""},
+		{"x.wildalias.adomain.com.", RecordType_A, LookupKind_Answer,
+			"x.wildalias.adomain.com. CNAME www.adomain.com., www.adomain.com. A 192.168.0.1, www.adomain.com. A 192.168.0.2", "", ""},
+		{"www.sub.adomain.com.", RecordType_A, LookupKind_Delegation,
+			"", "sub.adomain.com. NS ns.sub.adomain.com., sub.adomain.com. NS ns.ahostdomain.com.", "ns.sub.adomain.com. A 192.168.0.54"},
+		{"sub.adomain.com.", RecordType_DS, LookupKind_Answer,
+			"sub.adomain.com. DS 1 8 2 abcdef", "", ""},
+		{"x.old.adomain.com.", RecordType_A, LookupKind_Answer,
+			"old.adomain.com. DNAME new.adomain.com., x.old.adomain.com. CNAME x.new.adomain.com., x.new.adomain.com. A 192.168.0.4", "", ""},
+		{"www.ahostdomain.com.", RecordType_A, LookupKind_NotAuthoritative, "", "", ""},
+	}
+
+	for _, lookup := range lookups {
+		result := z.Lookup(Question{lookup.name, lookup.rtype, RecordClass_IN})
+		if result.Kind != lookup.kind {
+			t.Fatalf("Lookup of %s %s gave %s, expected %s", lookup.name, lookup.rtype, result.Kind, lookup.kind)
+		}
+
+		if recordNames(result.Answer) != lookup.answer ||
+			recordNames(result.Authority) != lookup.authority ||
+			recordNames(result.Additional) != lookup.additional {
+			t.Fatalf("Lookup of %s %s gave [%s] [%s] [%s], expected [%s] [%s] [%s]", lookup.name, lookup.rtype,
+				recordNames(result.Answer), recordNames(result.Authority), recordNames(result.Additional),
+				lookup.answer, lookup.authority, lookup.additional)
+		}
+	}
+}
+
+func TestZoneLookupStopsCNAMELoops(t *testing.T) {
+	z := loadLookupZone(t)
+
+	result := z.Lookup(Question{"loop1.adomain.com.", RecordType_A, RecordClass_IN})
+	if result.Kind != LookupKind_AliasLoop {
+		t.Fatalf("Lookup of a CNAME loop gave %s, expected %s", result.Kind, LookupKind_AliasLoop)
+	}
+
+	if len(result.Answer) != maxLookupChain+1 {
+		t.Fatalf("Lookup of a CNAME loop gave %d answers, expected %d", len(result.Answer), maxLookupChain+1)
+	}
+}
+
+func TestZoneLookupFollowsGenericCNAME(t *testing.T) {
+	z, err := LoadZone(NewScanner(strings.NewReader(lookupZone + "generic IN CNAME \\# 17 03777777 0761646f6d61696e 03636f6d 00\n")))
+	if err != nil {
+		t.Fatalf("Failed to load zone: %s", err)
+	}
+
+	result := z.Lookup(Question{"generic.adomain.com.", RecordType_A, RecordClass_IN})
+	if result.Kind != LookupKind_Answer || len(result.Answer) != 3 {
+		t.Fatalf("Lookup of a CNAME in the generic form gave %s [%s]", result.Kind, recordNames(result.Answer))
+	}
+}
+
+func TestLoadZoneWithoutSOAFails(t *testing.T) {
+	if _, err := LoadZone(NewScanner(strings.NewReader("adomain.com. 300 IN A 192.168.0.1"))); err == nil {
+		t.Fatalf("Loading a zone without an SOA record did not return an error")
+	}
+}
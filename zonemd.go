@@ -0,0 +1,296 @@
+package gozone
+
+// https://www.ietf.org/rfc/rfc8976.txt
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ZONEMDScheme_Simple = 1
+
+	ZONEMDHash_SHA384 = 1
+	ZONEMDHash_SHA512 = 2
+)
+
+// the shortest digest RFC 8976 section 2.2.4 allows
+const minZONEMDDigest = 12
+
+type ZONEMD struct {
+	Serial        uint32
+	Scheme        uint8
+	HashAlgorithm uint8
+	Digest        []byte
+}
+
+func (rd *ZONEMD) Type() RecordType { return RecordType_ZONEMD }
+
+func (rd *ZONEMD) String() string {
+	return fmt.Sprintf("%d %d %d %X", rd.Serial, rd.Scheme, rd.HashAlgorithm, rd.Digest)
+}
+
+func parseZONEMD(fields []string) (RData, error) {
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("ZONEMD record has %d fields, expected at least 4", len(fields))
+	}
+
+	serial, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid serial '%s' in ZONEMD record", fields[0])
+	}
+
+	scheme, err := parseUint8Field(RecordType_ZONEMD, "scheme", fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm, err := parseUint8Field(RecordType_ZONEMD, "hash algorithm", fields[2])
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := hex.DecodeString(strings.Join(fields[3:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid digest in ZONEMD record: %s", err)
+	}
+
+	if len(digest) < minZONEMDDigest {
+		return nil, fmt.Errorf("ZONEMD digest is %d bytes long, expected at least %d", len(digest), minZONEMDDigest)
+	}
+
+	return &ZONEMD{Serial: uint32(serial), Scheme: scheme, HashAlgorithm: algorithm, Digest: digest}, nil
+}
+
+func zonemdHash(algorithm uint8) (hash.Hash, error) {
+	switch algorithm {
+	case ZONEMDHash_SHA384:
+		return sha512.New384(), nil
+	case ZONEMDHash_SHA512:
+		return sha512.New(), nil
+	}
+
+	return nil, fmt.Errorf("Unsupported ZONEMD hash algorithm %d", algorithm)
+}
+
+// ZONEMDDigest computes the SIMPLE scheme digest of the zone, as RFC 8976
+// section 3.3: every record within the zone, glue and occluded data
+// included, in canonical form and order. The ZONEMD RRset at the apex, and
+// signatures over it, are left out.
+func (z *Zone) ZONEMDDigest(algorithm uint8) ([]byte, error) {
+	h, err := zonemdHash(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	apex := labelsKey(z.labels)
+	var rrsets []*RRset
+	var owners [][]string
+	for _, rrset := range z.rrsets {
+		labels, err := nameLabels(rrset.DomainName)
+		if err != nil || !isSubdomain(labels, z.labels) {
+			continue
+		}
+
+		if rrset.Type == RecordType_ZONEMD && labelsKey(labels) == apex {
+			continue
+		}
+
+		rrsets = append(rrsets, rrset)
+		owners = append(owners, labels)
+	}
+
+	order := make([]int, len(rrsets))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := rrsets[order[i]], rrsets[order[j]]
+		if c := compareLabels(owners[order[i]], owners[order[j]]); c != 0 {
+			return c < 0
+		}
+		if a.Class != b.Class {
+			return a.Class < b.Class
+		}
+		return a.Type < b.Type
+	})
+
+	for _, i := range order {
+		rrset := rrsets[i]
+		isApex := labelsKey(owners[i]) == apex
+		owner := canonicalNameWire(owners[i])
+
+		type rr struct {
+			ttl   int64
+			rdata []byte
+		}
+
+		var rrs []rr
+		for _, record := range rrset.Records {
+			if isApex && rrset.Type == RecordType_RRSIG {
+				rrsig, err := rdataAs[*RRSIG](record)
+				if err != nil {
+					return nil, err
+				}
+
+				if rrsig.TypeCovered == RecordType_ZONEMD {
+					continue
+				}
+			}
+
+			if record.TimeToLive < 0 {
+				return nil, fmt.Errorf("%s %s record at %s has no TTL, so cannot be digested: %w", record.DomainName, record.Type, record.Position, ErrInvalidTTL)
+			}
+
+			rdata, err := record.CanonicalRData()
+			if err != nil {
+				return nil, err
+			}
+			rrs = append(rrs, rr{record.TimeToLive, rdata})
+		}
+
+		sort.SliceStable(rrs, func(i, j int) bool { return bytes.Compare(rrs[i].rdata, rrs[j].rdata) < 0 })
+		for j, rr := range rrs {
+			// RRsets are sets, so duplicate records count once
+			if j > 0 && bytes.Equal(rr.rdata, rrs[j-1].rdata) {
+				continue
+			}
+
+			buf := append([]byte{}, owner...)
+			buf = binary.BigEndian.AppendUint16(buf, uint16(rrset.Type))
+			buf = binary.BigEndian.AppendUint16(buf, uint16(rrset.Class))
+			buf = binary.BigEndian.AppendUint32(buf, uint32(rr.ttl))
+			buf = binary.BigEndian.AppendUint16(buf, uint16(len(rr.rdata)))
+			_, _ = h.Write(append(buf, rr.rdata...))
+		}
+	}
+
+	return h.Sum(nil), nil
+}
+
+// UpdateZONEMD replaces the ZONEMD RRset at the apex with one SIMPLE scheme
+// record for each hash algorithm, carrying the serial of the SOA record.
+// Signatures over an existing ZONEMD RRset are no longer valid once it
+// changes.
+func (z *Zone) UpdateZONEMD(algorithms ...uint8) error {
+	soa := z.apexSOA()
+	if soa == nil {
+		return fmt.Errorf("Zone %s has no SOA record", z.origin)
+	}
+
+	serial, err := soa.Records[0].Serial()
+	if err != nil {
+		return err
+	}
+
+	if len(algorithms) == 0 {
+		algorithms = []uint8{ZONEMDHash_SHA384}
+	}
+
+	var records []Record
+	for _, algorithm := range algorithms {
+		digest, err := z.ZONEMDDigest(algorithm)
+		if err != nil {
+			return err
+		}
+
+		zonemd := &ZONEMD{Serial: serial, Scheme: ZONEMDScheme_Simple, HashAlgorithm: algorithm, Digest: digest}
+		records = append(records, Record{
+			DomainName: z.origin,
+			TimeToLive: soa.Records[0].TimeToLive,
+			Class:      soa.Class,
+			Type:       RecordType_ZONEMD,
+			Data:       presentationFields(zonemd.String()),
+		})
+	}
+
+	if existing := z.nodes[labelsKey(z.labels)].rrset(soa.Class, RecordType_ZONEMD); existing != nil {
+		existing.Records = records
+		return nil
+	}
+
+	for _, record := range records {
+		if err := z.Add(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// VerifyZONEMD checks the ZONEMD RRset at the apex, as RFC 8976 section 4:
+// its serial must match the SOA record, and the digest of at least one
+// record with a supported scheme and hash algorithm must match the zone.
+func (z *Zone) VerifyZONEMD() error {
+	soa := z.apexSOA()
+	if soa == nil {
+		return fmt.Errorf("Zone %s has no SOA record", z.origin)
+	}
+
+	serial, err := soa.Records[0].Serial()
+	if err != nil {
+		return err
+	}
+
+	rrset := z.nodes[labelsKey(z.labels)].rrset(soa.Class, RecordType_ZONEMD)
+	if rrset == nil {
+		return fmt.Errorf("Zone %s has no ZONEMD record", z.origin)
+	}
+
+	seen := make(map[[2]uint8]bool)
+	supported := false
+	for _, record := range rrset.Records {
+		zonemd, err := rdataAs[*ZONEMD](record)
+		if err != nil {
+			return err
+		}
+
+		if zonemd.Serial != serial {
+			return fmt.Errorf("ZONEMD serial %d does not match the SOA serial %d", zonemd.Serial, serial)
+		}
+
+		if zonemd.Scheme != ZONEMDScheme_Simple {
+			continue
+		}
+
+		if _, err = zonemdHash(zonemd.HashAlgorithm); err != nil {
+			continue
+		}
+
+		key := [2]uint8{zonemd.Scheme, zonemd.HashAlgorithm}
+		if seen[key] {
+			return fmt.Errorf("Zone %s has more than one ZONEMD record with scheme %d and hash algorithm %d", z.origin, zonemd.Scheme, zonemd.HashAlgorithm)
+		}
+		seen[key] = true
+		supported = true
+	}
+
+	if !supported {
+		return fmt.Errorf("Zone %s has no ZONEMD record with a supported scheme and hash algorithm", z.origin)
+	}
+
+	for _, record := range rrset.Records {
+		zonemd, _ := rdataAs[*ZONEMD](record)
+		if !seen[[2]uint8{zonemd.Scheme, zonemd.HashAlgorithm}] {
+			continue
+		}
+
+		digest, err := z.ZONEMDDigest(zonemd.HashAlgorithm)
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(digest, zonemd.Digest) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("ZONEMD digest does not match the zone %s", z.origin)
+}
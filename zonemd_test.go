@@ -0,0 +1,179 @@
+package gozone
+
+import (
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// RFC 8976 appendix A.1
+const zonemdZone = `$ORIGIN example.
+example.      86400  IN  SOA     ns1.example. admin.example. 2018031900 (
+                                 1800 900 604800 86400 )
+              86400  IN  NS      ns1.example.
+              86400  IN  NS      ns2.example.
+              86400  IN  ZONEMD  2018031900 1 1 (
+                                 c68090d90a7aed71
+                                 6bc459f9340e3d7c
+                                 1370d4d24b7e2fc3
+                                 a1ddc0b9a87153b9
+                                 a9713b3c9ae5cc27
+                                 777f98b8e730044c )
+ns1           3600   IN  A       203.0.113.63
+ns2           3600   IN  AAAA    2001:db8::63
+`
+
+func loadZONEMDZone(t *testing.T, zone string) *Zone {
+	t.Helper()
+
+	z, err := LoadZone(NewScanner(strings.NewReader(zone)))
+	if err != nil {
+		t.Fatalf("Failed to load zone: %s", err)
+	}
+
+	return z
+}
+
+func TestZONEMDDigest(t *testing.T) {
+	z := loadZONEMDZone(t, zonemdZone)
+
+	digest, err := z.ZONEMDDigest(ZONEMDHash_SHA384)
+	if err != nil {
+		t.Fatalf("Failed to compute digest: %s", err)
+	}
+
+	expected := "c68090d90a7aed716bc459f9340e3d7c1370d4d24b7e2fc3a1ddc0b9a87153b9a9713b3c9ae5cc27777f98b8e730044c"
+	if hex.EncodeToString(digest) != expected {
+		t.Fatalf("Digest was %x, expected %s", digest, expected)
+	}
+
+	if err = z.VerifyZONEMD(); err != nil {
+		t.Fatalf("Failed to verify ZONEMD: %s", err)
+	}
+}
+
+func TestZONEMDExclusions(t *testing.T) {
+	base := loadZONEMDZone(t, zonemdZone)
+	expected, _ := base.ZONEMDDigest(ZONEMDHash_SHA512)
+
+	// duplicates, out of zone data and signatures over the apex ZONEMD do not count
+	z := loadZONEMDZone(t, zonemdZone+`ns1 3600 IN A 203.0.113.63
+outside.test. 3600 IN A 192.0.2.1
+example. 86400 IN RRSIG ZONEMD 13 1 86400 20240401000000 20240301000000 1 example. AQID
+`)
+
+	if digest, _ := z.ZONEMDDigest(ZONEMDHash_SHA512); !reflect.DeepEqual(digest, expected) {
+		t.Fatalf("Excluded records changed the digest")
+	}
+
+	// glue, other signatures at the apex and new data do
+	for _, extra := range []string{
+		"sub.example. 3600 IN NS ns.sub.example.\nns.sub.example. 3600 IN A 192.0.2.1\n",
+		"example. 86400 IN RRSIG SOA 13 1 86400 20240401000000 20240301000000 1 example. AQID\n",
+		"NS1.EXAMPLE. 3600 IN A 203.0.113.64\n",
+	} {
+		z = loadZONEMDZone(t, zonemdZone+extra)
+		if digest, _ := z.ZONEMDDigest(ZONEMDHash_SHA512); reflect.DeepEqual(digest, expected) {
+			t.Fatalf("Adding %q did not change the digest", extra)
+		}
+	}
+}
+
+func TestUpdateZONEMD(t *testing.T) {
+	z := loadZONEMDZone(t, zonemdZone+"www 3600 IN A 192.0.2.1\n")
+	if err := z.VerifyZONEMD(); err == nil {
+		t.Fatalf("Verifying a changed zone should have failed")
+	}
+
+	if _, err := z.BumpSerial(SerialStrategy_Increment, signOptions.Inception); err != nil {
+		t.Fatalf("Failed to bump serial: %s", err)
+	}
+
+	if err := z.UpdateZONEMD(ZONEMDHash_SHA384, ZONEMDHash_SHA512); err != nil {
+		t.Fatalf("Failed to update ZONEMD: %s", err)
+	}
+
+	zonemd := z.RRset("example.", RecordClass_IN, RecordType_ZONEMD)
+	if len(zonemd.Records) != 2 || zonemd.Records[0].Data[0] != "2018031901" {
+		t.Fatalf("ZONEMD RRset was not replaced: %v", zonemd.Records)
+	}
+
+	if err := z.VerifyZONEMD(); err != nil {
+		t.Fatalf("Failed to verify updated ZONEMD: %s", err)
+	}
+
+	fresh := loadZONEMDZone(t, "example. 60 IN SOA ns1.example. admin.example. 1 1800 900 604800 86400\n")
+	if err := fresh.UpdateZONEMD(); err != nil {
+		t.Fatalf("Failed to add ZONEMD: %s", err)
+	}
+
+	if err := fresh.VerifyZONEMD(); err != nil {
+		t.Fatalf("Failed to verify added ZONEMD: %s", err)
+	}
+}
+
+func TestVerifyZONEMDErrors(t *testing.T) {
+	zones := []string{
+		"example. 60 IN SOA ns1.example. admin.example. 1 1800 900 604800 86400\n",
+		"example. 60 IN SOA ns1.example. admin.example. 2 1800 900 604800 86400\nexample. 60 IN ZONEMD 1 1 1 000102030405060708090A0B\n",
+		"example. 60 IN SOA ns1.example. admin.example. 1 1800 900 604800 86400\nexample. 60 IN ZONEMD 1 1 9 000102030405060708090A0B\n",
+		"example. 60 IN SOA ns1.example. admin.example. 1 1800 900 604800 86400\nexample. 60 IN ZONEMD 1 1 1 000102030405060708090A0B\nexample. 60 IN ZONEMD 1 1 1 000102030405060708090A0C\n",
+	}
+
+	for _, zone := range zones {
+		if err := loadZONEMDZone(t, zone).VerifyZONEMD(); err == nil {
+			t.Fatalf("Verifying [%s] should have failed", zone)
+		}
+	}
+
+	z := loadZONEMDZone(t, zones[0])
+	if err := z.Add(Record{DomainName: "example.", TimeToLive: 60, Class: RecordClass_IN, Type: RecordType_ZONEMD, Data: []string{`\#`, "2", "0001"}}); err != nil {
+		t.Fatalf("Failed to add record: %s", err)
+	}
+
+	if err := z.VerifyZONEMD(); err == nil {
+		t.Fatalf("Verifying a zone with a malformed ZONEMD record should have failed")
+	}
+}
+
+func TestZONEMDDigestWithoutTTL(t *testing.T) {
+	z := loadZONEMDZone(t, "example. 60 IN SOA ns1.example. admin.example. 1 1800 900 604800 86400\nexample. IN NS ns1.example.\n")
+	if _, err := z.ZONEMDDigest(ZONEMDHash_SHA384); !errors.Is(err, ErrInvalidTTL) {
+		t.Fatalf("Digesting a zone with a record without a TTL did not return ErrInvalidTTL: %v", err)
+	}
+}
+
+func TestVerifyZONEMDGenericForm(t *testing.T) {
+	zone := strings.Replace(zonemdZone, `ZONEMD  2018031900 1 1 (`, `ZONEMD  \# 54 7848B91C 01 01 (`, 1)
+	if err := loadZONEMDZone(t, zone).VerifyZONEMD(); err != nil {
+		t.Fatalf("Failed to verify a zone with its ZONEMD record in the generic form: %s", err)
+	}
+}
+
+func TestZONEMDRData(t *testing.T) {
+	record := Record{DomainName: "example.", Class: RecordClass_IN, Type: RecordType_ZONEMD, Data: []string{"2018031900", "1", "1", "000102030405", "060708090A0B"}}
+	rdata, err := record.RData()
+	if err != nil {
+		t.Fatalf("Failed to parse ZONEMD: %s", err)
+	}
+
+	wire, err := record.PackWire(nil)
+	if err != nil {
+		t.Fatalf("Failed to pack ZONEMD: %s", err)
+	}
+
+	unpacked, _, err := UnpackRecord(wire, 0)
+	if err != nil {
+		t.Fatalf("Failed to unpack ZONEMD: %s", err)
+	}
+
+	if got, _ := unpacked.RData(); !reflect.DeepEqual(got, rdata) {
+		t.Fatalf("ZONEMD was %#v after a round trip, expected %#v", got, rdata)
+	}
+
+	if _, err = (Record{Type: RecordType_ZONEMD, Data: []string{"1", "1", "1", "0001"}}).RData(); err == nil {
+		t.Fatalf("A ZONEMD digest shorter than 12 bytes should have failed")
+	}
+}